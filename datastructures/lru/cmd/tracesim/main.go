@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	var (
+		policies   string
+		capacities string
+		format     string
+		columns    CSVColumns
+		cfg        SimConfig
+		output     string
+	)
+	var rootCmd = &cobra.Command{
+		Use:   "tracesim [trace file]",
+		Short: "Replay a key trace through caches and print hit ratios",
+		Long: "Replays a key trace, one key per line or CSV with timestamps, through each\n" +
+			"eviction policy at several capacities. Reads standard input without a file.",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := columns.Validate(); err != nil {
+				return err
+			}
+			selected, err := LookupPolicies(policies)
+			if err != nil {
+				return err
+			}
+
+			var in io.Reader = os.Stdin
+			if len(args) == 1 && args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			start := time.Now()
+			trace, err := ReadTrace(in, format, columns)
+			if err != nil {
+				return fmt.Errorf("read trace: %w", err)
+			}
+			if trace.Len() == 0 {
+				return fmt.Errorf("the trace is empty")
+			}
+			if cfg.TTL > 0 && !trace.Timed() {
+				return fmt.Errorf("--ttl needs a CSV trace with a time column")
+			}
+			sizes, err := ParseCapacities(capacities, trace.Unique)
+			if err != nil {
+				return err
+			}
+
+			results := SimulateAll(trace, selected, sizes, cfg)
+			out := cmd.OutOrStdout()
+			switch output {
+			case "table":
+				if err := WriteTable(out, trace, selected, results); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "\nsimulated in %v\n", time.Since(start).Round(time.Millisecond))
+				return nil
+			case "csv":
+				return WriteCSV(out, results)
+			default:
+				return fmt.Errorf("unknown output %q", output)
+			}
+		},
+	}
+
+	flags := rootCmd.Flags()
+	flags.StringVarP(&policies, "policies", "p", "lru", "comma separated policies (lru, lfu, arc, 2q, tinylfu) or all")
+	flags.StringVarP(&capacities, "capacities", "c", "auto", "comma separated capacities, or auto for 1% to 100% of the distinct keys")
+	flags.StringVarP(&format, "format", "f", FormatAuto, "trace format: auto, lines or csv")
+	flags.IntVar(&columns.KeyColumn, "key-column", 1, "CSV column holding the key, counting from 0")
+	flags.IntVar(&columns.TimeColumn, "time-column", 0, "CSV column holding the timestamp, -1 for none")
+	flags.BoolVar(&columns.Header, "header", false, "skip the first CSV record, which names the columns")
+	flags.DurationVar(&cfg.TTL, "ttl", 0, "expire entries this long after they are stored, on the trace's clock")
+	flags.IntVar(&cfg.Warmup, "warmup", 0, "requests that fill the cache before hits are counted")
+	flags.StringVarP(&output, "output", "o", "table", "output format: table or csv")
+
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
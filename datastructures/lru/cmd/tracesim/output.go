@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// WriteTable prints one row per capacity with the hit ratio of each policy
+func WriteTable(w io.Writer, trace *Trace, policies []Policy, results []Result) error {
+	fmt.Fprintf(w, "%d requests, %d distinct keys\n\n", trace.Len(), trace.Unique)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "capacity\t")
+	for _, p := range policies {
+		fmt.Fprintf(tw, "%s\t", p.Name)
+	}
+	fmt.Fprintln(tw)
+	for i := 0; i < len(results); i += len(policies) {
+		fmt.Fprintf(tw, "%d\t", results[i].Capacity)
+		for _, r := range results[i : i+len(policies)] {
+			fmt.Fprintf(tw, "%.2f%%\t", r.HitRatio()*100)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// WriteCSV prints one row per policy and capacity, ready for plotting
+func WriteCSV(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"policy", "capacity", "requests", "hits", "hit_ratio"})
+	for _, r := range results {
+		cw.Write([]string{
+			r.Policy,
+			strconv.Itoa(r.Capacity),
+			strconv.FormatUint(r.Requests, 10),
+			strconv.FormatUint(r.Hits, 10),
+			strconv.FormatFloat(r.HitRatio(), 'f', 6, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kenneth-wang/go-demo/datastructures/lru/lrucache"
+)
+
+// cacheOption configures the caches of the simulator, keyed by trace key
+type cacheOption = lrucache.Option[uint32, struct{}]
+
+// Policy is an eviction policy the simulator can replay a trace through
+type Policy struct {
+	Name string
+	New  func(capacity int, opts ...cacheOption) lrucache.Cache[uint32, struct{}]
+}
+
+// Policies lists every policy by the name used on the command line
+var Policies = []Policy{
+	{"lru", func(c int, opts ...cacheOption) lrucache.Cache[uint32, struct{}] {
+		return lrucache.NewLRUCache[uint32, struct{}](c, opts...)
+	}},
+	{"lfu", func(c int, opts ...cacheOption) lrucache.Cache[uint32, struct{}] {
+		return lrucache.NewLFUCache[uint32, struct{}](c, opts...)
+	}},
+	{"arc", func(c int, opts ...cacheOption) lrucache.Cache[uint32, struct{}] {
+		return lrucache.NewARCCache[uint32, struct{}](c, opts...)
+	}},
+	{"2q", func(c int, opts ...cacheOption) lrucache.Cache[uint32, struct{}] {
+		return lrucache.NewTwoQueueCache[uint32, struct{}](c, opts...)
+	}},
+	{"tinylfu", func(c int, opts ...cacheOption) lrucache.Cache[uint32, struct{}] {
+		return lrucache.NewTinyLFUCache[uint32, struct{}](c, opts...)
+	}},
+}
+
+// LookupPolicies resolves a comma separated list of policy names; "all"
+// selects every policy
+func LookupPolicies(names string) ([]Policy, error) {
+	if names == "all" {
+		return Policies, nil
+	}
+	var selected []Policy
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		found := false
+		for _, p := range Policies {
+			if p.Name == name {
+				selected = append(selected, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown policy %q", name)
+		}
+	}
+	return selected, nil
+}
+
+// Result is the outcome of replaying a trace through one cache
+type Result struct {
+	Policy   string
+	Capacity int
+	Requests uint64
+	Hits     uint64
+}
+
+// HitRatio returns the fraction of requests that hit
+func (r Result) HitRatio() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Requests)
+}
+
+// SimConfig holds what every replay of a trace shares
+type SimConfig struct {
+	TTL    time.Duration // entry lifetime on the trace's own clock; needs a timed trace
+	Warmup int           // leading requests that fill the cache without being counted
+}
+
+// traceClock is a Clock that follows the timestamps of the trace
+type traceClock struct {
+	now time.Time
+}
+
+func (c *traceClock) Now() time.Time { return c.now }
+
+// Simulate replays trace through a cache of the given policy and capacity:
+// every miss inserts the key, as a read-through cache would
+func Simulate(trace *Trace, policy Policy, capacity int, cfg SimConfig) Result {
+	clock := &traceClock{}
+	opts := []cacheOption{lrucache.WithClock[uint32, struct{}](clock)}
+	if cfg.TTL > 0 && trace.Timed() {
+		opts = append(opts, lrucache.WithTTL[uint32, struct{}](cfg.TTL))
+	}
+	cache := policy.New(capacity, opts...)
+
+	result := Result{Policy: policy.Name, Capacity: capacity}
+	for i, key := range trace.Keys {
+		if trace.Timed() {
+			clock.now = trace.Times[i]
+		}
+		_, hit := cache.Get(key)
+		if !hit {
+			cache.Put(key, struct{}{})
+		}
+		if i < cfg.Warmup {
+			continue
+		}
+		result.Requests++
+		if hit {
+			result.Hits++
+		}
+	}
+	return result
+}
+
+// SimulateAll replays trace through every policy at every capacity, in
+// parallel, and returns the results ordered by capacity and then policy
+func SimulateAll(trace *Trace, policies []Policy, capacities []int, cfg SimConfig) []Result {
+	results := make([]Result, len(policies)*len(capacities))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				capacity := capacities[i/len(policies)]
+				results[i] = Simulate(trace, policies[i%len(policies)], capacity, cfg)
+			}
+		}()
+	}
+	for i := range results {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// autoFractions are the cache sizes tried by default, as fractions of the
+// number of distinct keys in the trace
+var autoFractions = []float64{0.01, 0.02, 0.05, 0.1, 0.2, 0.5, 1}
+
+// ParseCapacities reads a comma separated list of capacities. An empty
+// list or "auto" picks sizes relative to the trace's distinct keys.
+func ParseCapacities(list string, unique int) ([]int, error) {
+	var capacities []int
+	if list == "" || list == "auto" {
+		for _, f := range autoFractions {
+			capacities = append(capacities, max(1, int(f*float64(unique))))
+		}
+	} else {
+		for _, part := range strings.Split(list, ",") {
+			var c int
+			if _, err := fmt.Sscan(strings.TrimSpace(part), &c); err != nil || c <= 0 {
+				return nil, fmt.Errorf("bad capacity %q", part)
+			}
+			capacities = append(capacities, c)
+		}
+	}
+	slices.Sort(capacities)
+	// Tiny traces round several fractions to the same size
+	return slices.Compact(capacities), nil
+}
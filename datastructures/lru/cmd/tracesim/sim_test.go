@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// traceOf builds an untimed trace from single-letter keys
+func traceOf(keys string) *Trace {
+	trace, _ := ReadTrace(strings.NewReader(strings.Join(strings.Split(keys, ""), "\n")), FormatLines, CSVColumns{})
+	return trace
+}
+
+func TestSimulate_LRU(t *testing.T) {
+	lru, _ := LookupPolicies("lru")
+	// With room for two keys only a(3rd) and c(last) hit: each other request
+	// evicts the key that is needed next
+	got := Simulate(traceOf("abacbacc"), lru[0], 2, SimConfig{})
+	if got.Requests != 8 || got.Hits != 2 {
+		t.Fatalf("Simulate() = %+v, want 2 hits out of 8", got)
+	}
+	if got.HitRatio() != 2.0/8 {
+		t.Fatalf("HitRatio() = %v", got.HitRatio())
+	}
+}
+
+func TestSimulate_Warmup(t *testing.T) {
+	lru, _ := LookupPolicies("lru")
+	got := Simulate(traceOf("abcabc"), lru[0], 3, SimConfig{Warmup: 3})
+	if got.Requests != 3 || got.Hits != 3 {
+		t.Fatalf("Simulate() = %+v, warm-up requests must not count", got)
+	}
+}
+
+func TestSimulate_TTLFollowsTraceTime(t *testing.T) {
+	input := "0,a\n10,a\n100,a\n105,a\n"
+	trace, err := ReadTrace(strings.NewReader(input), FormatCSV, CSVColumns{KeyColumn: 1, TimeColumn: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lru, _ := LookupPolicies("lru")
+	got := Simulate(trace, lru[0], 10, SimConfig{TTL: 30 * time.Second})
+	// a expires between the second and third request
+	if got.Hits != 2 {
+		t.Fatalf("Simulate() = %+v, want 2 hits", got)
+	}
+}
+
+func TestSimulateAll_OrdersResults(t *testing.T) {
+	policies, err := LookupPolicies("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := traceOf(strings.Repeat("abcdefabcabaaz", 20))
+	capacities := []int{1, 2, 4, 8}
+	results := SimulateAll(trace, policies, capacities, SimConfig{})
+	if len(results) != len(policies)*len(capacities) {
+		t.Fatalf("%d results", len(results))
+	}
+	for i, r := range results {
+		if r.Capacity != capacities[i/len(policies)] || r.Policy != policies[i%len(policies)].Name {
+			t.Fatalf("result %d is %s at %d", i, r.Policy, r.Capacity)
+		}
+	}
+	// Every policy hits everything but the first requests once all keys fit
+	for _, r := range results[len(results)-len(policies):] {
+		if r.Hits != r.Requests-7 {
+			t.Fatalf("%s at capacity 8: %d hits out of %d", r.Policy, r.Hits, r.Requests)
+		}
+	}
+}
+
+func TestLookupPolicies(t *testing.T) {
+	policies, err := LookupPolicies("LRU, tinylfu")
+	if err != nil || len(policies) != 2 || policies[1].Name != "tinylfu" {
+		t.Fatalf("LookupPolicies() = %v, %v", policies, err)
+	}
+	if _, err := LookupPolicies("lru,fifo"); err == nil {
+		t.Fatal("an unknown policy should be rejected")
+	}
+}
+
+func TestParseCapacities(t *testing.T) {
+	got, err := ParseCapacities("100, 10,1000,10", 0)
+	if err != nil || !slices.Equal(got, []int{10, 100, 1000}) {
+		t.Fatalf("ParseCapacities() = %v, %v", got, err)
+	}
+	got, _ = ParseCapacities("auto", 1000)
+	if !slices.Equal(got, []int{10, 20, 50, 100, 200, 500, 1000}) {
+		t.Fatalf("auto capacities = %v", got)
+	}
+	if got, _ := ParseCapacities("", 3); !slices.Equal(got, []int{1, 3}) {
+		t.Fatalf("auto capacities for 3 keys = %v", got)
+	}
+	if _, err := ParseCapacities("10,zero", 0); err == nil {
+		t.Fatal("a bad capacity should be rejected")
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	policies, _ := LookupPolicies("lru,arc")
+	trace := traceOf("abab")
+	results := SimulateAll(trace, policies, []int{1, 2}, SimConfig{})
+
+	var csv bytes.Buffer
+	if err := WriteCSV(&csv, results); err != nil {
+		t.Fatal(err)
+	}
+	want := "policy,capacity,requests,hits,hit_ratio\n" +
+		"lru,1,4,0,0.000000\narc,1,4,0,0.000000\n" +
+		"lru,2,4,2,0.500000\narc,2,4,2,0.500000\n"
+	if csv.String() != want {
+		t.Fatalf("CSV output:\n%s", csv.String())
+	}
+
+	var table bytes.Buffer
+	if err := WriteTable(&table, trace, policies, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 5 || !strings.Contains(lines[0], "4 requests, 2 distinct keys") ||
+		strings.Fields(lines[4])[1] != "50.00%" {
+		t.Fatalf("table output:\n%s", table.String())
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trace formats accepted by ReadTrace
+const (
+	FormatAuto  = "auto"  // CSV if the first record has a comma, lines otherwise
+	FormatLines = "lines" // one key per line
+	FormatCSV   = "csv"   // comma separated, with a key and an optional time column
+)
+
+// Trace is a sequence of key requests. Keys are replaced by dense ids so
+// that replaying a trace does not hash strings.
+type Trace struct {
+	Keys   []uint32
+	Times  []time.Time // one per request, or empty if the trace has no times
+	Unique int         // number of distinct keys
+}
+
+// Len returns the number of requests
+func (t *Trace) Len() int {
+	return len(t.Keys)
+}
+
+// Timed reports whether the requests carry timestamps
+func (t *Trace) Timed() bool {
+	return len(t.Times) > 0
+}
+
+// CSVColumns picks the columns of a CSV trace; TimeColumn is -1 if the
+// trace has no timestamps
+type CSVColumns struct {
+	KeyColumn  int
+	TimeColumn int
+	Header     bool // the first record names the columns and is skipped
+}
+
+// Validate rejects column numbers that cannot pick a column
+func (c CSVColumns) Validate() error {
+	if c.KeyColumn < 0 {
+		return fmt.Errorf("key column %d must not be negative", c.KeyColumn)
+	}
+	if c.TimeColumn < -1 {
+		return fmt.Errorf("time column %d must be -1 for none, or a column number", c.TimeColumn)
+	}
+	return nil
+}
+
+// traceBuilder interns keys while a trace is read
+type traceBuilder struct {
+	trace *Trace
+	ids   map[string]uint32
+}
+
+func newTraceBuilder() *traceBuilder {
+	return &traceBuilder{trace: &Trace{}, ids: make(map[string]uint32)}
+}
+
+// add appends a request for key
+func (b *traceBuilder) add(key string) {
+	id, ok := b.ids[key]
+	if !ok {
+		id = uint32(len(b.ids))
+		b.ids[key] = id
+	}
+	b.trace.Keys = append(b.trace.Keys, id)
+}
+
+// build finishes the trace
+func (b *traceBuilder) build() *Trace {
+	b.trace.Unique = len(b.ids)
+	return b.trace
+}
+
+// ReadTrace reads a trace in the given format. Blank lines and lines
+// starting with # are skipped in both formats. A CSV header row is skipped
+// when columns.Header is set, or when its time column is not a timestamp.
+func ReadTrace(r io.Reader, format string, columns CSVColumns) (*Trace, error) {
+	br := bufio.NewReader(r)
+	if format == FormatAuto {
+		format = FormatLines
+		if first, err := firstRecord(br); err != nil {
+			return nil, err
+		} else if strings.Contains(first, ",") {
+			format = FormatCSV
+		}
+	}
+	switch format {
+	case FormatLines:
+		return readLines(br)
+	case FormatCSV:
+		if err := columns.Validate(); err != nil {
+			return nil, err
+		}
+		return readCSV(br, columns)
+	default:
+		return nil, fmt.Errorf("unknown trace format %q", format)
+	}
+}
+
+// firstRecord peeks at the first line that is not blank or a comment
+func firstRecord(br *bufio.Reader) (string, error) {
+	for size := 4096; ; size *= 2 {
+		data, err := br.Peek(size)
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				return line, nil
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, bufio.ErrBufferFull) {
+				return "", nil
+			}
+			return "", err
+		}
+	}
+}
+
+// readLines reads one key per line
+func readLines(r io.Reader) (*Trace, error) {
+	b := newTraceBuilder()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		key := strings.TrimSpace(scanner.Text())
+		if key == "" || strings.HasPrefix(key, "#") {
+			continue
+		}
+		b.add(key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b.build(), nil
+}
+
+// readCSV reads keys, and timestamps if a time column is given
+func readCSV(r io.Reader, columns CSVColumns) (*Trace, error) {
+	b := newTraceBuilder()
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n == 1 && columns.Header {
+			continue
+		}
+		if columns.KeyColumn >= len(record) || columns.TimeColumn >= len(record) {
+			return nil, fmt.Errorf("record %d has %d columns", n, len(record))
+		}
+		if columns.TimeColumn >= 0 {
+			at, err := parseTimestamp(record[columns.TimeColumn])
+			if err != nil {
+				if n == 1 {
+					continue // a header row
+				}
+				return nil, fmt.Errorf("record %d: %w", n, err)
+			}
+			b.trace.Times = append(b.trace.Times, at)
+		}
+		b.add(record[columns.KeyColumn])
+	}
+	return b.build(), nil
+}
+
+// parseTimestamp reads RFC 3339 times and Unix times in seconds,
+// milliseconds, microseconds or nanoseconds, told apart by magnitude
+func parseTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		switch abs := math.Abs(v); {
+		case abs >= 1e17:
+			return time.Unix(0, int64(v)), nil
+		case abs >= 1e14:
+			return time.UnixMicro(int64(v)), nil
+		case abs >= 1e11:
+			return time.UnixMilli(int64(v)), nil
+		default:
+			sec, frac := math.Modf(v)
+			return time.Unix(int64(sec), int64(frac*1e9)), nil
+		}
+	}
+	at, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad timestamp %q", s)
+	}
+	return at, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var defaultColumns = CSVColumns{KeyColumn: 1, TimeColumn: 0}
+
+func TestReadTrace_Lines(t *testing.T) {
+	input := "# a comment\nalpha\n\n beta \nalpha\ngamma\n"
+	trace, err := ReadTrace(strings.NewReader(input), FormatAuto, defaultColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(trace.Keys, []uint32{0, 1, 0, 2}) || trace.Unique != 3 {
+		t.Fatalf("Keys = %v, Unique = %d", trace.Keys, trace.Unique)
+	}
+	if trace.Timed() {
+		t.Fatal("a line trace has no timestamps")
+	}
+}
+
+func TestReadTrace_CSVWithHeader(t *testing.T) {
+	input := "timestamp,key,size\n2024-01-01T00:00:00Z,a,10\n2024-01-01T00:00:01.5Z,b,20\n2024-01-01T00:00:02Z,a,10\n"
+	trace, err := ReadTrace(strings.NewReader(input), FormatAuto, defaultColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(trace.Keys, []uint32{0, 1, 0}) {
+		t.Fatalf("Keys = %v, the header should be skipped", trace.Keys)
+	}
+	if got := trace.Times[1].Sub(trace.Times[0]); got != 1500*time.Millisecond {
+		t.Fatalf("second request came %v after the first", got)
+	}
+}
+
+func TestReadTrace_CSVColumns(t *testing.T) {
+	input := "a,1\nb,2\na,3\n"
+	trace, err := ReadTrace(strings.NewReader(input), FormatCSV, CSVColumns{KeyColumn: 0, TimeColumn: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(trace.Keys, []uint32{0, 1, 0}) || trace.Timed() {
+		t.Fatalf("Keys = %v, Timed = %v", trace.Keys, trace.Timed())
+	}
+
+	// Without a time column a header row is only skipped when asked to
+	input = "key,size\na,1\nb,2\n"
+	trace, err = ReadTrace(strings.NewReader(input), FormatCSV, CSVColumns{KeyColumn: 0, TimeColumn: -1, Header: true})
+	if err != nil || trace.Len() != 2 || trace.Unique != 2 {
+		t.Fatalf("Len = %d, Unique = %d, %v: the header should be skipped", trace.Len(), trace.Unique, err)
+	}
+
+	// A key containing commas stays whole in the lines format
+	trace, err = ReadTrace(strings.NewReader("a,1\na,1\n"), FormatLines, defaultColumns)
+	if err != nil || trace.Unique != 1 {
+		t.Fatalf("Unique = %d, %v", trace.Unique, err)
+	}
+}
+
+func TestReadTrace_Errors(t *testing.T) {
+	tests := map[string]string{
+		"bad timestamp":  "1700000000,a\nyesterday,b\n",
+		"missing column": "1700000000,a\n1700000001\n",
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ReadTrace(strings.NewReader(input), FormatCSV, defaultColumns); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+	for _, columns := range []CSVColumns{{KeyColumn: -1, TimeColumn: 0}, {KeyColumn: 1, TimeColumn: -2}} {
+		if _, err := ReadTrace(strings.NewReader("1700000000,a\n"), FormatCSV, columns); err == nil {
+			t.Fatalf("columns %+v should be rejected", columns)
+		}
+	}
+	if _, err := ReadTrace(strings.NewReader("a\n"), "xml", defaultColumns); err == nil {
+		t.Fatal("an unknown format should be rejected")
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, s := range []string{"1704067200", "1704067200.0", "1704067200000", "1704067200000000", "1704067200000000000", "2024-01-01T00:00:00Z"} {
+		got, err := parseTimestamp(s)
+		if err != nil || !got.Equal(want) {
+			t.Fatalf("parseTimestamp(%s) = %v, %v", s, got, err)
+		}
+	}
+}
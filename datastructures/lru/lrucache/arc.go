@@ -0,0 +1,179 @@
+package lrucache
+
+import "time"
+
+// ARCCache implements Adaptive Replacement Cache. It balances a list of
+// entries seen once (t1) against a list of entries seen again (t2), and
+// moves the split between them using ghost lists of recently evicted keys
+// (b1, b2). Unlike LRU, a single scan cannot flush the frequently used
+// entries.
+type ARCCache[K comparable, V any] struct {
+	cacheBase[K, V]
+	items  map[K]*entry[K, V] // resident entries in t1 and t2
+	ghosts map[K]*entry[K, V] // evicted keys in b1 and b2, without values
+	t1, t2 entryList[K, V]
+	b1, b2 entryList[K, V]
+	p      int // target size of t1
+}
+
+// NewARCCache creates a new ARC cache with the given capacity
+func NewARCCache[K comparable, V any](capacity int, opts ...Option[K, V]) *ARCCache[K, V] {
+	return &ARCCache[K, V]{
+		cacheBase: newCacheBase[K, V](capacity, opts, false),
+		items:     make(map[K]*entry[K, V]),
+		ghosts:    make(map[K]*entry[K, V]),
+	}
+}
+
+// Get retrieves a value by key; a hit promotes the entry to t2
+func (c *ARCCache[K, V]) Get(key K) (V, bool) {
+	e, ok := c.items[key]
+	if ok && c.expired(e.expiresAt) {
+		c.expire(e)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		var zero V
+		return zero, false
+	}
+	c.stats.Hits++
+	e.list.remove(e)
+	c.t2.pushFront(e)
+	return e.value, true
+}
+
+// Put sets a key-value pair in the cache, expiring after the default TTL
+func (c *ARCCache[K, V]) Put(key K, value V) {
+	c.PutWithTTL(key, value, c.ttl)
+}
+
+// PutWithTTL sets a key-value pair that expires after ttl
+func (c *ARCCache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) {
+	expiresAt := c.deadline(ttl)
+	if e, ok := c.items[key]; ok {
+		old := e.value
+		e.value, e.expiresAt = value, expiresAt
+		e.list.remove(e)
+		c.t2.pushFront(e)
+		c.stats.Updates++
+		c.evicted(key, old, EvictionReplaced)
+		return
+	}
+
+	c.stats.Insertions++
+	e := &entry[K, V]{key: key, value: value, expiresAt: expiresAt}
+	if ghost, ok := c.ghosts[key]; ok {
+		// The key was evicted too early: grow the list it was evicted from
+		inB2 := ghost.list == &c.b2
+		if inB2 {
+			c.p = max(0, c.p-max(1, c.b1.len/c.b2.len))
+		} else {
+			c.p = min(c.capacity, c.p+max(1, c.b2.len/c.b1.len))
+		}
+		ghost.list.remove(ghost)
+		delete(c.ghosts, key)
+		if len(c.items) >= c.capacity {
+			c.replace(inB2)
+		}
+		c.items[key] = e
+		c.t2.pushFront(e)
+		c.trimGhosts()
+		return
+	}
+
+	if len(c.items) >= c.capacity {
+		c.replace(false)
+	}
+	c.items[key] = e
+	c.t1.pushFront(e)
+	c.trimGhosts()
+}
+
+// replace evicts from t1 or t2 depending on the target p, remembering the
+// key in the matching ghost list
+func (c *ARCCache[K, V]) replace(ghostInB2 bool) {
+	from, to := &c.t2, &c.b2
+	if c.t1.len > 0 && (c.t1.len > c.p || (c.t1.len == c.p && ghostInB2)) {
+		from, to = &c.t1, &c.b1
+	}
+	if from.len == 0 {
+		from, to = &c.t1, &c.b1
+	}
+	victim := from.back()
+	from.remove(victim)
+	delete(c.items, victim.key)
+	c.stats.Evictions++
+	c.evicted(victim.key, victim.value, EvictionCapacity)
+
+	var zero V
+	victim.value, victim.expiresAt = zero, time.Time{}
+	to.pushFront(victim)
+	c.ghosts[victim.key] = victim
+}
+
+// trimGhosts keeps b1 within capacity-p and b2 within p entries
+func (c *ARCCache[K, V]) trimGhosts() {
+	for c.b1.len > 0 && c.b1.len > c.capacity-c.p {
+		c.dropGhost(&c.b1)
+	}
+	for c.b2.len > 0 && c.b2.len > c.p {
+		c.dropGhost(&c.b2)
+	}
+}
+
+// dropGhost forgets the oldest key of a ghost list
+func (c *ARCCache[K, V]) dropGhost(list *entryList[K, V]) {
+	ghost := list.back()
+	list.remove(ghost)
+	delete(c.ghosts, ghost.key)
+}
+
+// expire drops an expired entry without leaving a ghost behind
+func (c *ARCCache[K, V]) expire(e *entry[K, V]) {
+	e.list.remove(e)
+	delete(c.items, e.key)
+	c.stats.Expirations++
+	c.evicted(e.key, e.value, EvictionExpired)
+}
+
+// RemoveExpired removes every expired entry and returns how many there were
+func (c *ARCCache[K, V]) RemoveExpired() int {
+	removed := 0
+	for _, e := range c.items {
+		if c.expired(e.expiresAt) {
+			c.expire(e)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Size returns the current size of the cache
+func (c *ARCCache[K, V]) Size() int {
+	return len(c.items)
+}
+
+// Keys returns the keys seen more than once (t2), then those seen once
+// (t1), most recent first within each
+func (c *ARCCache[K, V]) Keys() []K {
+	keys := make([]K, 0, len(c.items))
+	keys = c.t2.appendKeys(keys)
+	return c.t1.appendKeys(keys)
+}
+
+// Clear removes all entries and forgets the adaptation state
+func (c *ARCCache[K, V]) Clear() {
+	c.t1.clear(&c.cacheBase)
+	c.t2.clear(&c.cacheBase)
+	c.b1 = entryList[K, V]{}
+	c.b2 = entryList[K, V]{}
+	c.items = make(map[K]*entry[K, V])
+	c.ghosts = make(map[K]*entry[K, V])
+	c.p = 0
+}
+
+// String returns a string representation of the cache
+func (c *ARCCache[K, V]) String() string {
+	return formatCache("ARCCache", c.capacity, c.Keys())
+}
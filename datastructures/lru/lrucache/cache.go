@@ -0,0 +1,190 @@
+package lrucache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Cache is the API shared by every eviction policy
+type Cache[K comparable, V any] interface {
+	Get(key K) (V, bool)
+	Put(key K, value V)
+	PutWithTTL(key K, value V, ttl time.Duration)
+	RemoveExpired() int
+	Size() int
+	Capacity() int
+	Keys() []K
+	Clear()
+	Stats() Stats
+}
+
+// cacheBase holds what every policy shares: capacity, expiry settings,
+// counters and the eviction callback
+type cacheBase[K comparable, V any] struct {
+	capacity int
+	ttl      time.Duration
+	clock    Clock
+	onEvict  func(key K, value V, reason EvictionReason)
+	stats    Stats
+}
+
+// newCacheBase validates the capacity and applies opts. Only LRUCache
+// supports weights; the other policies pass weighted false.
+func newCacheBase[K comparable, V any](capacity int, opts []Option[K, V], weighted bool) cacheBase[K, V] {
+	if capacity <= 0 {
+		panic("capacity must be positive")
+	}
+	options := newCacheOptions(opts)
+	if options.weigher != nil && !weighted {
+		panic("this eviction policy does not support WithMaxWeight")
+	}
+	if options.weigher != nil && options.maxWeight <= 0 {
+		panic("max weight must be positive")
+	}
+	return cacheBase[K, V]{
+		capacity: capacity,
+		ttl:      options.ttl,
+		clock:    options.clock,
+		onEvict:  options.onEvict,
+	}
+}
+
+// deadline returns when an entry stored now with ttl expires, zero for never
+func (b *cacheBase[K, V]) deadline(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return b.clock.Now().Add(ttl)
+}
+
+// expired reports whether a deadline has passed
+func (b *cacheBase[K, V]) expired(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && !b.clock.Now().Before(expiresAt)
+}
+
+// evicted reports an entry that left the cache to the callback
+func (b *cacheBase[K, V]) evicted(key K, value V, reason EvictionReason) {
+	if b.onEvict != nil {
+		b.onEvict(key, value, reason)
+	}
+}
+
+// Stats returns a snapshot of the cache's counters
+func (b *cacheBase[K, V]) Stats() Stats {
+	return b.stats
+}
+
+// Capacity returns the capacity of the cache
+func (b *cacheBase[K, V]) Capacity() int {
+	return b.capacity
+}
+
+// formatCache is the String form shared by the caches
+func formatCache[K any](name string, capacity int, keys []K) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s{capacity: %d, size: %d, keys: [", name, capacity, len(keys))
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%v", key)
+	}
+	b.WriteString("]}")
+	return b.String()
+}
+
+// entry is a cached item of the multi-list policies
+type entry[K comparable, V any] struct {
+	key        K
+	value      V
+	expiresAt  time.Time
+	prev, next *entry[K, V]
+	list       *entryList[K, V] // the list holding the entry
+	freq       int              // LFU access count
+}
+
+// entryList is a doubly linked list of entries, front is most recent.
+// The zero value is an empty list; it must not be copied after use.
+type entryList[K comparable, V any] struct {
+	root entry[K, V]
+	len  int
+}
+
+// lazyInit links the sentinel to itself on first use
+func (l *entryList[K, V]) lazyInit() {
+	if l.root.next == nil {
+		l.root.next = &l.root
+		l.root.prev = &l.root
+	}
+}
+
+// pushFront inserts e at the front
+func (l *entryList[K, V]) pushFront(e *entry[K, V]) {
+	l.lazyInit()
+	e.prev = &l.root
+	e.next = l.root.next
+	l.root.next.prev = e
+	l.root.next = e
+	e.list = l
+	l.len++
+}
+
+// remove unlinks e, which must be on l
+func (l *entryList[K, V]) remove(e *entry[K, V]) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev, e.next, e.list = nil, nil, nil
+	l.len--
+}
+
+// moveToFront makes e the most recent entry of l
+func (l *entryList[K, V]) moveToFront(e *entry[K, V]) {
+	l.remove(e)
+	l.pushFront(e)
+}
+
+// back returns the least recent entry, nil when empty
+func (l *entryList[K, V]) back() *entry[K, V] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.prev
+}
+
+// each calls fn for every entry from front to back; fn may remove the entry
+func (l *entryList[K, V]) each(fn func(e *entry[K, V])) {
+	if l.len == 0 {
+		return
+	}
+	for e := l.root.next; e != &l.root; {
+		next := e.next
+		fn(e)
+		e = next
+	}
+}
+
+// appendKeys appends the keys from front to back
+func (l *entryList[K, V]) appendKeys(keys []K) []K {
+	l.each(func(e *entry[K, V]) { keys = append(keys, e.key) })
+	return keys
+}
+
+// clear empties the list, reporting every entry to the callback
+func (l *entryList[K, V]) clear(base *cacheBase[K, V]) {
+	l.each(func(e *entry[K, V]) { base.evicted(e.key, e.value, EvictionCleared) })
+	l.root.next = &l.root
+	l.root.prev = &l.root
+	l.len = 0
+}
+
+// Every cache implements Cache
+var (
+	_ Cache[string, int] = (*LRUCache[string, int])(nil)
+	_ Cache[string, int] = (*SyncLRUCache[string, int])(nil)
+	_ Cache[string, int] = (*ShardedLRUCache[string, int])(nil)
+	_ Cache[string, int] = (*LFUCache[string, int])(nil)
+	_ Cache[string, int] = (*ARCCache[string, int])(nil)
+	_ Cache[string, int] = (*TwoQueueCache[string, int])(nil)
+	_ Cache[string, int] = (*TinyLFUCache[string, int])(nil)
+)
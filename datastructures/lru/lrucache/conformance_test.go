@@ -0,0 +1,392 @@
+package lrucache
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+// cacheFactory builds a cache under test
+type cacheFactory func(capacity int, opts ...Option[int, int]) Cache[int, int]
+
+// cacheFactories lists every implementation the conformance suite checks
+var cacheFactories = []struct {
+	name string
+	new  cacheFactory
+}{
+	{"LRU", func(capacity int, opts ...Option[int, int]) Cache[int, int] {
+		return NewLRUCache[int, int](capacity, opts...)
+	}},
+	{"SyncLRU", func(capacity int, opts ...Option[int, int]) Cache[int, int] {
+		return NewSyncLRUCache[int, int](capacity, opts...)
+	}},
+	{"ShardedLRU", func(capacity int, opts ...Option[int, int]) Cache[int, int] {
+		return NewShardedLRUCache[int, int](capacity, 4, nil, opts...)
+	}},
+	{"LFU", func(capacity int, opts ...Option[int, int]) Cache[int, int] {
+		return NewLFUCache[int, int](capacity, opts...)
+	}},
+	{"ARC", func(capacity int, opts ...Option[int, int]) Cache[int, int] {
+		return NewARCCache[int, int](capacity, opts...)
+	}},
+	{"2Q", func(capacity int, opts ...Option[int, int]) Cache[int, int] {
+		return NewTwoQueueCache[int, int](capacity, opts...)
+	}},
+	{"TinyLFU", func(capacity int, opts ...Option[int, int]) Cache[int, int] {
+		return NewTinyLFUCache[int, int](capacity, opts...)
+	}},
+}
+
+// forEachCache runs a subtest per implementation
+func forEachCache(t *testing.T, test func(t *testing.T, newCache cacheFactory)) {
+	for _, factory := range cacheFactories {
+		t.Run(factory.name, func(t *testing.T) {
+			test(t, factory.new)
+		})
+	}
+}
+
+func TestConformance_GetPut(t *testing.T) {
+	forEachCache(t, func(t *testing.T, newCache cacheFactory) {
+		cache := newCache(8)
+		if _, ok := cache.Get(1); ok {
+			t.Fatal("empty cache reported a hit")
+		}
+		cache.Put(1, 10)
+		cache.Put(2, 20)
+		cache.Put(1, 11)
+
+		if v, ok := cache.Get(1); !ok || v != 11 {
+			t.Fatalf("Get(1) = %v, %v; want 11, true", v, ok)
+		}
+		if v, ok := cache.Get(2); !ok || v != 20 {
+			t.Fatalf("Get(2) = %v, %v; want 20, true", v, ok)
+		}
+		if cache.Size() != 2 || cache.Capacity() != 8 {
+			t.Fatalf("Size() = %d, Capacity() = %d", cache.Size(), cache.Capacity())
+		}
+	})
+}
+
+func TestConformance_RespectsCapacity(t *testing.T) {
+	forEachCache(t, func(t *testing.T, newCache cacheFactory) {
+		for _, capacity := range []int{1, 2, 7, 64} {
+			cache := newCache(capacity)
+			rng := rand.New(rand.NewSource(int64(capacity)))
+			for i := 0; i < 2000; i++ {
+				key := rng.Intn(capacity * 3)
+				if v, ok := cache.Get(key); ok && v != key {
+					t.Fatalf("Get(%d) = %d, stale or wrong value", key, v)
+				}
+				cache.Put(key, key)
+				if cache.Size() > capacity {
+					t.Fatalf("capacity %d: Size() = %d", capacity, cache.Size())
+				}
+			}
+			if keys := cache.Keys(); len(keys) != cache.Size() {
+				t.Fatalf("Keys() has %d entries, Size() is %d", len(keys), cache.Size())
+			}
+		}
+	})
+}
+
+func TestConformance_KeysAreResidentAndUnique(t *testing.T) {
+	forEachCache(t, func(t *testing.T, newCache cacheFactory) {
+		cache := newCache(16)
+		for i := 0; i < 100; i++ {
+			cache.Put(i%40, i%40)
+			cache.Get((i * 7) % 40)
+		}
+		seen := make(map[int]bool)
+		for _, key := range cache.Keys() {
+			if seen[key] {
+				t.Fatalf("key %d listed twice", key)
+			}
+			seen[key] = true
+			if _, ok := cache.Get(key); !ok {
+				t.Fatalf("listed key %d is not resident", key)
+			}
+		}
+	})
+}
+
+func TestConformance_Stats(t *testing.T) {
+	forEachCache(t, func(t *testing.T, newCache cacheFactory) {
+		cache := newCache(4)
+		for i := 0; i < 10; i++ {
+			cache.Put(i, i)
+		}
+		cache.Put(9, 90)
+		for i := 0; i < 10; i++ {
+			cache.Get(i)
+		}
+
+		stats := cache.Stats()
+		if stats.Insertions != 10 || stats.Updates != 1 {
+			t.Fatalf("unexpected write counters %+v", stats)
+		}
+		if stats.Requests() != 10 || stats.Hits != uint64(cache.Size()) {
+			t.Fatalf("Hits = %d with %d resident keys: %+v", stats.Hits, cache.Size(), stats)
+		}
+		if stats.Evictions != 10-uint64(cache.Size()) {
+			t.Fatalf("Evictions = %d with %d resident keys", stats.Evictions, cache.Size())
+		}
+	})
+}
+
+func TestConformance_OnEvictAccountsForEveryKey(t *testing.T) {
+	forEachCache(t, func(t *testing.T, newCache cacheFactory) {
+		evicted := make(map[int]EvictionReason)
+		cache := newCache(8, WithOnEvict(func(key, value int, reason EvictionReason) {
+			if reason == EvictionReplaced {
+				return
+			}
+			if _, dup := evicted[key]; dup {
+				t.Fatalf("key %d evicted twice", key)
+			}
+			if key != value {
+				t.Fatalf("callback got %d=%d", key, value)
+			}
+			evicted[key] = reason
+		}))
+		for i := 0; i < 50; i++ {
+			cache.Put(i, i)
+			cache.Get(i / 2)
+		}
+
+		// Every key is either still resident or was reported exactly once
+		resident := cache.Keys()
+		if len(resident)+len(evicted) != 50 {
+			t.Fatalf("%d resident + %d evicted != 50 inserted", len(resident), len(evicted))
+		}
+		for _, key := range resident {
+			if _, ok := evicted[key]; ok {
+				t.Fatalf("resident key %d was reported as evicted", key)
+			}
+		}
+
+		cache.Clear()
+		if cache.Size() != 0 || len(cache.Keys()) != 0 {
+			t.Fatal("cache not empty after Clear")
+		}
+		cleared := 0
+		for _, reason := range evicted {
+			if reason == EvictionCleared {
+				cleared++
+			}
+		}
+		if cleared != len(resident) {
+			t.Fatalf("Clear reported %d entries, %d were resident", cleared, len(resident))
+		}
+	})
+}
+
+func TestConformance_TTL(t *testing.T) {
+	forEachCache(t, func(t *testing.T, newCache cacheFactory) {
+		clock := newFakeClock()
+		cache := newCache(8, WithTTL[int, int](time.Minute), WithClock[int, int](clock))
+		cache.Put(1, 1)
+		cache.PutWithTTL(2, 2, time.Second)
+		cache.PutWithTTL(3, 3, 0)
+		cache.Put(4, 4)
+
+		clock.Advance(time.Second)
+		if _, ok := cache.Get(2); ok {
+			t.Fatal("2 should have expired")
+		}
+		if _, ok := cache.Get(1); !ok {
+			t.Fatal("1 should live for the default TTL")
+		}
+
+		clock.Advance(time.Minute)
+		if removed := cache.RemoveExpired(); removed != 2 {
+			t.Fatalf("RemoveExpired() = %d, want 2", removed)
+		}
+		if keys := cache.Keys(); len(keys) != 1 || keys[0] != 3 {
+			t.Fatalf("Keys() = %v, want [3]", keys)
+		}
+		if stats := cache.Stats(); stats.Expirations != 3 {
+			t.Fatalf("Expirations = %d, want 3", stats.Expirations)
+		}
+	})
+}
+
+func TestConformance_HotSetSurvivesScan(t *testing.T) {
+	// Strict LRU loses the hot set to a long scan; every other policy keeps
+	// at least part of it
+	const capacity = 100
+	hitsAfterScan := make(map[string]int)
+	for _, factory := range cacheFactories {
+		cache := factory.new(capacity)
+		for round := 0; round < 5; round++ {
+			for key := 0; key < capacity/2; key++ {
+				if _, ok := cache.Get(key); !ok {
+					cache.Put(key, key)
+				}
+			}
+		}
+		for key := 1000; key < 1000+3*capacity; key++ {
+			if _, ok := cache.Get(key); !ok {
+				cache.Put(key, key)
+			}
+		}
+		for key := 0; key < capacity/2; key++ {
+			if _, ok := cache.Get(key); ok {
+				hitsAfterScan[factory.name]++
+			}
+		}
+	}
+
+	if hitsAfterScan["LRU"] != 0 {
+		t.Fatalf("LRU kept %d hot keys through the scan", hitsAfterScan["LRU"])
+	}
+	for _, name := range []string{"LFU", "ARC", "2Q", "TinyLFU"} {
+		if hitsAfterScan[name] < capacity/4 {
+			t.Errorf("%s kept only %d of %d hot keys: %v", name, hitsAfterScan[name], capacity/2, hitsAfterScan)
+		}
+	}
+}
+
+func TestLFUCache_EvictsLeastFrequent(t *testing.T) {
+	cache := NewLFUCache[string, int](3)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Put("c", 3)
+	cache.Get("a")
+	cache.Get("a")
+	cache.Get("c")
+	cache.Put("d", 4) // b was used least
+
+	if _, ok := cache.Get("b"); ok {
+		t.Fatal("b should have been evicted")
+	}
+	if got := fmt.Sprint(cache.Keys()); got != "[a c d]" {
+		t.Fatalf("Keys() = %s, want [a c d]", got)
+	}
+}
+
+func TestLFUCache_MinFrequencyAfterExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewLFUCache[string, int](2, WithClock[string, int](clock))
+	cache.PutWithTTL("once", 1, time.Second)
+	cache.Put("often", 2)
+	for i := 0; i < 5; i++ {
+		cache.Get("often")
+	}
+	clock.Advance(time.Second)
+	cache.RemoveExpired()
+
+	// The lowest remaining count is now 6; eviction must still find it
+	cache.Put("x", 3)
+	cache.Put("y", 4)
+	if _, ok := cache.Get("often"); !ok {
+		t.Fatal("the frequently used key should survive")
+	}
+	if cache.Size() != 2 {
+		t.Fatalf("Size() = %d, want 2", cache.Size())
+	}
+}
+
+func TestARCCache_GhostHitAdapts(t *testing.T) {
+	cache := NewARCCache[int, int](4)
+	for i := 0; i < 8; i++ {
+		cache.Put(i, i) // 0-3 are evicted to the b1 ghost list
+	}
+	if cache.p != 0 {
+		t.Fatalf("p = %d before any ghost hit", cache.p)
+	}
+	cache.Put(0, 0)
+	if cache.p == 0 {
+		t.Fatal("a b1 ghost hit should grow the recency target")
+	}
+	if cache.t2.len != 1 {
+		t.Fatal("a key returning from a ghost list belongs in t2")
+	}
+	if len(cache.ghosts) > cache.capacity {
+		t.Fatalf("%d ghosts exceed capacity", len(cache.ghosts))
+	}
+}
+
+func TestTwoQueueCache_PromotesRememberedKeys(t *testing.T) {
+	cache := NewTwoQueueCache[int, int](8)
+	for i := 0; i < 12; i++ {
+		cache.Put(i, i)
+	}
+	// The oldest keys were evicted from the recent FIFO but are remembered
+	cache.Put(0, 0)
+	if cache.items[0].list != &cache.frequent {
+		t.Fatal("a remembered key should go straight to the frequent LRU")
+	}
+}
+
+func TestCountMinSketch_Estimates(t *testing.T) {
+	sketch := newCountMinSketch(64)
+	hash := DefaultHashFunc[int]()
+	for i := 0; i < 10; i++ {
+		sketch.increment(hash(1))
+	}
+	sketch.increment(hash(2))
+
+	if got := sketch.estimate(hash(1)); got != 10 {
+		t.Fatalf("estimate(1) = %d, want 10", got)
+	}
+	if got := sketch.estimate(hash(3)); got > 1 {
+		t.Fatalf("estimate(3) = %d for an unseen key", got)
+	}
+
+	for i := 0; i < 100; i++ {
+		sketch.increment(hash(1))
+	}
+	if got := sketch.estimate(hash(1)); got != sketchMaxCount {
+		t.Fatalf("estimate should saturate at %d, got %d", sketchMaxCount, got)
+	}
+	sketch.age()
+	if got := sketch.estimate(hash(1)); got != sketchMaxCount/2 {
+		t.Fatalf("aging should halve the counters, got %d", got)
+	}
+}
+
+func TestTinyLFUCache_RejectsColdCandidates(t *testing.T) {
+	cache := NewTinyLFUCache[int, int](100)
+	for round := 0; round < 3; round++ {
+		for key := 0; key < 99; key++ {
+			if _, ok := cache.Get(key); !ok {
+				cache.Put(key, key)
+			}
+		}
+	}
+	for key := 1000; key < 1100; key++ {
+		cache.Put(key, key)
+	}
+
+	keys := cache.Keys()
+	sort.Ints(keys)
+	cold := 0
+	for _, key := range keys {
+		if key >= 1000 {
+			cold++
+		}
+	}
+	if cold > cache.windowCap {
+		t.Fatalf("%d one-off keys got past the admission filter", cold)
+	}
+}
+
+func BenchmarkPolicies(b *testing.B) {
+	keys := benchKeys()
+	for _, factory := range cacheFactories {
+		b.Run(factory.name, func(b *testing.B) {
+			cache := factory.new(benchCapacity)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := keys[i%len(keys)]
+				if _, ok := cache.Get(key); !ok {
+					cache.Put(key, i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package lrucache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the cache the current time; tests swap in a fake one
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock reads the wall clock
+type systemClock struct{}
+
+// Now returns time.Now()
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// cacheOptions holds the settings shared by every cache type
+type cacheOptions[K comparable, V any] struct {
+	ttl       time.Duration
+	clock     Clock
+	onEvict   func(key K, value V, reason EvictionReason)
+	weigher   func(key K, value V) int64
+	maxWeight int64
+}
+
+// Option configures a cache with keys of type K and values of type V. The
+// types are part of the option so that callbacks such as WithOnEvict are
+// checked against the cache at compile time.
+type Option[K comparable, V any] func(*cacheOptions[K, V])
+
+// WithTTL expires entries stored with Put after ttl; zero keeps them until evicted
+func WithTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
+	return func(o *cacheOptions[K, V]) {
+		o.ttl = ttl
+	}
+}
+
+// WithClock replaces the wall clock used for expiry
+func WithClock[K comparable, V any](clock Clock) Option[K, V] {
+	return func(o *cacheOptions[K, V]) {
+		o.clock = clock
+	}
+}
+
+// newCacheOptions applies opts over the defaults
+func newCacheOptions[K comparable, V any](opts []Option[K, V]) cacheOptions[K, V] {
+	o := cacheOptions[K, V]{clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Expirer is a cache whose expired entries can be reclaimed in bulk
+type Expirer interface {
+	RemoveExpired() int
+}
+
+// Janitor periodically reclaims expired entries in the background. It must
+// only be used with a thread-safe cache such as SyncLRUCache or
+// ShardedLRUCache.
+type Janitor struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// StartJanitor calls cache.RemoveExpired every interval until Stop is called
+func StartJanitor(cache Expirer, interval time.Duration) *Janitor {
+	if interval <= 0 {
+		panic("janitor interval must be positive")
+	}
+	j := &Janitor{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-j.stop:
+				return
+			case <-ticker.C:
+				cache.RemoveExpired()
+			}
+		}
+	}()
+	return j
+}
+
+// Stop ends the janitor and waits for a running sweep to finish; it is safe
+// to call more than once
+func (j *Janitor) Stop() {
+	j.once.Do(func() { close(j.stop) })
+	<-j.done
+}
@@ -0,0 +1,155 @@
+package lrucache
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when told to
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestLRUCache_PutWithTTLExpiresLazily(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewLRUCache[string, int](4, WithClock[string, int](clock))
+	cache.PutWithTTL("short", 1, time.Second)
+	cache.Put("forever", 2)
+
+	clock.Advance(999 * time.Millisecond)
+	if _, ok := cache.Get("short"); !ok {
+		t.Fatal("entry should live until its TTL has passed")
+	}
+	clock.Advance(time.Millisecond)
+	if _, ok := cache.Get("short"); ok {
+		t.Fatal("entry should expire once its TTL has passed")
+	}
+	if cache.Size() != 1 {
+		t.Fatalf("Size() = %d, the expired entry should be removed by Get", cache.Size())
+	}
+
+	clock.Advance(time.Hour)
+	if v, ok := cache.Get("forever"); !ok || v != 2 {
+		t.Fatal("entries without a TTL should never expire")
+	}
+}
+
+func TestLRUCache_DefaultTTL(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewLRUCache[string, int](4, WithTTL[string, int](time.Minute), WithClock[string, int](clock))
+	cache.Put("a", 1)
+	cache.PutWithTTL("b", 2, 0) // opt out of the default
+
+	clock.Advance(30 * time.Second)
+	cache.Put("a", 10) // an update restarts the TTL
+
+	clock.Advance(45 * time.Second)
+	if v, ok := cache.Get("a"); !ok || v != 10 {
+		t.Fatalf("Get(a) = %v, %v; the update should have refreshed the TTL", v, ok)
+	}
+	clock.Advance(15 * time.Second)
+	if _, ok := cache.Get("a"); ok {
+		t.Fatal("a should have expired a minute after its last Put")
+	}
+	if _, ok := cache.Get("b"); !ok {
+		t.Fatal("b was stored without a TTL")
+	}
+}
+
+func TestLRUCache_RemoveExpired(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewLRUCache[int, int](8, WithClock[int, int](clock))
+	for i := 0; i < 6; i++ {
+		cache.PutWithTTL(i, i, time.Duration(i+1)*time.Second)
+	}
+
+	clock.Advance(3 * time.Second)
+	if removed := cache.RemoveExpired(); removed != 3 {
+		t.Fatalf("RemoveExpired() = %d, want 3", removed)
+	}
+	if got, want := cache.Keys(), []int{5, 4, 3}; !slices.Equal(got, want) {
+		t.Fatalf("Keys() = %v, want %v", got, want)
+	}
+}
+
+func TestLRUCache_ReusedNodeDropsOldTTL(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewLRUCache[int, int](1, WithClock[int, int](clock))
+	cache.PutWithTTL(1, 1, time.Second)
+	cache.Put(2, 2) // evicts 1 and reuses its node
+
+	clock.Advance(time.Hour)
+	if _, ok := cache.Get(2); !ok {
+		t.Fatal("2 was stored without a TTL")
+	}
+}
+
+func TestJanitor_ReclaimsAndStops(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewShardedLRUCache[int, int](64, 4, nil, WithTTL[int, int](time.Second), WithClock[int, int](clock))
+	for i := 0; i < 32; i++ {
+		cache.Put(i, i)
+	}
+	clock.Advance(time.Second)
+
+	janitor := StartJanitor(cache, time.Millisecond)
+	deadline := time.Now().Add(2 * time.Second)
+	for cache.Size() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor left %d expired entries", cache.Size())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	janitor.Stop()
+	janitor.Stop()
+
+	// After Stop nothing is reclaimed any more
+	cache.PutWithTTL(100, 100, time.Nanosecond)
+	clock.Advance(time.Second)
+	time.Sleep(5 * time.Millisecond)
+	if cache.Size() != 1 {
+		t.Fatal("a stopped janitor should not touch the cache")
+	}
+}
+
+func TestSyncLRUCache_ExpiryUnderConcurrency(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewSyncLRUCache[int, int](128, WithTTL[int, int](time.Second), WithClock[int, int](clock))
+	janitor := StartJanitor(cache, time.Millisecond)
+	defer janitor.Stop()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				cache.Put(g*1000+i%100, i)
+				cache.Get(g*1000 + (i+50)%100)
+				if i%100 == 0 {
+					clock.Advance(100 * time.Millisecond)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
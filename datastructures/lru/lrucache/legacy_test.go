@@ -0,0 +1,152 @@
+package lrucache
+
+import "fmt"
+
+// legacyNode represents a node in the doubly linked list
+type legacyNode struct {
+	Key, Value interface{}
+	Prev, Next *legacyNode
+}
+
+// legacyLRUCache is the interface{}-based cache that LRUCache replaced,
+// kept to benchmark the generic version against
+type legacyLRUCache struct {
+	capacity int
+	cache    map[interface{}]*legacyNode
+	head     *legacyNode // Most recently used
+	tail     *legacyNode // Least recently used
+}
+
+// newLegacyLRUCache creates a new LRU cache with the given capacity
+func newLegacyLRUCache(capacity int) *legacyLRUCache {
+	if capacity <= 0 {
+		panic("capacity must be positive")
+	}
+
+	// Create dummy head and tail nodes
+	head := &legacyNode{}
+	tail := &legacyNode{}
+	head.Next = tail
+	tail.Prev = head
+
+	return &legacyLRUCache{
+		capacity: capacity,
+		cache:    make(map[interface{}]*legacyNode),
+		head:     head,
+		tail:     tail,
+	}
+}
+
+// addNode adds a node right after head
+func (lru *legacyLRUCache) addNode(node *legacyNode) {
+	node.Prev = lru.head
+	node.Next = lru.head.Next
+
+	lru.head.Next.Prev = node
+	lru.head.Next = node
+}
+
+// removeNode removes an existing node from the linked list
+func (lru *legacyLRUCache) removeNode(node *legacyNode) {
+	prevNode := node.Prev
+	nextNode := node.Next
+
+	prevNode.Next = nextNode
+	nextNode.Prev = prevNode
+}
+
+// moveToHead moves certain node to the head
+func (lru *legacyLRUCache) moveToHead(node *legacyNode) {
+	lru.removeNode(node)
+	lru.addNode(node)
+}
+
+// popTail removes the last node (LRU node)
+func (lru *legacyLRUCache) popTail() *legacyNode {
+	lastNode := lru.tail.Prev
+	lru.removeNode(lastNode)
+	return lastNode
+}
+
+// Get retrieves a value by key
+func (lru *legacyLRUCache) Get(key interface{}) (interface{}, bool) {
+	node, exists := lru.cache[key]
+	if !exists {
+		return nil, false
+	}
+
+	// Move the accessed node to the head (mark as recently used)
+	lru.moveToHead(node)
+
+	return node.Value, true
+}
+
+// Put sets a key-value pair in the cache
+func (lru *legacyLRUCache) Put(key, value interface{}) {
+	node, exists := lru.cache[key]
+
+	if exists {
+		// Update the value and move to head
+		node.Value = value
+		lru.moveToHead(node)
+		return
+	}
+
+	// Create new node
+	newNode := &legacyNode{
+		Key:   key,
+		Value: value,
+	}
+
+	if len(lru.cache) >= lru.capacity {
+		// Remove LRU node
+		tail := lru.popTail()
+		delete(lru.cache, tail.Key)
+	}
+
+	// Add new node
+	lru.addNode(newNode)
+	lru.cache[key] = newNode
+}
+
+// Size returns the current size of the cache
+func (lru *legacyLRUCache) Size() int {
+	return len(lru.cache)
+}
+
+// Capacity returns the capacity of the cache
+func (lru *legacyLRUCache) Capacity() int {
+	return lru.capacity
+}
+
+// Keys returns all keys in order from most recent to least recent
+func (lru *legacyLRUCache) Keys() []interface{} {
+	keys := make([]interface{}, 0, len(lru.cache))
+	current := lru.head.Next
+	for current != lru.tail {
+		keys = append(keys, current.Key)
+		current = current.Next
+	}
+	return keys
+}
+
+// Clear removes all entries from the cache
+func (lru *legacyLRUCache) Clear() {
+	lru.cache = make(map[interface{}]*legacyNode)
+	lru.head.Next = lru.tail
+	lru.tail.Prev = lru.head
+}
+
+// String returns a string representation of the cache
+func (lru *legacyLRUCache) String() string {
+	keys := lru.Keys()
+	result := fmt.Sprintf("legacyLRUCache{capacity: %d, size: %d, keys: [", lru.capacity, len(lru.cache))
+	for i, key := range keys {
+		if i > 0 {
+			result += ", "
+		}
+		result += fmt.Sprintf("%v", key)
+	}
+	result += "]}"
+	return result
+}
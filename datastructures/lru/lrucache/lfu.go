@@ -0,0 +1,170 @@
+package lrucache
+
+import (
+	"sort"
+	"time"
+)
+
+// LFUCache evicts the least frequently used entry, breaking ties by
+// recency. All operations are O(1).
+type LFUCache[K comparable, V any] struct {
+	cacheBase[K, V]
+	items   map[K]*entry[K, V]
+	buckets map[int]*entryList[K, V] // entries by access count
+	minFreq int
+}
+
+// NewLFUCache creates a new LFU cache with the given capacity
+func NewLFUCache[K comparable, V any](capacity int, opts ...Option[K, V]) *LFUCache[K, V] {
+	return &LFUCache[K, V]{
+		cacheBase: newCacheBase[K, V](capacity, opts, false),
+		items:     make(map[K]*entry[K, V]),
+		buckets:   make(map[int]*entryList[K, V]),
+	}
+}
+
+// bucket returns the list of entries used freq times, creating it if needed
+func (c *LFUCache[K, V]) bucket(freq int) *entryList[K, V] {
+	list, ok := c.buckets[freq]
+	if !ok {
+		list = &entryList[K, V]{}
+		c.buckets[freq] = list
+	}
+	return list
+}
+
+// unlink removes e from its bucket, dropping the bucket once empty
+func (c *LFUCache[K, V]) unlink(e *entry[K, V]) {
+	list := e.list
+	list.remove(e)
+	if list.len == 0 {
+		delete(c.buckets, e.freq)
+	}
+}
+
+// touch counts an access to e
+func (c *LFUCache[K, V]) touch(e *entry[K, V]) {
+	c.unlink(e)
+	if _, ok := c.buckets[c.minFreq]; !ok && c.minFreq == e.freq {
+		c.minFreq++
+	}
+	e.freq++
+	c.bucket(e.freq).pushFront(e)
+}
+
+// drop removes e from the cache; callers other than Put must fix minFreq
+func (c *LFUCache[K, V]) drop(e *entry[K, V], reason EvictionReason) {
+	c.unlink(e)
+	delete(c.items, e.key)
+	c.evicted(e.key, e.value, reason)
+}
+
+// expire drops an expired entry. Expiry can empty any bucket, so the
+// minimum count is searched again when its bucket went away.
+func (c *LFUCache[K, V]) expire(e *entry[K, V]) {
+	c.stats.Expirations++
+	c.drop(e, EvictionExpired)
+	if _, ok := c.buckets[c.minFreq]; ok {
+		return
+	}
+	c.minFreq = 0
+	for freq := range c.buckets {
+		if c.minFreq == 0 || freq < c.minFreq {
+			c.minFreq = freq
+		}
+	}
+}
+
+// Get retrieves a value by key and counts the access
+func (c *LFUCache[K, V]) Get(key K) (V, bool) {
+	e, ok := c.items[key]
+	if ok && c.expired(e.expiresAt) {
+		c.expire(e)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		var zero V
+		return zero, false
+	}
+	c.stats.Hits++
+	c.touch(e)
+	return e.value, true
+}
+
+// Put sets a key-value pair in the cache, expiring after the default TTL
+func (c *LFUCache[K, V]) Put(key K, value V) {
+	c.PutWithTTL(key, value, c.ttl)
+}
+
+// PutWithTTL sets a key-value pair that expires after ttl; an update counts
+// as an access
+func (c *LFUCache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) {
+	expiresAt := c.deadline(ttl)
+	if e, ok := c.items[key]; ok {
+		old := e.value
+		e.value, e.expiresAt = value, expiresAt
+		c.touch(e)
+		c.stats.Updates++
+		c.evicted(key, old, EvictionReplaced)
+		return
+	}
+
+	c.stats.Insertions++
+	if len(c.items) >= c.capacity {
+		c.stats.Evictions++
+		c.drop(c.buckets[c.minFreq].back(), EvictionCapacity)
+	}
+	e := &entry[K, V]{key: key, value: value, expiresAt: expiresAt, freq: 1}
+	c.items[key] = e
+	c.bucket(1).pushFront(e)
+	c.minFreq = 1
+}
+
+// RemoveExpired removes every expired entry and returns how many there were
+func (c *LFUCache[K, V]) RemoveExpired() int {
+	removed := 0
+	for _, e := range c.items {
+		if c.expired(e.expiresAt) {
+			c.expire(e)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Size returns the current size of the cache
+func (c *LFUCache[K, V]) Size() int {
+	return len(c.items)
+}
+
+// Keys returns all keys from most to least frequently used, most recent
+// first within the same count
+func (c *LFUCache[K, V]) Keys() []K {
+	freqs := make([]int, 0, len(c.buckets))
+	for freq := range c.buckets {
+		freqs = append(freqs, freq)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(freqs)))
+
+	keys := make([]K, 0, len(c.items))
+	for _, freq := range freqs {
+		keys = c.buckets[freq].appendKeys(keys)
+	}
+	return keys
+}
+
+// Clear removes all entries from the cache
+func (c *LFUCache[K, V]) Clear() {
+	for _, list := range c.buckets {
+		list.clear(&c.cacheBase)
+	}
+	c.items = make(map[K]*entry[K, V])
+	c.buckets = make(map[int]*entryList[K, V])
+	c.minFreq = 0
+}
+
+// String returns a string representation of the cache
+func (c *LFUCache[K, V]) String() string {
+	return formatCache("LFUCache", c.capacity, c.Keys())
+}
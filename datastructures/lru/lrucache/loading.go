@@ -0,0 +1,331 @@
+package lrucache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LoaderFunc loads the value of a key that is not cached
+type LoaderFunc[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// loadingOptions holds the settings of a LoadingCache
+type loadingOptions struct {
+	clock        Clock
+	errorTTL     time.Duration
+	refreshAfter time.Duration
+	refreshRate  float64
+	refreshBurst int
+}
+
+// LoadingOption configures a LoadingCache. It is a type of its own so that
+// options of the wrapped cache, such as WithTTL, cannot be passed to
+// NewLoadingCache by mistake, nor loading options to a plain cache.
+type LoadingOption func(*loadingOptions)
+
+// WithLoadingClock replaces the wall clock used for cached errors and refreshes
+func WithLoadingClock(clock Clock) LoadingOption {
+	return func(o *loadingOptions) {
+		o.clock = clock
+	}
+}
+
+// WithErrorTTL makes a LoadingCache remember failed loads for ttl, so that
+// callers get the error back without hitting the backend again
+func WithErrorTTL(ttl time.Duration) LoadingOption {
+	return func(o *loadingOptions) {
+		o.errorTTL = ttl
+	}
+}
+
+// WithRefreshAfter turns on stale-while-revalidate in a LoadingCache: a hit
+// on an entry loaded more than after ago still returns the cached value at
+// once, and reloads it in the background. Combine it with a longer WithTTL on
+// the wrapped cache to bound how stale a value can get. A failed refresh is
+// retried after the WithErrorTTL duration, or after another after without it.
+func WithRefreshAfter(after time.Duration) LoadingOption {
+	return func(o *loadingOptions) {
+		o.refreshAfter = after
+	}
+}
+
+// WithRefreshRateLimit allows at most perSecond background refreshes per
+// second, with bursts of up to burst; stale hits over the limit skip the
+// refresh and try again on a later hit
+func WithRefreshRateLimit(perSecond float64, burst int) LoadingOption {
+	return func(o *loadingOptions) {
+		o.refreshRate = perSecond
+		o.refreshBurst = burst
+	}
+}
+
+// LoadStats counts what a LoadingCache did on misses and refreshes
+type LoadStats struct {
+	Loads              uint64 // loader calls, refreshes included
+	Failures           uint64 // loader calls that returned an error
+	Shared             uint64 // misses that waited for a load started by another caller
+	CachedErrors       uint64 // misses answered with a remembered error
+	StaleHits          uint64 // hits served while past their refresh point
+	Refreshes          uint64 // background refreshes started
+	ThrottledRefreshes uint64 // refreshes skipped by the rate limit
+}
+
+// refreshLimiter is a token bucket driven by the cache's clock
+type refreshLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token if one is available at now
+func (l *refreshLimiter) allow(now time.Time) bool {
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// loadCall is a load in progress that callers of the same key wait for
+type loadCall[V any] struct {
+	done    chan struct{}
+	value   V
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+	refresh bool // a background refresh of a cached value
+}
+
+// cachedError is a remembered failed load
+type cachedError struct {
+	err       error
+	expiresAt time.Time
+}
+
+// LoadingCache fills a cache on misses through GetOrLoad. Concurrent misses
+// for one key share a single loader call.
+type LoadingCache[K comparable, V any] struct {
+	cache        Cache[K, V]
+	errorTTL     time.Duration
+	refreshAfter time.Duration
+	clock        Clock
+
+	mutex     sync.Mutex
+	calls     map[K]*loadCall[V]
+	errors    map[K]cachedError
+	refreshAt map[K]time.Time // when each loaded value should be refreshed
+	limiter   *refreshLimiter // nil when refreshes are not rate limited
+	stats     LoadStats
+}
+
+// NewLoadingCache wraps cache, which must be safe for concurrent use such as
+// SyncLRUCache or ShardedLRUCache.
+func NewLoadingCache[K comparable, V any](cache Cache[K, V], opts ...LoadingOption) *LoadingCache[K, V] {
+	options := loadingOptions{clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	lc := &LoadingCache[K, V]{
+		cache:        cache,
+		errorTTL:     options.errorTTL,
+		refreshAfter: options.refreshAfter,
+		clock:        options.clock,
+		calls:        make(map[K]*loadCall[V]),
+		errors:       make(map[K]cachedError),
+		refreshAt:    make(map[K]time.Time),
+	}
+	if options.refreshRate > 0 {
+		burst := float64(max(1, options.refreshBurst))
+		lc.limiter = &refreshLimiter{rate: options.refreshRate, burst: burst, tokens: burst}
+	}
+	return lc
+}
+
+// Cache returns the wrapped cache
+func (lc *LoadingCache[K, V]) Cache() Cache[K, V] {
+	return lc.cache
+}
+
+// GetOrLoad returns the cached value of key, calling loader on a miss and
+// caching its result. Callers missing the same key at the same time share
+// one loader call. A caller whose ctx ends stops waiting with ctx.Err();
+// the load itself is only cancelled once every waiting caller has left.
+// With WithRefreshAfter, a stale hit also starts a background refresh.
+func (lc *LoadingCache[K, V]) GetOrLoad(ctx context.Context, key K, loader LoaderFunc[K, V]) (V, error) {
+	if value, ok := lc.cache.Get(key); ok {
+		if lc.refreshAfter > 0 {
+			lc.refreshIfStale(ctx, key, loader)
+		}
+		return value, nil
+	}
+
+	lc.mutex.Lock()
+	if cached, ok := lc.errors[key]; ok {
+		if lc.clock.Now().Before(cached.expiresAt) {
+			lc.stats.CachedErrors++
+			lc.mutex.Unlock()
+			var zero V
+			return zero, cached.err
+		}
+		delete(lc.errors, key)
+	}
+	call, ok := lc.calls[key]
+	if ok {
+		lc.stats.Shared++
+	} else {
+		// The load must outlive the caller that happened to start it
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &loadCall[V]{done: make(chan struct{}), cancel: cancel}
+		lc.calls[key] = call
+		lc.stats.Loads++
+		go lc.load(loadCtx, key, loader, call)
+	}
+	call.waiters++
+	lc.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		lc.mutex.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Abandoned: cancel it and let the next miss start afresh
+			call.cancel()
+			if lc.calls[key] == call {
+				delete(lc.calls, key)
+			}
+		}
+		lc.mutex.Unlock()
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// refreshIfStale starts a background refresh of a cached key past its
+// refresh point, unless one is running or the rate limit is reached
+func (lc *LoadingCache[K, V]) refreshIfStale(ctx context.Context, key K, loader LoaderFunc[K, V]) {
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+
+	now := lc.clock.Now()
+	at, ok := lc.refreshAt[key]
+	if !ok || now.Before(at) {
+		return
+	}
+	lc.stats.StaleHits++
+	if _, running := lc.calls[key]; running {
+		return
+	}
+	if lc.limiter != nil && !lc.limiter.allow(now) {
+		lc.stats.ThrottledRefreshes++
+		return
+	}
+
+	loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	call := &loadCall[V]{done: make(chan struct{}), cancel: cancel, refresh: true}
+	lc.calls[key] = call
+	lc.stats.Loads++
+	lc.stats.Refreshes++
+	go lc.load(loadCtx, key, loader, call)
+}
+
+// load runs the loader and publishes its result to the waiting callers
+func (lc *LoadingCache[K, V]) load(ctx context.Context, key K, loader LoaderFunc[K, V], call *loadCall[V]) {
+	defer call.cancel()
+	call.value, call.err = callLoader(ctx, key, loader)
+	if call.err == nil {
+		// Cache before the call is removed, so no caller can miss both
+		lc.cache.Put(key, call.value)
+	}
+
+	lc.mutex.Lock()
+	if lc.calls[key] == call {
+		delete(lc.calls, key)
+	}
+	now := lc.clock.Now()
+	switch {
+	case call.err == nil:
+		if lc.refreshAfter > 0 {
+			lc.pruneRefreshAt()
+			lc.refreshAt[key] = now.Add(lc.refreshAfter)
+		}
+	case call.refresh:
+		// The stale value stays in use; back off like a failed load would,
+		// or for another refresh interval without an error TTL, so that
+		// every hit does not retry a failing backend
+		lc.stats.Failures++
+		backoff := lc.errorTTL
+		if backoff <= 0 {
+			backoff = lc.refreshAfter
+		}
+		lc.refreshAt[key] = now.Add(backoff)
+	default:
+		lc.stats.Failures++
+		// A load cancelled because nobody waits any more says nothing about the key
+		if lc.errorTTL > 0 && ctx.Err() == nil {
+			lc.pruneErrors()
+			lc.errors[key] = cachedError{err: call.err, expiresAt: now.Add(lc.errorTTL)}
+		}
+	}
+	lc.mutex.Unlock()
+	close(call.done)
+}
+
+// pruneRefreshAt forgets the refresh points of keys that have left the
+// cache once they clearly outnumber it; the caller holds the mutex
+func (lc *LoadingCache[K, V]) pruneRefreshAt() {
+	if len(lc.refreshAt) < 2*lc.cache.Capacity()+16 {
+		return
+	}
+	resident := make(map[K]time.Time, lc.cache.Size())
+	for _, key := range lc.cache.Keys() {
+		if at, ok := lc.refreshAt[key]; ok {
+			resident[key] = at
+		}
+	}
+	lc.refreshAt = resident
+}
+
+// callLoader turns a panicking loader into an error so waiters are released
+func callLoader[K comparable, V any](ctx context.Context, key K, loader LoaderFunc[K, V]) (value V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("loader for %v panicked: %v", key, r)
+		}
+	}()
+	return loader(ctx, key)
+}
+
+// pruneErrors drops expired errors once many have built up; the caller holds the mutex
+func (lc *LoadingCache[K, V]) pruneErrors() {
+	if len(lc.errors) < 1024 {
+		return
+	}
+	now := lc.clock.Now()
+	for key, cached := range lc.errors {
+		if !now.Before(cached.expiresAt) {
+			delete(lc.errors, key)
+		}
+	}
+}
+
+// Forget drops a remembered error so the next miss loads again
+func (lc *LoadingCache[K, V]) Forget(key K) {
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+	delete(lc.errors, key)
+}
+
+// LoadStats returns a snapshot of the load counters
+func (lc *LoadingCache[K, V]) LoadStats() LoadStats {
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+	return lc.stats
+}
@@ -0,0 +1,372 @@
+package lrucache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadingCache_LoadsOnceAndCaches(t *testing.T) {
+	lc := NewLoadingCache[string, string](NewSyncLRUCache[string, string](4))
+	var calls atomic.Int32
+	loader := func(ctx context.Context, key string) (string, error) {
+		calls.Add(1)
+		return "value of " + key, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		v, err := lc.GetOrLoad(context.Background(), "a", loader)
+		if err != nil || v != "value of a" {
+			t.Fatalf("GetOrLoad() = %q, %v", v, err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("loader called %d times, want 1", calls.Load())
+	}
+	if v, ok := lc.Cache().Get("a"); !ok || v != "value of a" {
+		t.Fatal("the loaded value should be in the wrapped cache")
+	}
+}
+
+func TestLoadingCache_CollapsesConcurrentLoads(t *testing.T) {
+	lc := NewLoadingCache[int, int](NewShardedLRUCache[int, int](64, 4, nil))
+	release := make(chan struct{})
+	var calls atomic.Int32
+	loader := func(ctx context.Context, key int) (int, error) {
+		calls.Add(1)
+		<-release
+		return key * 2, nil
+	}
+
+	const callers = 50
+	var wg sync.WaitGroup
+	results := make(chan int, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := lc.GetOrLoad(context.Background(), 21, loader)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- v
+		}()
+	}
+	// Let every caller reach the in-flight load before it finishes
+	for lc.LoadStats().Shared < callers-1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for v := range results {
+		if v != 42 {
+			t.Fatalf("caller got %d, want 42", v)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("loader called %d times for one key", calls.Load())
+	}
+	if stats := lc.LoadStats(); stats.Loads != 1 || stats.Shared != callers-1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestLoadingCache_ErrorsAreNotCachedByDefault(t *testing.T) {
+	lc := NewLoadingCache[string, int](NewSyncLRUCache[string, int](4))
+	errBackend := errors.New("backend down")
+	var calls int
+	loader := func(ctx context.Context, key string) (int, error) {
+		calls++
+		return 0, errBackend
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := lc.GetOrLoad(context.Background(), "a", loader); !errors.Is(err, errBackend) {
+			t.Fatalf("GetOrLoad() error = %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("loader called %d times, want 2", calls)
+	}
+	if lc.Cache().Size() != 0 {
+		t.Fatal("a failed load must not be cached as a value")
+	}
+}
+
+func TestLoadingCache_ErrorTTL(t *testing.T) {
+	clock := newFakeClock()
+	lc := NewLoadingCache[string, int](NewSyncLRUCache[string, int](4), WithErrorTTL(time.Second), WithLoadingClock(clock))
+	fail := true
+	var calls int
+	loader := func(ctx context.Context, key string) (int, error) {
+		calls++
+		if fail {
+			return 0, fmt.Errorf("load %s: unavailable", key)
+		}
+		return 7, nil
+	}
+
+	lc.GetOrLoad(context.Background(), "a", loader)
+	fail = false
+	if _, err := lc.GetOrLoad(context.Background(), "a", loader); err == nil {
+		t.Fatal("the error should be remembered for a second")
+	}
+	if calls != 1 || lc.LoadStats().CachedErrors != 1 {
+		t.Fatalf("calls = %d, stats %+v", calls, lc.LoadStats())
+	}
+
+	clock.Advance(time.Second)
+	if v, err := lc.GetOrLoad(context.Background(), "a", loader); err != nil || v != 7 {
+		t.Fatalf("after the error TTL GetOrLoad() = %d, %v", v, err)
+	}
+
+	fail = true
+	lc.GetOrLoad(context.Background(), "b", loader)
+	lc.Forget("b")
+	fail = false
+	if _, err := lc.GetOrLoad(context.Background(), "b", loader); err != nil {
+		t.Fatalf("Forget should clear the remembered error: %v", err)
+	}
+}
+
+func TestLoadingCache_WaiterCancellation(t *testing.T) {
+	lc := NewLoadingCache[string, string](NewSyncLRUCache[string, string](4))
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (string, error) {
+		select {
+		case <-release:
+			return "loaded", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	// The patient caller starts the load, the impatient one gives up early
+	patient := make(chan error, 1)
+	go func() {
+		_, err := lc.GetOrLoad(context.Background(), "a", loader)
+		patient <- err
+	}()
+	for lc.LoadStats().Loads == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := lc.GetOrLoad(ctx, "a", loader); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("impatient caller got %v", err)
+	}
+
+	// Its departure must not cancel the load for the patient caller
+	close(release)
+	if err := <-patient; err != nil {
+		t.Fatalf("patient caller got %v", err)
+	}
+	if v, ok := lc.Cache().Get("a"); !ok || v != "loaded" {
+		t.Fatal("the load should have completed and been cached")
+	}
+}
+
+func TestLoadingCache_AbandonedLoadIsCancelled(t *testing.T) {
+	lc := NewLoadingCache[string, string](NewSyncLRUCache[string, string](4), WithErrorTTL(time.Hour))
+	cancelled := make(chan struct{})
+	loader := func(ctx context.Context, key string) (string, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return "", ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for lc.LoadStats().Loads == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	if _, err := lc.GetOrLoad(ctx, "a", loader); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetOrLoad() error = %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the loader should be cancelled once nobody waits")
+	}
+
+	// The cancellation is not remembered as a failure of the key
+	v, err := lc.GetOrLoad(context.Background(), "a", func(context.Context, string) (string, error) {
+		return "fresh", nil
+	})
+	if err != nil || v != "fresh" {
+		t.Fatalf("GetOrLoad() = %q, %v", v, err)
+	}
+}
+
+func TestLoadingCache_LoaderPanic(t *testing.T) {
+	lc := NewLoadingCache[string, string](NewSyncLRUCache[string, string](4))
+	_, err := lc.GetOrLoad(context.Background(), "a", func(context.Context, string) (string, error) {
+		panic("boom")
+	})
+	if err == nil {
+		t.Fatal("a panicking loader should surface as an error")
+	}
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not reached in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLoadingCache_StaleWhileRevalidate(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewSyncLRUCache[string, int](4, WithTTL[string, int](time.Minute), WithClock[string, int](clock))
+	lc := NewLoadingCache[string, int](cache, WithRefreshAfter(10*time.Second), WithLoadingClock(clock))
+	release := make(chan struct{})
+	var version atomic.Int32
+	loader := func(ctx context.Context, key string) (int, error) {
+		if version.Add(1) > 1 {
+			<-release
+		}
+		return int(version.Load()), nil
+	}
+
+	if v, _ := lc.GetOrLoad(context.Background(), "a", loader); v != 1 {
+		t.Fatalf("first load = %d, want 1", v)
+	}
+	if v, _ := lc.GetOrLoad(context.Background(), "a", loader); v != 1 || lc.LoadStats().Refreshes != 0 {
+		t.Fatal("a fresh hit must not refresh")
+	}
+
+	// Past the refresh point every hit is served stale and only one refresh runs
+	clock.Advance(11 * time.Second)
+	for i := 0; i < 5; i++ {
+		if v, err := lc.GetOrLoad(context.Background(), "a", loader); err != nil || v != 1 {
+			t.Fatalf("stale hit = %d, %v; want the cached 1", v, err)
+		}
+	}
+	if stats := lc.LoadStats(); stats.Refreshes != 1 || stats.StaleHits != 5 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	close(release)
+	waitFor(t, func() bool {
+		v, _ := cache.Get("a")
+		return v == 2
+	})
+	if v, _ := lc.GetOrLoad(context.Background(), "a", loader); v != 2 {
+		t.Fatalf("after the refresh GetOrLoad() = %d, want 2", v)
+	}
+	if lc.LoadStats().Refreshes != 1 {
+		t.Fatal("the refreshed value should be fresh again")
+	}
+}
+
+func TestLoadingCache_RefreshRateLimit(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewSyncLRUCache[int, int](16, WithClock[int, int](clock))
+	lc := NewLoadingCache[int, int](cache, WithRefreshAfter(time.Second),
+		WithRefreshRateLimit(1, 2), WithLoadingClock(clock))
+	loader := func(ctx context.Context, key int) (int, error) {
+		return key, nil
+	}
+
+	for key := 0; key < 5; key++ {
+		lc.GetOrLoad(context.Background(), key, loader)
+	}
+	clock.Advance(2 * time.Second)
+	for key := 0; key < 5; key++ {
+		lc.GetOrLoad(context.Background(), key, loader)
+	}
+	waitFor(t, func() bool { return lc.LoadStats().Loads == 7 })
+	if stats := lc.LoadStats(); stats.Refreshes != 2 || stats.ThrottledRefreshes != 3 {
+		t.Fatalf("a burst of 2 should allow 2 refreshes: %+v", stats)
+	}
+
+	// A second later one more token is available
+	clock.Advance(time.Second)
+	for key := 2; key < 5; key++ {
+		lc.GetOrLoad(context.Background(), key, loader)
+	}
+	if stats := lc.LoadStats(); stats.Refreshes != 3 || stats.ThrottledRefreshes != 5 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestLoadingCache_FailedRefreshKeepsStaleValue(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewSyncLRUCache[string, string](4, WithClock[string, string](clock))
+	lc := NewLoadingCache[string, string](cache, WithRefreshAfter(time.Second),
+		WithErrorTTL(5*time.Second), WithLoadingClock(clock))
+	fail := false
+	loader := func(ctx context.Context, key string) (string, error) {
+		if fail {
+			return "", errors.New("backend down")
+		}
+		return "good", nil
+	}
+
+	lc.GetOrLoad(context.Background(), "a", loader)
+	fail = true
+	clock.Advance(2 * time.Second)
+	if v, err := lc.GetOrLoad(context.Background(), "a", loader); err != nil || v != "good" {
+		t.Fatalf("stale hit = %q, %v", v, err)
+	}
+	waitFor(t, func() bool { return lc.LoadStats().Failures == 1 })
+
+	// The failure backs off for the error TTL and keeps serving the old value
+	clock.Advance(time.Second)
+	if v, err := lc.GetOrLoad(context.Background(), "a", loader); err != nil || v != "good" {
+		t.Fatalf("GetOrLoad() = %q, %v after a failed refresh", v, err)
+	}
+	if stats := lc.LoadStats(); stats.Refreshes != 1 {
+		t.Fatalf("the refresh should back off: %+v", stats)
+	}
+	clock.Advance(5 * time.Second)
+	lc.GetOrLoad(context.Background(), "a", loader)
+	if stats := lc.LoadStats(); stats.Refreshes != 2 {
+		t.Fatalf("the refresh should be retried after the error TTL: %+v", stats)
+	}
+}
+
+func TestLoadingCache_FailedRefreshBacksOffWithoutErrorTTL(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewSyncLRUCache[string, string](4, WithClock[string, string](clock))
+	lc := NewLoadingCache[string, string](cache, WithRefreshAfter(time.Second),
+		WithLoadingClock(clock))
+	fail := false
+	loader := func(ctx context.Context, key string) (string, error) {
+		if fail {
+			return "", errors.New("backend down")
+		}
+		return "good", nil
+	}
+
+	lc.GetOrLoad(context.Background(), "a", loader)
+	fail = true
+	clock.Advance(2 * time.Second)
+	lc.GetOrLoad(context.Background(), "a", loader)
+	waitFor(t, func() bool { return lc.LoadStats().Failures == 1 })
+
+	// Without an error TTL the retry waits for another refresh interval
+	clock.Advance(500 * time.Millisecond)
+	lc.GetOrLoad(context.Background(), "a", loader)
+	if stats := lc.LoadStats(); stats.Refreshes != 1 {
+		t.Fatalf("the refresh should back off: %+v", stats)
+	}
+	clock.Advance(time.Second)
+	lc.GetOrLoad(context.Background(), "a", loader)
+	if stats := lc.LoadStats(); stats.Refreshes != 2 {
+		t.Fatalf("the refresh should be retried after the refresh interval: %+v", stats)
+	}
+}
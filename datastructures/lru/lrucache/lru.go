@@ -0,0 +1,241 @@
+// Package lrucache provides the generic LRU cache together with its thread-safe,
+// sharded, loading and slab variants and alternative eviction policies.
+package lrucache
+
+import (
+	"fmt"
+	"time"
+)
+
+// Node represents a node in the doubly linked list
+type Node[K comparable, V any] struct {
+	Key        K
+	Value      V
+	Prev, Next *Node[K, V]
+	expiresAt  time.Time // zero when the entry never expires
+	weight     int64
+}
+
+// LRUCache represents an LRU cache
+type LRUCache[K comparable, V any] struct {
+	cacheBase[K, V]
+	cache     map[K]*Node[K, V]
+	head      *Node[K, V] // Most recently used
+	tail      *Node[K, V] // Least recently used
+	weigher   func(key K, value V) int64
+	maxWeight int64
+	weight    int64 // total weight of the cached entries
+}
+
+// NewLRUCache creates a new LRU cache with the given capacity
+func NewLRUCache[K comparable, V any](capacity int, opts ...Option[K, V]) *LRUCache[K, V] {
+	base := newCacheBase[K, V](capacity, opts, true)
+	options := newCacheOptions(opts)
+
+	// Create dummy head and tail nodes
+	head := &Node[K, V]{}
+	tail := &Node[K, V]{}
+	head.Next = tail
+	tail.Prev = head
+
+	return &LRUCache[K, V]{
+		cacheBase: base,
+		cache:     make(map[K]*Node[K, V]),
+		head:      head,
+		tail:      tail,
+		weigher:   options.weigher,
+		maxWeight: options.maxWeight,
+	}
+}
+
+// addNode adds a node right after head
+func (lru *LRUCache[K, V]) addNode(node *Node[K, V]) {
+	node.Prev = lru.head
+	node.Next = lru.head.Next
+
+	lru.head.Next.Prev = node
+	lru.head.Next = node
+}
+
+// removeNode removes an existing node from the linked list
+func (lru *LRUCache[K, V]) removeNode(node *Node[K, V]) {
+	prevNode := node.Prev
+	nextNode := node.Next
+
+	prevNode.Next = nextNode
+	nextNode.Prev = prevNode
+}
+
+// moveToHead moves certain node to the head
+func (lru *LRUCache[K, V]) moveToHead(node *Node[K, V]) {
+	lru.removeNode(node)
+	lru.addNode(node)
+}
+
+// popTail removes the last node (LRU node)
+func (lru *LRUCache[K, V]) popTail() *Node[K, V] {
+	lastNode := lru.tail.Prev
+	lru.drop(lastNode)
+	return lastNode
+}
+
+// drop removes a node from the list and the map
+func (lru *LRUCache[K, V]) drop(node *Node[K, V]) {
+	lru.removeNode(node)
+	delete(lru.cache, node.Key)
+	lru.weight -= node.weight
+}
+
+// Get retrieves a value by key; the zero value is returned on a miss.
+// An expired entry is removed and reported as a miss.
+func (lru *LRUCache[K, V]) Get(key K) (V, bool) {
+	node, exists := lru.cache[key]
+	if exists && lru.expired(node.expiresAt) {
+		lru.drop(node)
+		lru.stats.Expirations++
+		lru.evicted(node.Key, node.Value, EvictionExpired)
+		exists = false
+	}
+	if !exists {
+		lru.stats.Misses++
+		var zero V
+		return zero, false
+	}
+
+	// Move the accessed node to the head (mark as recently used)
+	lru.moveToHead(node)
+	lru.stats.Hits++
+
+	return node.Value, true
+}
+
+// Put sets a key-value pair in the cache, expiring after the default TTL
+func (lru *LRUCache[K, V]) Put(key K, value V) {
+	lru.PutWithTTL(key, value, lru.ttl)
+}
+
+// PutWithTTL sets a key-value pair that expires after ttl; zero or a
+// negative ttl keeps it until evicted. An entry too heavy to ever fit is
+// dropped, see TryPutWithTTL.
+func (lru *LRUCache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) {
+	lru.TryPutWithTTL(key, value, ttl)
+}
+
+// TryPut is Put that reports an entry heavier than the maximum weight
+func (lru *LRUCache[K, V]) TryPut(key K, value V) error {
+	return lru.TryPutWithTTL(key, value, lru.ttl)
+}
+
+// TryPutWithTTL sets a key-value pair that expires after ttl, evicting
+// least recently used entries until it fits. An entry heavier than the
+// maximum weight is rejected with ErrEntryTooLarge, and any older value of
+// the key is removed so that Get does not return it.
+func (lru *LRUCache[K, V]) TryPutWithTTL(key K, value V, ttl time.Duration) error {
+	weight := lru.weigh(key, value)
+	node, exists := lru.cache[key]
+	if weight < 0 || (lru.weigher != nil && weight > lru.maxWeight) {
+		lru.stats.Rejections++
+		if exists {
+			lru.drop(node)
+			lru.evicted(key, node.Value, EvictionReplaced)
+		}
+		return fmt.Errorf("%w: %v weighs %d, the limit is %d", ErrEntryTooLarge, key, weight, lru.maxWeight)
+	}
+	expiresAt := lru.deadline(ttl)
+
+	if exists {
+		// Update the value and move to head
+		old := node.Value
+		node.Value = value
+		node.expiresAt = expiresAt
+		lru.weight += weight - node.weight
+		node.weight = weight
+		lru.moveToHead(node)
+		lru.stats.Updates++
+		lru.evicted(key, old, EvictionReplaced)
+		// A heavier value may push older entries out; the node itself fits
+		for lru.overweight(0) {
+			lru.evictTail()
+		}
+		return nil
+	}
+
+	lru.stats.Insertions++
+	var newNode *Node[K, V]
+	for len(lru.cache) >= lru.capacity || lru.overweight(weight) {
+		// Remove the LRU node and reuse it for the new entry
+		newNode = lru.evictTail()
+	}
+	if newNode != nil {
+		newNode.Key, newNode.Value = key, value
+		newNode.expiresAt = expiresAt
+		newNode.weight = weight
+	} else {
+		newNode = &Node[K, V]{Key: key, Value: value, expiresAt: expiresAt, weight: weight}
+	}
+
+	// Add new node
+	lru.addNode(newNode)
+	lru.cache[key] = newNode
+	lru.weight += weight
+	return nil
+}
+
+// evictTail removes the least recently used entry to make room
+func (lru *LRUCache[K, V]) evictTail() *Node[K, V] {
+	node := lru.popTail()
+	lru.stats.Evictions++
+	lru.evicted(node.Key, node.Value, EvictionCapacity)
+	return node
+}
+
+// RemoveExpired removes every expired entry and returns how many there were
+func (lru *LRUCache[K, V]) RemoveExpired() int {
+	removed := 0
+	for node := lru.head.Next; node != lru.tail; {
+		next := node.Next
+		if lru.expired(node.expiresAt) {
+			lru.drop(node)
+			lru.stats.Expirations++
+			lru.evicted(node.Key, node.Value, EvictionExpired)
+			removed++
+		}
+		node = next
+	}
+	return removed
+}
+
+// Size returns the current size of the cache. Expired entries count until
+// Get or RemoveExpired reclaims them.
+func (lru *LRUCache[K, V]) Size() int {
+	return len(lru.cache)
+}
+
+// Keys returns all keys in order from most recent to least recent
+func (lru *LRUCache[K, V]) Keys() []K {
+	keys := make([]K, 0, len(lru.cache))
+	current := lru.head.Next
+	for current != lru.tail {
+		keys = append(keys, current.Key)
+		current = current.Next
+	}
+	return keys
+}
+
+// Clear removes all entries from the cache
+func (lru *LRUCache[K, V]) Clear() {
+	if lru.onEvict != nil {
+		for node := lru.head.Next; node != lru.tail; node = node.Next {
+			lru.onEvict(node.Key, node.Value, EvictionCleared)
+		}
+	}
+	lru.cache = make(map[K]*Node[K, V])
+	lru.head.Next = lru.tail
+	lru.tail.Prev = lru.head
+	lru.weight = 0
+}
+
+// String returns a string representation of the cache
+func (lru *LRUCache[K, V]) String() string {
+	return formatCache("LRUCache", lru.capacity, lru.Keys())
+}
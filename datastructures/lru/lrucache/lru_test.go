@@ -0,0 +1,162 @@
+package lrucache
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestLRUCache_GetPut(t *testing.T) {
+	cache := NewLRUCache[string, int](2)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %v, %v; want 1, true", v, ok)
+	}
+	if v, ok := cache.Get("missing"); ok || v != 0 {
+		t.Fatalf("Get(missing) = %v, %v; want zero value, false", v, ok)
+	}
+
+	// b is now least recently used
+	cache.Put("c", 3)
+	if _, ok := cache.Get("b"); ok {
+		t.Fatal("b should have been evicted")
+	}
+	if got, want := cache.Keys(), []string{"c", "a"}; !slices.Equal(got, want) {
+		t.Fatalf("Keys() = %v, want %v", got, want)
+	}
+}
+
+func TestLRUCache_UpdateMovesToFront(t *testing.T) {
+	cache := NewLRUCache[int, string](3)
+	for i := 1; i <= 3; i++ {
+		cache.Put(i, fmt.Sprint(i))
+	}
+	cache.Put(1, "one")
+
+	if cache.Size() != 3 {
+		t.Fatalf("Size() = %d, want 3", cache.Size())
+	}
+	if got, want := cache.Keys(), []int{1, 3, 2}; !slices.Equal(got, want) {
+		t.Fatalf("Keys() = %v, want %v", got, want)
+	}
+	cache.Put(4, "four")
+	if _, ok := cache.Get(2); ok {
+		t.Fatal("2 should have been evicted")
+	}
+	if v, _ := cache.Get(1); v != "one" {
+		t.Fatalf("Get(1) = %q, want updated value", v)
+	}
+}
+
+func TestLRUCache_Clear(t *testing.T) {
+	cache := NewLRUCache[string, int](2)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Clear()
+
+	if cache.Size() != 0 || len(cache.Keys()) != 0 {
+		t.Fatalf("cache not empty after Clear: %s", cache)
+	}
+	cache.Put("c", 3)
+	if got := cache.String(); got != "LRUCache{capacity: 2, size: 1, keys: [c]}" {
+		t.Fatalf("String() = %q", got)
+	}
+}
+
+func TestNewLRUCache_PanicsOnInvalidCapacity(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for capacity 0")
+		}
+	}()
+	NewLRUCache[string, int](0)
+}
+
+func TestLRUCache_MatchesLegacy(t *testing.T) {
+	cache := NewLRUCache[int, int](16)
+	legacy := newLegacyLRUCache(16)
+	for i := 0; i < 1000; i++ {
+		key := (i * 7919) % 40
+		if i%3 == 0 {
+			cache.Put(key, i)
+			legacy.Put(key, i)
+			continue
+		}
+		v, ok := cache.Get(key)
+		lv, lok := legacy.Get(key)
+		if ok != lok || (ok && v != lv.(int)) {
+			t.Fatalf("step %d: Get(%d) = %v, %v; legacy %v, %v", i, key, v, ok, lv, lok)
+		}
+	}
+	legacyKeys := legacy.Keys()
+	for i, key := range cache.Keys() {
+		if legacyKeys[i].(int) != key {
+			t.Fatalf("key order differs: %v vs %v", cache.Keys(), legacyKeys)
+		}
+	}
+}
+
+const benchCapacity = 1024
+
+// benchKeys returns keys of which about 80% fit into a benchCapacity cache
+func benchKeys() []int {
+	keys := make([]int, 4096)
+	for i := range keys {
+		keys[i] = (i * 2654435761) % (benchCapacity * 5 / 4)
+	}
+	return keys
+}
+
+func BenchmarkLRUCache_Put(b *testing.B) {
+	cache := NewLRUCache[int, int](benchCapacity)
+	keys := benchKeys()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := keys[i%len(keys)]
+		cache.Put(key, i)
+	}
+}
+
+func BenchmarkLegacyLRUCache_Put(b *testing.B) {
+	cache := newLegacyLRUCache(benchCapacity)
+	keys := benchKeys()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := keys[i%len(keys)]
+		cache.Put(key, i)
+	}
+}
+
+func BenchmarkLRUCache_Get(b *testing.B) {
+	cache := NewLRUCache[int, int](benchCapacity)
+	keys := benchKeys()
+	for i, key := range keys {
+		cache.Put(key, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if v, ok := cache.Get(keys[i%len(keys)]); ok {
+			_ = v + 1
+		}
+	}
+}
+
+func BenchmarkLegacyLRUCache_Get(b *testing.B) {
+	cache := newLegacyLRUCache(benchCapacity)
+	keys := benchKeys()
+	for i, key := range keys {
+		cache.Put(key, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if v, ok := cache.Get(keys[i%len(keys)]); ok {
+			_ = v.(int) + 1
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package lrucache
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// SyncLRUCache is an LRUCache guarded by a single mutex
+type SyncLRUCache[K comparable, V any] struct {
+	mutex sync.Mutex
+	cache *LRUCache[K, V]
+}
+
+// NewSyncLRUCache creates a thread-safe LRU cache with the given capacity
+func NewSyncLRUCache[K comparable, V any](capacity int, opts ...Option[K, V]) *SyncLRUCache[K, V] {
+	return &SyncLRUCache[K, V]{cache: NewLRUCache[K, V](capacity, opts...)}
+}
+
+// Get retrieves a value by key
+func (c *SyncLRUCache[K, V]) Get(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cache.Get(key)
+}
+
+// Put sets a key-value pair in the cache
+func (c *SyncLRUCache[K, V]) Put(key K, value V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cache.Put(key, value)
+}
+
+// PutWithTTL sets a key-value pair that expires after ttl
+func (c *SyncLRUCache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cache.PutWithTTL(key, value, ttl)
+}
+
+// TryPut sets a key-value pair, reporting entries that are too heavy
+func (c *SyncLRUCache[K, V]) TryPut(key K, value V) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cache.TryPut(key, value)
+}
+
+// Weight returns the total weight of the cached entries
+func (c *SyncLRUCache[K, V]) Weight() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cache.Weight()
+}
+
+// RemoveExpired removes every expired entry and returns how many there were
+func (c *SyncLRUCache[K, V]) RemoveExpired() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cache.RemoveExpired()
+}
+
+// Stats returns a snapshot of the cache's counters
+func (c *SyncLRUCache[K, V]) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cache.Stats()
+}
+
+// Size returns the current size of the cache
+func (c *SyncLRUCache[K, V]) Size() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cache.Size()
+}
+
+// Capacity returns the capacity of the cache
+func (c *SyncLRUCache[K, V]) Capacity() int {
+	return c.cache.Capacity()
+}
+
+// Keys returns all keys in order from most recent to least recent
+func (c *SyncLRUCache[K, V]) Keys() []K {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cache.Keys()
+}
+
+// Clear removes all entries from the cache
+func (c *SyncLRUCache[K, V]) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cache.Clear()
+}
+
+// String returns a string representation of the cache
+func (c *SyncLRUCache[K, V]) String() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cache.String()
+}
+
+// HashFunc maps a key to the shard that owns it
+type HashFunc[K comparable] func(key K) uint64
+
+// lruShard is one independently locked part of a ShardedLRUCache
+type lruShard[K comparable, V any] struct {
+	mutex sync.Mutex
+	cache *LRUCache[K, V]
+}
+
+// ShardedLRUCache spreads keys over independently locked LRU shards, so
+// goroutines working on different keys rarely contend. Eviction is LRU
+// within each shard, which approximates global LRU order when keys hash
+// evenly.
+type ShardedLRUCache[K comparable, V any] struct {
+	shards   []*lruShard[K, V]
+	mask     uint64
+	hash     HashFunc[K]
+	capacity int
+}
+
+// NewShardedLRUCache creates a cache of the given total capacity split over
+// shards shards, rounded up to a power of two and limited to capacity.
+// A nil hash uses DefaultHashFunc. A maximum weight is split evenly too, so
+// an entry heavier than one shard's share is rejected.
+func NewShardedLRUCache[K comparable, V any](capacity, shards int, hash HashFunc[K], opts ...Option[K, V]) *ShardedLRUCache[K, V] {
+	if capacity <= 0 {
+		panic("capacity must be positive")
+	}
+	if shards <= 0 {
+		panic("shard count must be positive")
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	// Every shard holds at least one entry
+	for n > capacity {
+		n >>= 1
+	}
+	if hash == nil {
+		hash = DefaultHashFunc[K]()
+	}
+
+	c := &ShardedLRUCache[K, V]{
+		shards:   make([]*lruShard[K, V], n),
+		mask:     uint64(n - 1),
+		hash:     hash,
+		capacity: capacity,
+	}
+	if maxWeight := newCacheOptions(opts).maxWeight; maxWeight > 0 {
+		share := max(1, maxWeight/int64(n))
+		opts = append(opts[:len(opts):len(opts)], func(o *cacheOptions[K, V]) { o.maxWeight = share })
+	}
+	// Spread the capacity so the shard sizes add up to exactly capacity
+	for i := range c.shards {
+		size := capacity / n
+		if i < capacity%n {
+			size++
+		}
+		c.shards[i] = &lruShard[K, V]{cache: NewLRUCache[K, V](size, opts...)}
+	}
+	return c
+}
+
+// shardFor returns the shard that owns key
+func (c *ShardedLRUCache[K, V]) shardFor(key K) *lruShard[K, V] {
+	return c.shards[c.hash(key)&c.mask]
+}
+
+// Get retrieves a value by key
+func (c *ShardedLRUCache[K, V]) Get(key K) (V, bool) {
+	shard := c.shardFor(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	return shard.cache.Get(key)
+}
+
+// Put sets a key-value pair in the cache
+func (c *ShardedLRUCache[K, V]) Put(key K, value V) {
+	shard := c.shardFor(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	shard.cache.Put(key, value)
+}
+
+// PutWithTTL sets a key-value pair that expires after ttl
+func (c *ShardedLRUCache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) {
+	shard := c.shardFor(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	shard.cache.PutWithTTL(key, value, ttl)
+}
+
+// TryPut sets a key-value pair, reporting entries that are too heavy
+func (c *ShardedLRUCache[K, V]) TryPut(key K, value V) error {
+	shard := c.shardFor(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	return shard.cache.TryPut(key, value)
+}
+
+// Weight returns the total weight of the cached entries
+func (c *ShardedLRUCache[K, V]) Weight() int64 {
+	var weight int64
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		weight += shard.cache.Weight()
+		shard.mutex.Unlock()
+	}
+	return weight
+}
+
+// RemoveExpired removes every expired entry and returns how many there were
+func (c *ShardedLRUCache[K, V]) RemoveExpired() int {
+	removed := 0
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		removed += shard.cache.RemoveExpired()
+		shard.mutex.Unlock()
+	}
+	return removed
+}
+
+// Stats returns the counters of all shards added together
+func (c *ShardedLRUCache[K, V]) Stats() Stats {
+	var stats Stats
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		stats = stats.add(shard.cache.Stats())
+		shard.mutex.Unlock()
+	}
+	return stats
+}
+
+// Size returns the current size of the cache
+func (c *ShardedLRUCache[K, V]) Size() int {
+	size := 0
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		size += shard.cache.Size()
+		shard.mutex.Unlock()
+	}
+	return size
+}
+
+// Capacity returns the total capacity of the cache
+func (c *ShardedLRUCache[K, V]) Capacity() int {
+	return c.capacity
+}
+
+// Shards returns the number of shards
+func (c *ShardedLRUCache[K, V]) Shards() int {
+	return len(c.shards)
+}
+
+// Keys returns the keys of every shard in turn, each shard's from most
+// recent to least recent. There is no global order across shards.
+func (c *ShardedLRUCache[K, V]) Keys() []K {
+	var keys []K
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		keys = append(keys, shard.cache.Keys()...)
+		shard.mutex.Unlock()
+	}
+	return keys
+}
+
+// Clear removes all entries from the cache
+func (c *ShardedLRUCache[K, V]) Clear() {
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		shard.cache.Clear()
+		shard.mutex.Unlock()
+	}
+}
+
+// DefaultHashFunc returns a HashFunc for any comparable key type. Strings and
+// integers are hashed directly; other keys, such as structs, arrays and
+// interfaces, are hashed field by field so that keys equal under == hash
+// alike. Floats hash -0 and +0 alike, as they are the same map key; NaN keys
+// are not supported since a NaN never equals itself and so can never be
+// found again.
+func DefaultHashFunc[K comparable]() HashFunc[K] {
+	seed := maphash.MakeSeed()
+	return func(key K) uint64 {
+		switch k := any(key).(type) {
+		case string:
+			return maphash.String(seed, k)
+		case int:
+			return mix64(uint64(k))
+		case int8:
+			return mix64(uint64(k))
+		case int16:
+			return mix64(uint64(k))
+		case int32:
+			return mix64(uint64(k))
+		case int64:
+			return mix64(uint64(k))
+		case uint:
+			return mix64(uint64(k))
+		case uint8:
+			return mix64(uint64(k))
+		case uint16:
+			return mix64(uint64(k))
+		case uint32:
+			return mix64(uint64(k))
+		case uint64:
+			return mix64(k)
+		case uintptr:
+			return mix64(uint64(k))
+		case float64:
+			if k == 0 {
+				k = 0 // -0 == +0 but their bits differ
+			}
+			return mix64(math.Float64bits(k))
+		case float32:
+			if k == 0 {
+				k = 0
+			}
+			return mix64(uint64(math.Float32bits(k)))
+		default:
+			var h maphash.Hash
+			h.SetSeed(seed)
+			hashValue(&h, reflect.ValueOf(&key).Elem())
+			return h.Sum64()
+		}
+	}
+}
+
+// hashValue writes v to h so that values equal under == write the same bytes
+func hashValue(h *maphash.Hash, v reflect.Value) {
+	var buf [8]byte
+	writeUint64 := func(x uint64) {
+		binary.LittleEndian.PutUint64(buf[:], x)
+		h.Write(buf[:])
+	}
+	writeFloat := func(f float64) {
+		if f == 0 {
+			f = 0 // -0 == +0 but their bits differ
+		}
+		writeUint64(math.Float64bits(f))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			h.WriteByte(1)
+		} else {
+			h.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint64(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeFloat(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		writeFloat(real(c))
+		writeFloat(imag(c))
+	case reflect.String:
+		// The length keeps adjacent strings of a struct from running together
+		writeUint64(uint64(v.Len()))
+		h.WriteString(v.String())
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		writeUint64(uint64(v.Pointer()))
+	case reflect.Interface:
+		if v.IsNil() {
+			h.WriteByte(0)
+			return
+		}
+		elem := v.Elem()
+		h.WriteString(elem.Type().String())
+		hashValue(h, elem)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			hashValue(h, v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			// Blank fields are ignored by ==
+			if v.Type().Field(i).Name != "_" {
+				hashValue(h, v.Field(i))
+			}
+		}
+	}
+}
+
+// mix64 is the splitmix64 finalizer; it spreads consecutive integers over all bits
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
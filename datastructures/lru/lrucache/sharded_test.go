@@ -0,0 +1,249 @@
+package lrucache
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestShardedLRUCache_Basics(t *testing.T) {
+	cache := NewShardedLRUCache[string, int](64, 4, nil)
+	for i := 0; i < 64; i++ {
+		cache.Put(fmt.Sprint(i), i)
+	}
+	for i := 0; i < 64; i += 7 {
+		if v, ok := cache.Get(fmt.Sprint(i)); ok && v != i {
+			t.Fatalf("Get(%d) = %d", i, v)
+		}
+	}
+	if cache.Size() > cache.Capacity() {
+		t.Fatalf("Size() = %d exceeds capacity %d", cache.Size(), cache.Capacity())
+	}
+	if len(cache.Keys()) != cache.Size() {
+		t.Fatalf("Keys() has %d entries, Size() is %d", len(cache.Keys()), cache.Size())
+	}
+	cache.Clear()
+	if cache.Size() != 0 {
+		t.Fatalf("Size() = %d after Clear", cache.Size())
+	}
+}
+
+func TestShardedLRUCache_ShardLayout(t *testing.T) {
+	cache := NewShardedLRUCache[int, int](10, 3, nil)
+	if cache.Shards() != 4 {
+		t.Fatalf("Shards() = %d, want 3 rounded up to 4", cache.Shards())
+	}
+	total := 0
+	for _, shard := range cache.shards {
+		total += shard.cache.Capacity()
+	}
+	if total != 10 {
+		t.Fatalf("shard capacities add up to %d, want 10", total)
+	}
+
+	small := NewShardedLRUCache[int, int](3, 16, nil)
+	if small.Shards() != 2 {
+		t.Fatalf("Shards() = %d, want at most one shard per entry", small.Shards())
+	}
+}
+
+func TestShardedLRUCache_EvictsLeastRecentlyUsedPerShard(t *testing.T) {
+	// A constant hash puts every key into the same 3-entry shard, which behaves
+	// exactly like an LRUCache
+	cache := NewShardedLRUCache[int, int](12, 4, func(int) uint64 { return 0 })
+	for i := 0; i < 3; i++ {
+		cache.Put(i, i)
+	}
+	cache.Get(0)
+	cache.Put(3, 3)
+
+	if _, ok := cache.Get(1); ok {
+		t.Fatal("1 should have been evicted from its shard")
+	}
+	if _, ok := cache.Get(0); !ok {
+		t.Fatal("0 was used recently and should be kept")
+	}
+}
+
+func TestShardedLRUCache_ApproximatesGlobalLRU(t *testing.T) {
+	const capacity = 1024
+	cache := NewShardedLRUCache[int, int](capacity, 8, nil)
+	for i := 0; i < 4*capacity; i++ {
+		cache.Put(i, i)
+	}
+
+	// Recently written keys should almost all be present
+	recent := 0
+	for i := 4*capacity - capacity/2; i < 4*capacity; i++ {
+		if _, ok := cache.Get(i); ok {
+			recent++
+		}
+	}
+	if recent < capacity/2*9/10 {
+		t.Fatalf("only %d of the %d most recent keys survived", recent, capacity/2)
+	}
+}
+
+func TestDefaultHashFunc_Distribution(t *testing.T) {
+	type point struct{ X, Y int }
+	hashString := DefaultHashFunc[string]()
+	hashInt := DefaultHashFunc[int]()
+	hashPoint := DefaultHashFunc[point]()
+
+	if hashString("a") != hashString("a") || hashPoint(point{1, 2}) != hashPoint(point{1, 2}) {
+		t.Fatal("hash must be deterministic")
+	}
+	if hashPoint(point{1, 2}) == hashPoint(point{2, 1}) {
+		t.Fatal("distinct struct keys should hash differently")
+	}
+
+	var buckets [8]int
+	for i := 0; i < 8000; i++ {
+		buckets[hashInt(i)&7]++
+	}
+	for shard, n := range buckets {
+		if n < 800 || n > 1200 {
+			t.Fatalf("shard %d got %d of 8000 sequential keys", shard, n)
+		}
+	}
+}
+
+func TestDefaultHashFunc_SignedZero(t *testing.T) {
+	hash64 := DefaultHashFunc[float64]()
+	hash32 := DefaultHashFunc[float32]()
+	if hash64(math.Copysign(0, -1)) != hash64(0) {
+		t.Fatal("-0.0 and 0.0 are the same key but hash differently")
+	}
+	if hash32(float32(math.Copysign(0, -1))) != hash32(0) {
+		t.Fatal("float32 -0 and 0 are the same key but hash differently")
+	}
+
+	// Both zeros must land on the same shard of a sharded cache
+	cache := NewShardedLRUCache[float64, string](64, 8, hash64)
+	cache.Put(math.Copysign(0, -1), "zero")
+	if v, ok := cache.Get(0); !ok || v != "zero" {
+		t.Fatalf("Get(0) = %q, %v", v, ok)
+	}
+}
+
+func TestDefaultHashFunc_CompositeKeys(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	type price struct {
+		Symbol string
+		Value  float64
+	}
+	hashPrice := DefaultHashFunc[price]()
+	if hashPrice(price{"AAPL", negZero}) != hashPrice(price{"AAPL", 0}) {
+		t.Fatal("struct keys equal under == must hash alike")
+	}
+	if hashPrice(price{"AAPL", 1}) == hashPrice(price{"AAPL", 2}) {
+		t.Fatal("distinct struct keys should hash differently")
+	}
+	hashArray := DefaultHashFunc[[2]float64]()
+	if hashArray([2]float64{negZero, 1}) != hashArray([2]float64{0, 1}) {
+		t.Fatal("array keys equal under == must hash alike")
+	}
+	hashComplex := DefaultHashFunc[complex128]()
+	if hashComplex(complex(negZero, negZero)) != hashComplex(0) {
+		t.Fatal("complex keys equal under == must hash alike")
+	}
+	hashAny := DefaultHashFunc[any]()
+	if hashAny(price{"AAPL", negZero}) != hashAny(price{"AAPL", 0}) || hashAny(nil) != hashAny(nil) {
+		t.Fatal("interface keys equal under == must hash alike")
+	}
+
+	cache := NewShardedLRUCache[price, string](64, 8, hashPrice)
+	cache.Put(price{"AAPL", negZero}, "flat")
+	if v, ok := cache.Get(price{"AAPL", 0}); !ok || v != "flat" {
+		t.Fatalf("Get = %q, %v", v, ok)
+	}
+}
+
+// concurrentCache is implemented by the thread-safe caches
+type concurrentCache interface {
+	Get(key int) (int, bool)
+	Put(key, value int)
+	Size() int
+	Keys() []int
+	Clear()
+}
+
+// stress hammers a cache from many goroutines; run with -race
+func stress(t *testing.T, cache concurrentCache, capacity int) {
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < 2000; i++ {
+				key := rng.Intn(capacity * 2)
+				switch op := rng.Intn(100); {
+				case op < 50:
+					if v, ok := cache.Get(key); ok && v != key*10 {
+						t.Errorf("Get(%d) = %d, want %d", key, v, key*10)
+						return
+					}
+				case op < 95:
+					cache.Put(key, key*10)
+				case op < 99:
+					if size := cache.Size(); size > capacity {
+						t.Errorf("Size() = %d exceeds capacity %d", size, capacity)
+						return
+					}
+				default:
+					cache.Keys()
+					if rng.Intn(10) == 0 {
+						cache.Clear()
+					}
+				}
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+}
+
+func TestShardedLRUCache_ConcurrentStress(t *testing.T) {
+	stress(t, NewShardedLRUCache[int, int](256, 16, nil), 256)
+}
+
+func TestSyncLRUCache_ConcurrentStress(t *testing.T) {
+	stress(t, NewSyncLRUCache[int, int](256), 256)
+}
+
+// benchmarkParallel runs a 90% read workload over a working set slightly
+// larger than the cache
+func benchmarkParallel(b *testing.B, cache concurrentCache) {
+	keys := benchKeys()
+	for i, key := range keys {
+		cache.Put(key, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := rand.Intn(len(keys))
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%10 == 0 {
+				cache.Put(key, i)
+			} else {
+				cache.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkSyncLRUCache_Parallel(b *testing.B) {
+	benchmarkParallel(b, NewSyncLRUCache[int, int](benchCapacity))
+}
+
+func BenchmarkShardedLRUCache_Parallel(b *testing.B) {
+	for _, shards := range []int{4, 16, 64} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			benchmarkParallel(b, NewShardedLRUCache[int, int](benchCapacity, shards, nil))
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package lrucache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"sync"
+	"time"
+)
+
+// slabHeaderSize is the size of the header in front of every slab entry:
+// key hash, expiry in Unix nanoseconds, key length and value length
+const slabHeaderSize = 8 + 8 + 2 + 4
+
+// SlabEntrySize returns how many ring bytes an entry with a key of keyLen
+// bytes and a value of valueLen bytes takes, to size a SlabCache with
+func SlabEntrySize(keyLen, valueLen int) int {
+	return slabHeaderSize + keyLen + valueLen
+}
+
+// SlabCache is a thread-safe cache of []byte values for very large entry
+// counts. Entries are copied into preallocated byte rings and indexed by a
+// map[uint64]uint32 of key hash to ring offset, so the garbage collector
+// has no pointers to scan no matter how many entries are cached. Space is
+// reclaimed in insertion order: when a ring is full its oldest entries are
+// overwritten, whether or not they were read recently.
+type SlabCache struct {
+	shards []*slabShard
+	mask   uint64
+	seed   maphash.Seed
+	ttl    time.Duration
+	clock  Clock
+}
+
+// slabShard is one ring of a SlabCache with its own lock
+type slabShard struct {
+	mutex sync.Mutex
+	ring  []byte
+	index map[uint64]uint32 // key hash to offset of the entry in ring
+	head  uint64            // position of the oldest entry; ring offset is head % len(ring)
+	tail  uint64            // position the next entry is written at
+	stats Stats
+}
+
+// NewSlabCache creates a cache that holds up to size bytes of entries,
+// headers and keys included, split over shards rings. WithTTL and
+// WithClock apply.
+func NewSlabCache(size, shards int, opts ...Option[string, []byte]) *SlabCache {
+	if shards <= 0 {
+		panic("shard count must be positive")
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	ringSize := size / n
+	if ringSize < slabHeaderSize {
+		panic("slab cache size is too small for its shard count")
+	}
+	if uint64(ringSize) > math.MaxUint32 {
+		panic("slab cache shards are limited to 4GiB each")
+	}
+
+	options := newCacheOptions(opts)
+	c := &SlabCache{
+		shards: make([]*slabShard, n),
+		mask:   uint64(n - 1),
+		seed:   maphash.MakeSeed(),
+		ttl:    options.ttl,
+		clock:  options.clock,
+	}
+	for i := range c.shards {
+		c.shards[i] = &slabShard{
+			ring:  make([]byte, ringSize),
+			index: make(map[uint64]uint32),
+		}
+	}
+	return c
+}
+
+// shardFor hashes key and picks its shard
+func (c *SlabCache) shardFor(key string) (*slabShard, uint64) {
+	hash := maphash.String(c.seed, key)
+	return c.shards[hash&c.mask], hash
+}
+
+// deadline returns the expiry of an entry stored now with ttl, 0 for never
+func (c *SlabCache) deadline(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return c.clock.Now().Add(ttl).UnixNano()
+}
+
+// Get returns a copy of the value of key
+func (c *SlabCache) Get(key string) ([]byte, bool) {
+	shard, hash := c.shardFor(key)
+	return shard.get(hash, key, c.clock.Now().UnixNano())
+}
+
+// Put stores a copy of value under key, expiring after the default TTL
+func (c *SlabCache) Put(key string, value []byte) {
+	c.TryPutWithTTL(key, value, c.ttl)
+}
+
+// PutWithTTL stores a copy of value that expires after ttl; zero or a
+// negative ttl keeps it until it is overwritten
+func (c *SlabCache) PutWithTTL(key string, value []byte, ttl time.Duration) {
+	c.TryPutWithTTL(key, value, ttl)
+}
+
+// TryPut is Put that reports an entry too large for a shard's ring
+func (c *SlabCache) TryPut(key string, value []byte) error {
+	return c.TryPutWithTTL(key, value, c.ttl)
+}
+
+// TryPutWithTTL stores a copy of value that expires after ttl, overwriting
+// the oldest entries of the shard until it fits. An entry larger than a
+// ring is rejected with ErrEntryTooLarge and any older value of the key is
+// removed.
+func (c *SlabCache) TryPutWithTTL(key string, value []byte, ttl time.Duration) error {
+	shard, hash := c.shardFor(key)
+	return shard.put(hash, key, value, c.deadline(ttl))
+}
+
+// Delete removes key and reports whether it was cached. Its bytes are
+// reclaimed when the ring wraps around to them.
+func (c *SlabCache) Delete(key string) bool {
+	shard, hash := c.shardFor(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	if _, ok := shard.lookup(hash, key); !ok {
+		return false
+	}
+	delete(shard.index, hash)
+	return true
+}
+
+// Len returns the number of cached entries. Expired entries count until
+// Get or the ring reclaims them.
+func (c *SlabCache) Len() int {
+	n := 0
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		n += len(shard.index)
+		shard.mutex.Unlock()
+	}
+	return n
+}
+
+// Capacity returns the number of bytes the rings hold together
+func (c *SlabCache) Capacity() int {
+	return len(c.shards) * len(c.shards[0].ring)
+}
+
+// Shards returns the number of rings
+func (c *SlabCache) Shards() int {
+	return len(c.shards)
+}
+
+// Stats returns the counters of all shards added together
+func (c *SlabCache) Stats() Stats {
+	var stats Stats
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		stats = stats.add(shard.stats)
+		shard.mutex.Unlock()
+	}
+	return stats
+}
+
+// Clear removes all entries; the rings are kept for reuse
+func (c *SlabCache) Clear() {
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		clear(shard.index)
+		shard.head, shard.tail = 0, 0
+		shard.mutex.Unlock()
+	}
+}
+
+// String returns a short description of the cache
+func (c *SlabCache) String() string {
+	return fmt.Sprintf("SlabCache(%d bytes in %d shards, %d entries)", c.Capacity(), c.Shards(), c.Len())
+}
+
+// slabHeader is the decoded header of an entry
+type slabHeader struct {
+	hash      uint64
+	expiresAt int64
+	keyLen    int
+	valueLen  int
+}
+
+// size returns the number of ring bytes the entry takes
+func (h slabHeader) size() uint64 {
+	return uint64(slabHeaderSize + h.keyLen + h.valueLen)
+}
+
+// readAt copies len(buf) bytes starting at ring position pos, wrapping
+// around the end of the ring
+func (s *slabShard) readAt(pos uint64, buf []byte) {
+	off := pos % uint64(len(s.ring))
+	n := copy(buf, s.ring[off:])
+	copy(buf[n:], s.ring)
+}
+
+// writeAt copies data to ring position pos, wrapping around the end
+func (s *slabShard) writeAt(pos uint64, data []byte) {
+	off := pos % uint64(len(s.ring))
+	n := copy(s.ring[off:], data)
+	copy(s.ring, data[n:])
+}
+
+// header decodes the entry header at ring position pos
+func (s *slabShard) header(pos uint64) slabHeader {
+	var buf [slabHeaderSize]byte
+	s.readAt(pos, buf[:])
+	return slabHeader{
+		hash:      binary.LittleEndian.Uint64(buf[0:]),
+		expiresAt: int64(binary.LittleEndian.Uint64(buf[8:])),
+		keyLen:    int(binary.LittleEndian.Uint16(buf[16:])),
+		valueLen:  int(binary.LittleEndian.Uint32(buf[18:])),
+	}
+}
+
+// position turns an indexed ring offset back into a position between
+// head and tail
+func (s *slabShard) position(off uint32) uint64 {
+	size := uint64(len(s.ring))
+	pos := s.head - s.head%size + uint64(off)
+	if pos < s.head {
+		pos += size
+	}
+	return pos
+}
+
+// lookup finds the live entry of key; a different key with the same hash
+// is reported as missing
+func (s *slabShard) lookup(hash uint64, key string) (uint64, bool) {
+	off, ok := s.index[hash]
+	if !ok {
+		return 0, false
+	}
+	pos := s.position(off)
+	h := s.header(pos)
+	if h.keyLen != len(key) {
+		return 0, false
+	}
+	return pos, s.keyEquals(pos+slabHeaderSize, key)
+}
+
+// keyEquals compares the key stored at ring position pos with key without
+// copying it out
+func (s *slabShard) keyEquals(pos uint64, key string) bool {
+	off := pos % uint64(len(s.ring))
+	n := min(len(key), len(s.ring)-int(off))
+	return string(s.ring[off:off+uint64(n)]) == key[:n] && string(s.ring[:len(key)-n]) == key[n:]
+}
+
+// get copies out the value of key if it is cached and not expired
+func (s *slabShard) get(hash uint64, key string, now int64) ([]byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pos, ok := s.lookup(hash, key)
+	if ok {
+		h := s.header(pos)
+		if h.expiresAt != 0 && now >= h.expiresAt {
+			delete(s.index, hash)
+			s.stats.Expirations++
+		} else {
+			value := make([]byte, h.valueLen)
+			s.readAt(pos+slabHeaderSize+uint64(h.keyLen), value)
+			s.stats.Hits++
+			return value, true
+		}
+	}
+	s.stats.Misses++
+	return nil, false
+}
+
+// put appends an entry at the tail, overwriting the oldest entries first
+func (s *slabShard) put(hash uint64, key string, value []byte, expiresAt int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	h := slabHeader{hash: hash, expiresAt: expiresAt, keyLen: len(key), valueLen: len(value)}
+	if len(key) > math.MaxUint16 || uint64(len(value)) > math.MaxUint32 || h.size() > uint64(len(s.ring)) {
+		s.stats.Rejections++
+		if _, ok := s.lookup(hash, key); ok {
+			delete(s.index, hash)
+		}
+		return fmt.Errorf("%w: %q needs %d bytes, a shard holds %d", ErrEntryTooLarge, key, h.size(), len(s.ring))
+	}
+
+	if _, ok := s.index[hash]; ok {
+		// The old entry stays in the ring as garbage until it is reclaimed
+		delete(s.index, hash)
+		s.stats.Updates++
+	} else {
+		s.stats.Insertions++
+	}
+	for uint64(len(s.ring))-(s.tail-s.head) < h.size() {
+		s.evictHead()
+	}
+
+	var buf [slabHeaderSize]byte
+	binary.LittleEndian.PutUint64(buf[0:], h.hash)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(h.expiresAt))
+	binary.LittleEndian.PutUint16(buf[16:], uint16(h.keyLen))
+	binary.LittleEndian.PutUint32(buf[18:], uint32(h.valueLen))
+	s.writeAt(s.tail, buf[:])
+	s.writeAt(s.tail+slabHeaderSize, []byte(key))
+	s.writeAt(s.tail+slabHeaderSize+uint64(h.keyLen), value)
+
+	s.index[hash] = uint32(s.tail % uint64(len(s.ring)))
+	s.tail += h.size()
+	return nil
+}
+
+// evictHead reclaims the oldest entry; it only leaves the index if it is
+// still the live entry of its key, not one replaced or deleted since
+func (s *slabShard) evictHead() {
+	h := s.header(s.head)
+	if off, ok := s.index[h.hash]; ok && uint64(off) == s.head%uint64(len(s.ring)) {
+		delete(s.index, h.hash)
+		s.stats.Evictions++
+	}
+	s.head += h.size()
+}
@@ -0,0 +1,251 @@
+package lrucache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSlabCache_PutGet(t *testing.T) {
+	cache := NewSlabCache(1<<12, 4)
+	value := []byte("hello")
+	cache.Put("greeting", value)
+	value[0] = 'j'
+
+	got, ok := cache.Get("greeting")
+	if !ok || string(got) != "hello" {
+		t.Fatalf("Get() = %q, %v; the cache must keep its own copy", got, ok)
+	}
+	got[0] = 'y'
+	if again, _ := cache.Get("greeting"); string(again) != "hello" {
+		t.Fatal("Get must return a copy")
+	}
+
+	cache.Put("greeting", []byte("hi"))
+	if got, _ := cache.Get("greeting"); string(got) != "hi" {
+		t.Fatalf("after an update Get() = %q", got)
+	}
+	if _, ok := cache.Get("missing"); ok {
+		t.Fatal("a missing key should miss")
+	}
+	if stats := cache.Stats(); stats.Insertions != 1 || stats.Updates != 1 || stats.Hits != 3 || stats.Misses != 1 {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+	if cache.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", cache.Len())
+	}
+}
+
+func TestSlabCache_OverwritesOldestFirst(t *testing.T) {
+	// One ring that fits exactly four 10-byte values under 2-byte keys
+	cache := NewSlabCache(4*(slabHeaderSize+2+10), 1)
+	for i := 0; i < 4; i++ {
+		cache.Put(fmt.Sprintf("k%d", i), bytes.Repeat([]byte{byte(i)}, 10))
+	}
+	// Reads do not protect an entry from being overwritten
+	cache.Get("k0")
+	cache.Put("k4", bytes.Repeat([]byte{4}, 10))
+
+	if _, ok := cache.Get("k0"); ok {
+		t.Fatal("the oldest entry should be overwritten")
+	}
+	for i := 1; i <= 4; i++ {
+		got, ok := cache.Get(fmt.Sprintf("k%d", i))
+		if !ok || !bytes.Equal(got, bytes.Repeat([]byte{byte(i)}, 10)) {
+			t.Fatalf("k%d = %v, %v", i, got, ok)
+		}
+	}
+	if stats := cache.Stats(); stats.Evictions != 1 {
+		t.Fatalf("Evictions = %d, want 1", stats.Evictions)
+	}
+}
+
+func TestSlabCache_EntriesWrapAroundTheRing(t *testing.T) {
+	// Entry sizes that do not divide the ring make entries straddle its end
+	cache := NewSlabCache(1000, 1)
+	want := make(map[string][]byte)
+	for i := 0; i < 500; i++ {
+		key := "key-" + strconv.Itoa(i)
+		value := bytes.Repeat([]byte{byte(i)}, 1+i%37)
+		cache.Put(key, value)
+		want[key] = value
+
+		// Every entry that is still indexed must read back intact
+		for k, v := range want {
+			got, ok := cache.Get(k)
+			if !ok {
+				delete(want, k)
+				continue
+			}
+			if !bytes.Equal(got, v) {
+				t.Fatalf("after %d puts %s = %v, want %v", i+1, k, got, v)
+			}
+		}
+	}
+	if len(want) == 0 || len(want) != cache.Len() {
+		t.Fatalf("%d live entries, Len() = %d", len(want), cache.Len())
+	}
+}
+
+func TestSlabCache_TTL(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewSlabCache(1<<12, 1, WithTTL[string, []byte](time.Minute), WithClock[string, []byte](clock))
+	cache.Put("default", []byte("a"))
+	cache.PutWithTTL("short", []byte("b"), time.Second)
+	cache.PutWithTTL("forever", []byte("c"), 0)
+
+	clock.Advance(time.Second)
+	if _, ok := cache.Get("short"); ok {
+		t.Fatal("short should have expired")
+	}
+	clock.Advance(time.Minute)
+	if _, ok := cache.Get("default"); ok {
+		t.Fatal("default should expire after the cache TTL")
+	}
+	if _, ok := cache.Get("forever"); !ok {
+		t.Fatal("forever should never expire")
+	}
+	if stats := cache.Stats(); stats.Expirations != 2 {
+		t.Fatalf("Expirations = %d, want 2", stats.Expirations)
+	}
+}
+
+func TestSlabCache_RejectsEntriesLargerThanARing(t *testing.T) {
+	cache := NewSlabCache(256, 2)
+	cache.Put("big", []byte("small enough"))
+	err := cache.TryPut("big", make([]byte, 200))
+	if !errors.Is(err, ErrEntryTooLarge) {
+		t.Fatalf("TryPut() error = %v, want ErrEntryTooLarge", err)
+	}
+	if _, ok := cache.Get("big"); ok {
+		t.Fatal("a rejected update must remove the older value")
+	}
+}
+
+func TestSlabCache_DeleteAndClear(t *testing.T) {
+	cache := NewSlabCache(1<<12, 2)
+	cache.Put("a", []byte("1"))
+	cache.Put("b", []byte("2"))
+	if !cache.Delete("a") || cache.Delete("a") {
+		t.Fatal("Delete should report whether the key was cached")
+	}
+	if _, ok := cache.Get("a"); ok {
+		t.Fatal("a deleted key should miss")
+	}
+
+	cache.Clear()
+	if cache.Len() != 0 {
+		t.Fatalf("Len() = %d after Clear", cache.Len())
+	}
+	cache.Put("c", []byte("3"))
+	if got, _ := cache.Get("c"); string(got) != "3" {
+		t.Fatal("the cache should be usable after Clear")
+	}
+}
+
+func TestSlabCache_Concurrent(t *testing.T) {
+	cache := NewSlabCache(1<<14, 8)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := strconv.Itoa((g*31 + i) % 300)
+				if got, ok := cache.Get(key); ok && string(got) != "v"+key {
+					t.Errorf("Get(%s) = %q", key, got)
+					return
+				}
+				cache.Put(key, []byte("v"+key))
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// gcEntries is how many entries the GC benchmarks keep cached
+const gcEntries = 1 << 20
+
+// fillLRU caches n 64-byte values under 16-byte keys in a SyncLRUCache
+func fillLRU(n int) *SyncLRUCache[string, []byte] {
+	cache := NewSyncLRUCache[string, []byte](n)
+	for i := 0; i < n; i++ {
+		cache.Put(fmt.Sprintf("key-%012d", i), make([]byte, 64))
+	}
+	return cache
+}
+
+// fillSlab caches the same entries as fillLRU in a SlabCache
+func fillSlab(n int) *SlabCache {
+	cache := NewSlabCache(n*(slabHeaderSize+16+64), 64)
+	value := make([]byte, 64)
+	for i := 0; i < n; i++ {
+		cache.Put(fmt.Sprintf("key-%012d", i), value)
+	}
+	return cache
+}
+
+// benchmarkGC times full collections while the cache built by fill is
+// live and reports the stop-the-world pauses next to the collection time
+func benchmarkGC(b *testing.B, fill func() any) {
+	cache := fill()
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(cache)
+	if cycles := after.NumGC - before.NumGC; cycles > 0 {
+		b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(cycles), "pause-ns/gc")
+	}
+	b.ReportMetric(float64(after.HeapObjects), "heap-objects")
+}
+
+func BenchmarkGC_SyncLRUCache(b *testing.B) {
+	benchmarkGC(b, func() any { return fillLRU(gcEntries) })
+}
+
+func BenchmarkGC_SlabCache(b *testing.B) {
+	benchmarkGC(b, func() any { return fillSlab(gcEntries) })
+}
+
+// benchmarkThroughput runs a read-mostly parallel load over a warm cache
+func benchmarkThroughput(b *testing.B, get func(string) bool, put func(string)) {
+	const keys = 1 << 16
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := fmt.Sprintf("key-%012d", i%keys)
+			if !get(key) {
+				put(key)
+			}
+			i += 7919
+		}
+	})
+}
+
+func BenchmarkThroughput_SyncLRUCache(b *testing.B) {
+	cache := fillLRU(1 << 16)
+	b.ResetTimer()
+	benchmarkThroughput(b,
+		func(key string) bool { _, ok := cache.Get(key); return ok },
+		func(key string) { cache.Put(key, make([]byte, 64)) })
+}
+
+func BenchmarkThroughput_SlabCache(b *testing.B) {
+	cache := fillSlab(1 << 16)
+	value := make([]byte, 64)
+	b.ResetTimer()
+	benchmarkThroughput(b,
+		func(key string) bool { _, ok := cache.Get(key); return ok },
+		func(key string) { cache.Put(key, value) })
+}
@@ -0,0 +1,290 @@
+package lrucache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// snapshotVersion is written at the start of every snapshot
+const snapshotVersion = 1
+
+// ErrBadSnapshot is returned by Load for a stream that is not a snapshot
+// this version can read
+var ErrBadSnapshot = errors.New("bad cache snapshot")
+
+// Encoder writes one value to a snapshot stream
+type Encoder interface {
+	Encode(v any) error
+}
+
+// Decoder reads one value from a snapshot stream
+type Decoder interface {
+	Decode(v any) error
+}
+
+// Codec turns keys and values into bytes for Save and back for Load
+type Codec interface {
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+// GobCodec encodes snapshots with encoding/gob. Interface values inside
+// keys or values need gob.Register.
+type GobCodec struct{}
+
+// NewEncoder returns a gob encoder writing to w
+func (GobCodec) NewEncoder(w io.Writer) Encoder { return gob.NewEncoder(w) }
+
+// NewDecoder returns a gob decoder reading from r
+func (GobCodec) NewDecoder(r io.Reader) Decoder { return gob.NewDecoder(r) }
+
+// JSONCodec encodes snapshots as a stream of JSON documents, one per line
+type JSONCodec struct{}
+
+// NewEncoder returns a JSON encoder writing to w
+func (JSONCodec) NewEncoder(w io.Writer) Encoder { return json.NewEncoder(w) }
+
+// NewDecoder returns a JSON decoder reading from r
+func (JSONCodec) NewDecoder(r io.Reader) Decoder { return json.NewDecoder(r) }
+
+// snapshotHeader precedes the entries of a snapshot
+type snapshotHeader struct {
+	Version int
+	Count   int
+}
+
+// snapshotEntry is one cached entry as it is persisted
+type snapshotEntry[K comparable, V any] struct {
+	Key       K
+	Value     V
+	ExpiresAt time.Time // zero when the entry never expires
+}
+
+// writeSnapshot encodes entries, most recently used first
+func writeSnapshot[K comparable, V any](w io.Writer, codec Codec, entries []snapshotEntry[K, V]) error {
+	enc := codec.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion, Count: len(entries)}); err != nil {
+		return fmt.Errorf("write snapshot header: %w", err)
+	}
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return fmt.Errorf("write snapshot entry %v: %w", entries[i].Key, err)
+		}
+	}
+	return nil
+}
+
+// readSnapshot decodes a whole snapshot, so that a truncated or corrupt
+// stream is rejected before anything is restored
+func readSnapshot[K comparable, V any](r io.Reader, codec Codec) ([]snapshotEntry[K, V], error) {
+	dec := codec.NewDecoder(r)
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("%w: read header: %v", ErrBadSnapshot, err)
+	}
+	if header.Version != snapshotVersion || header.Count < 0 {
+		return nil, fmt.Errorf("%w: version %d with %d entries", ErrBadSnapshot, header.Version, header.Count)
+	}
+	entries := make([]snapshotEntry[K, V], 0, min(header.Count, 1<<16))
+	for i := 0; i < header.Count; i++ {
+		var entry snapshotEntry[K, V]
+		if err := dec.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("%w: read entry %d of %d: %v", ErrBadSnapshot, i+1, header.Count, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// snapshot copies the entries from most to least recently used, leaving
+// the order and the statistics untouched
+func (lru *LRUCache[K, V]) snapshot() []snapshotEntry[K, V] {
+	entries := make([]snapshotEntry[K, V], 0, len(lru.cache))
+	for node := lru.head.Next; node != lru.tail; node = node.Next {
+		if lru.expired(node.expiresAt) {
+			continue
+		}
+		entries = append(entries, snapshotEntry[K, V]{Key: node.Key, Value: node.Value, ExpiresAt: node.expiresAt})
+	}
+	return entries
+}
+
+// restore puts entries back least recently used first, so they end up in
+// the saved order. Entries that expired in the meantime are skipped.
+func (lru *LRUCache[K, V]) restore(entries []snapshotEntry[K, V]) {
+	now := lru.clock.Now()
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		var ttl time.Duration
+		if !entry.ExpiresAt.IsZero() {
+			if ttl = entry.ExpiresAt.Sub(now); ttl <= 0 {
+				continue
+			}
+		}
+		lru.TryPutWithTTL(entry.Key, entry.Value, ttl)
+	}
+}
+
+// Save writes the live entries to w in the order Keys reports, with their
+// expiry deadlines
+func (lru *LRUCache[K, V]) Save(w io.Writer, codec Codec) error {
+	return writeSnapshot(w, codec, lru.snapshot())
+}
+
+// Load adds the entries of a snapshot written by Save, restoring their
+// recency order and remaining TTLs. Loaded entries become more recent than
+// the ones already cached; nothing is loaded from a bad snapshot.
+func (lru *LRUCache[K, V]) Load(r io.Reader, codec Codec) error {
+	entries, err := readSnapshot[K, V](r, codec)
+	if err != nil {
+		return err
+	}
+	lru.restore(entries)
+	return nil
+}
+
+// Save writes the live entries to w, encoding them outside the lock
+func (c *SyncLRUCache[K, V]) Save(w io.Writer, codec Codec) error {
+	c.mutex.Lock()
+	entries := c.cache.snapshot()
+	c.mutex.Unlock()
+	return writeSnapshot(w, codec, entries)
+}
+
+// Load adds the entries of a snapshot written by Save, decoding them
+// outside the lock
+func (c *SyncLRUCache[K, V]) Load(r io.Reader, codec Codec) error {
+	entries, err := readSnapshot[K, V](r, codec)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cache.restore(entries)
+	return nil
+}
+
+// Saver is a cache that can write a snapshot of itself
+type Saver interface {
+	Save(w io.Writer, codec Codec) error
+}
+
+// Loader is a cache that can be filled from a snapshot
+type Loader interface {
+	Load(r io.Reader, codec Codec) error
+}
+
+// SaveFile writes a snapshot of cache to path. The snapshot goes to a
+// temporary file first, so a crash never leaves a half-written file behind.
+func SaveFile(cache Saver, path string, codec Codec) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := cache.Save(tmp, codec); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadFile fills cache from a snapshot at path. A missing file is reported
+// with an error that matches os.ErrNotExist.
+func LoadFile(cache Loader, path string, codec Codec) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return cache.Load(f, codec)
+}
+
+// Snapshotter periodically saves a cache to a file in the background. It
+// must only be used with a thread-safe cache such as SyncLRUCache.
+type Snapshotter struct {
+	cache Saver
+	path  string
+	codec Codec
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+
+	mutex   sync.Mutex
+	lastErr error
+}
+
+// StartSnapshotter saves cache to path every interval until Stop is called
+func StartSnapshotter(cache Saver, path string, interval time.Duration, codec Codec) *Snapshotter {
+	if interval <= 0 {
+		panic("snapshot interval must be positive")
+	}
+	s := &Snapshotter{
+		cache: cache,
+		path:  path,
+		codec: codec,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.save()
+			}
+		}
+	}()
+	return s
+}
+
+// save writes one snapshot and records its outcome
+func (s *Snapshotter) save() error {
+	err := SaveFile(s.cache, s.path, s.codec)
+	s.mutex.Lock()
+	s.lastErr = err
+	s.mutex.Unlock()
+	return err
+}
+
+// Err returns the error of the latest snapshot, nil if it succeeded
+func (s *Snapshotter) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastErr
+}
+
+// Stop ends the periodic snapshots and writes a final one, so a clean
+// shutdown loses nothing; later calls only report that final error
+func (s *Snapshotter) Stop() error {
+	stopped := false
+	s.once.Do(func() {
+		close(s.stop)
+		stopped = true
+	})
+	<-s.done
+	if stopped {
+		return s.save()
+	}
+	return s.Err()
+}
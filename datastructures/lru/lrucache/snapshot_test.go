@@ -0,0 +1,173 @@
+package lrucache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLRUCache_SaveLoadKeepsRecencyOrder(t *testing.T) {
+	for name, codec := range map[string]Codec{"gob": GobCodec{}, "json": JSONCodec{}} {
+		t.Run(name, func(t *testing.T) {
+			cache := NewLRUCache[string, int](5)
+			for i, key := range []string{"a", "b", "c", "d", "e"} {
+				cache.Put(key, i)
+			}
+			cache.Get("b")
+			cache.Get("d")
+			want := cache.Keys()
+			stats := cache.Stats()
+
+			var buf bytes.Buffer
+			if err := cache.Save(&buf, codec); err != nil {
+				t.Fatal(err)
+			}
+			if cache.Stats() != stats || !slices.Equal(cache.Keys(), want) {
+				t.Fatal("Save must not touch the order or the statistics")
+			}
+
+			restored := NewLRUCache[string, int](5)
+			if err := restored.Load(&buf, codec); err != nil {
+				t.Fatal(err)
+			}
+			if got := restored.Keys(); !slices.Equal(got, want) {
+				t.Fatalf("restored order %v, want %v", got, want)
+			}
+			if v, ok := restored.Get("c"); !ok || v != 2 {
+				t.Fatalf("Get(c) = %d, %v", v, ok)
+			}
+		})
+	}
+}
+
+func TestLRUCache_LoadIntoSmallerCacheKeepsMostRecent(t *testing.T) {
+	cache := NewLRUCache[int, string](4)
+	for i := 0; i < 4; i++ {
+		cache.Put(i, "v")
+	}
+	var buf bytes.Buffer
+	if err := cache.Save(&buf, GobCodec{}); err != nil {
+		t.Fatal(err)
+	}
+
+	small := NewLRUCache[int, string](2)
+	if err := small.Load(&buf, GobCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := small.Keys(); !slices.Equal(got, []int{3, 2}) {
+		t.Fatalf("Keys() = %v, want the two most recent entries", got)
+	}
+}
+
+func TestLRUCache_LoadRestoresRemainingTTL(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewLRUCache[string, int](4, WithClock[string, int](clock))
+	cache.PutWithTTL("short", 1, time.Second)
+	cache.PutWithTTL("long", 2, time.Minute)
+	cache.Put("forever", 3)
+	cache.PutWithTTL("gone", 4, time.Millisecond)
+	clock.Advance(time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := cache.Save(&buf, JSONCodec{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The restart takes two seconds, long enough for "short" to expire
+	clock.Advance(2 * time.Second)
+	restored := NewLRUCache[string, int](4, WithClock[string, int](clock))
+	if err := restored.Load(&buf, JSONCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.Keys(); !slices.Equal(got, []string{"forever", "long"}) {
+		t.Fatalf("Keys() = %v", got)
+	}
+	clock.Advance(time.Minute)
+	if _, ok := restored.Get("long"); ok {
+		t.Fatal("a restored entry keeps its original deadline")
+	}
+	if _, ok := restored.Get("forever"); !ok {
+		t.Fatal("an entry without a TTL never expires")
+	}
+}
+
+func TestLRUCache_LoadRejectsBadSnapshot(t *testing.T) {
+	cache := NewLRUCache[string, int](4)
+	for i, key := range []string{"a", "b", "c"} {
+		cache.Put(key, i)
+	}
+	var buf bytes.Buffer
+	if err := cache.Save(&buf, GobCodec{}); err != nil {
+		t.Fatal(err)
+	}
+
+	truncated := bytes.NewReader(buf.Bytes()[:buf.Len()-4])
+	restored := NewLRUCache[string, int](4)
+	if err := restored.Load(truncated, GobCodec{}); !errors.Is(err, ErrBadSnapshot) {
+		t.Fatalf("Load() error = %v, want ErrBadSnapshot", err)
+	}
+	if restored.Size() != 0 {
+		t.Fatal("nothing should be loaded from a truncated snapshot")
+	}
+
+	if err := restored.Load(bytes.NewBufferString(`{"Version":99,"Count":0}`), JSONCodec{}); !errors.Is(err, ErrBadSnapshot) {
+		t.Fatalf("Load() error = %v for an unknown version", err)
+	}
+}
+
+func TestSaveFileAndLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	if err := LoadFile(NewSyncLRUCache[string, int](4), path, GobCodec{}); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("LoadFile() error = %v for a missing file", err)
+	}
+
+	cache := NewSyncLRUCache[string, int](4)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	if err := SaveFile(cache, path, GobCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewSyncLRUCache[string, int](4)
+	if err := LoadFile(restored, path, GobCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.Keys(); !slices.Equal(got, []string{"b", "a"}) {
+		t.Fatalf("Keys() = %v", got)
+	}
+
+	matches, _ := filepath.Glob(path + ".tmp*")
+	if len(matches) != 0 {
+		t.Fatalf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestSnapshotter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	cache := NewSyncLRUCache[string, int](4)
+	cache.Put("a", 1)
+
+	s := StartSnapshotter(cache, path, 5*time.Millisecond, JSONCodec{})
+	waitFor(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	})
+	cache.Put("b", 2)
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal("a second Stop should report the final snapshot's result")
+	}
+
+	restored := NewSyncLRUCache[string, int](4)
+	if err := LoadFile(restored, path, JSONCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.Keys(); !slices.Equal(got, []string{"b", "a"}) {
+		t.Fatalf("Stop should write a final snapshot, got %v", got)
+	}
+}
@@ -0,0 +1,85 @@
+package lrucache
+
+import "fmt"
+
+// EvictionReason tells an eviction callback why an entry left the cache
+type EvictionReason int
+
+const (
+	// EvictionCapacity removed the least recently used entry to make room
+	EvictionCapacity EvictionReason = iota
+	// EvictionExpired removed an entry whose TTL had passed
+	EvictionExpired
+	// EvictionReplaced dropped the old value of a key that was Put again
+	EvictionReplaced
+	// EvictionCleared removed the entry through Clear
+	EvictionCleared
+)
+
+// String returns the reason name
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictionCapacity:
+		return "capacity"
+	case EvictionExpired:
+		return "expired"
+	case EvictionReplaced:
+		return "replaced"
+	case EvictionCleared:
+		return "cleared"
+	default:
+		return fmt.Sprintf("EvictionReason(%d)", int(r))
+	}
+}
+
+// WithOnEvict calls fn with every entry that leaves the cache, for example
+// to close resources or write back dirty values. The callback runs while
+// the cache is locked, so it must not call back into the cache.
+func WithOnEvict[K comparable, V any](fn func(key K, value V, reason EvictionReason)) Option[K, V] {
+	return func(o *cacheOptions[K, V]) {
+		o.onEvict = fn
+	}
+}
+
+// Stats is a snapshot of a cache's counters
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Insertions  uint64 // Puts of new keys
+	Updates     uint64 // Puts of existing keys
+	Evictions   uint64 // entries removed to make room
+	Expirations uint64 // entries removed because their TTL passed
+	Rejections  uint64 // entries too heavy to be cached
+}
+
+// Requests returns the number of lookups
+func (s Stats) Requests() uint64 {
+	return s.Hits + s.Misses
+}
+
+// HitRate returns the fraction of lookups that hit, 0 without lookups
+func (s Stats) HitRate() float64 {
+	if s.Requests() == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Requests())
+}
+
+// add sums two snapshots
+func (s Stats) add(other Stats) Stats {
+	return Stats{
+		Hits:        s.Hits + other.Hits,
+		Misses:      s.Misses + other.Misses,
+		Insertions:  s.Insertions + other.Insertions,
+		Updates:     s.Updates + other.Updates,
+		Evictions:   s.Evictions + other.Evictions,
+		Expirations: s.Expirations + other.Expirations,
+		Rejections:  s.Rejections + other.Rejections,
+	}
+}
+
+// String formats the snapshot as a single line
+func (s Stats) String() string {
+	return fmt.Sprintf("hits=%d misses=%d hit-rate=%.2f%% insertions=%d updates=%d evictions=%d expirations=%d rejections=%d",
+		s.Hits, s.Misses, s.HitRate()*100, s.Insertions, s.Updates, s.Evictions, s.Expirations, s.Rejections)
+}
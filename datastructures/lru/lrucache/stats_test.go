@@ -0,0 +1,107 @@
+package lrucache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// evictionLog records eviction callbacks
+type evictionLog []string
+
+func (l *evictionLog) record(key string, value int, reason EvictionReason) {
+	*l = append(*l, fmt.Sprintf("%s=%d:%s", key, value, reason))
+}
+
+func TestLRUCache_Stats(t *testing.T) {
+	cache := NewLRUCache[string, int](2)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Put("a", 10)
+	cache.Get("a")
+	cache.Get("missing")
+	cache.Put("c", 3) // evicts b
+
+	want := Stats{Hits: 1, Misses: 1, Insertions: 3, Updates: 1, Evictions: 1}
+	if got := cache.Stats(); got != want {
+		t.Fatalf("Stats() = %+v, want %+v", got, want)
+	}
+	if rate := cache.Stats().HitRate(); rate != 0.5 {
+		t.Fatalf("HitRate() = %v, want 0.5", rate)
+	}
+	if (Stats{}).HitRate() != 0 {
+		t.Fatal("HitRate() without lookups should be 0")
+	}
+}
+
+func TestLRUCache_OnEvictReasons(t *testing.T) {
+	clock := newFakeClock()
+	var log evictionLog
+	cache := NewLRUCache[string, int](2, WithClock[string, int](clock), WithOnEvict(log.record))
+
+	cache.Put("a", 1)
+	cache.Put("a", 2)                     // replaced
+	cache.PutWithTTL("b", 3, time.Second) // expires
+	cache.Put("c", 4)                     // evicts a
+	clock.Advance(time.Second)
+	cache.Get("b")
+	cache.Put("d", 5)
+	cache.Clear()
+
+	want := "a=1:replaced a=2:capacity b=3:expired d=5:cleared c=4:cleared"
+	if got := strings.Join(log, " "); got != want {
+		t.Fatalf("evictions = %s\nwant        %s", got, want)
+	}
+	if stats := cache.Stats(); stats.Expirations != 1 || stats.Evictions != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestLRUCache_RemoveExpiredCallsOnEvict(t *testing.T) {
+	clock := newFakeClock()
+	var log evictionLog
+	cache := NewLRUCache[string, int](4, WithTTL[string, int](time.Second), WithClock[string, int](clock), WithOnEvict(log.record))
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	clock.Advance(time.Second)
+	cache.RemoveExpired()
+
+	if got := strings.Join(log, " "); got != "b=2:expired a=1:expired" {
+		t.Fatalf("evictions = %s", got)
+	}
+	if cache.Stats().Expirations != 2 {
+		t.Fatalf("Expirations = %d, want 2", cache.Stats().Expirations)
+	}
+}
+
+func TestWithOnEvict_TypedByCallback(t *testing.T) {
+	// The option takes the callback's types, so a callback that does not
+	// match the cache fails to compile instead of panicking
+	evicted := 0
+	var opt Option[string, int] = WithOnEvict(func(key string, value int, reason EvictionReason) { evicted++ })
+	cache := NewLRUCache[string, int](1, opt)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	if evicted != 1 {
+		t.Fatalf("got %d evictions, want 1", evicted)
+	}
+}
+
+func TestShardedLRUCache_StatsAddUp(t *testing.T) {
+	evicted := 0
+	cache := NewShardedLRUCache[int, int](16, 4, nil, WithOnEvict(func(int, int, EvictionReason) { evicted++ }))
+	for i := 0; i < 64; i++ {
+		cache.Put(i, i)
+		cache.Get(i)
+		cache.Get(i + 1000)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 64 || stats.Misses != 64 || stats.Insertions != 64 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats.Evictions != 48 || evicted != 48 {
+		t.Fatalf("Evictions = %d, callbacks = %d; want 48", stats.Evictions, evicted)
+	}
+}
@@ -0,0 +1,248 @@
+package lrucache
+
+import "time"
+
+// countMinSketch estimates how often keys were seen using 4 rows of
+// saturating counters. All counters are halved periodically so the
+// estimates follow changes in popularity.
+type countMinSketch struct {
+	rows      [4][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+// sketchMaxCount is the saturation limit of a counter
+const sketchMaxCount = 15
+
+// newCountMinSketch sizes the sketch for a cache holding capacity entries
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := 16
+	for width < capacity {
+		width <<= 1
+	}
+	s := &countMinSketch{mask: uint64(width - 1), resetAt: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index returns the counter of row i for a hash, using double hashing
+func (s *countMinSketch) index(hash uint64, i int) uint64 {
+	h2 := mix64(hash) | 1
+	return (hash + uint64(i)*h2) & s.mask
+}
+
+// increment records one occurrence of a hash
+func (s *countMinSketch) increment(hash uint64) {
+	for i := range s.rows {
+		if counter := &s.rows[i][s.index(hash, i)]; *counter < sketchMaxCount {
+			*counter++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.age()
+	}
+}
+
+// estimate returns an upper bound of how often a hash was seen
+func (s *countMinSketch) estimate(hash uint64) uint8 {
+	minimum := uint8(sketchMaxCount)
+	for i := range s.rows {
+		minimum = min(minimum, s.rows[i][s.index(hash, i)])
+	}
+	return minimum
+}
+
+// age halves every counter
+func (s *countMinSketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// reset clears every counter
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		clear(s.rows[i])
+	}
+	s.additions = 0
+}
+
+// TinyLFUCache implements W-TinyLFU. New entries land in a small LRU window;
+// an entry leaving the window is only admitted to the main segmented LRU if
+// a count-min sketch says it is used more often than the entry it would
+// replace. Main is split into probation and protected segments, and a hit
+// on probation promotes the entry to protected.
+type TinyLFUCache[K comparable, V any] struct {
+	cacheBase[K, V]
+	items        map[K]*entry[K, V]
+	window       entryList[K, V]
+	probation    entryList[K, V]
+	protected    entryList[K, V]
+	windowCap    int
+	protectedCap int
+	sketch       *countMinSketch
+	hash         HashFunc[K]
+}
+
+// NewTinyLFUCache creates a W-TinyLFU cache with the given capacity. The
+// window holds 1% of the entries and the protected segment 80% of the rest.
+func NewTinyLFUCache[K comparable, V any](capacity int, opts ...Option[K, V]) *TinyLFUCache[K, V] {
+	base := newCacheBase[K, V](capacity, opts, false)
+	windowCap := max(1, capacity/100)
+	return &TinyLFUCache[K, V]{
+		cacheBase:    base,
+		items:        make(map[K]*entry[K, V]),
+		windowCap:    windowCap,
+		protectedCap: (capacity - windowCap) * 8 / 10,
+		sketch:       newCountMinSketch(capacity),
+		hash:         DefaultHashFunc[K](),
+	}
+}
+
+// Get retrieves a value by key and records the access in the sketch
+func (c *TinyLFUCache[K, V]) Get(key K) (V, bool) {
+	c.sketch.increment(c.hash(key))
+	e, ok := c.items[key]
+	if ok && c.expired(e.expiresAt) {
+		c.expire(e)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		var zero V
+		return zero, false
+	}
+	c.stats.Hits++
+	c.touch(e)
+	return e.value, true
+}
+
+// touch moves an accessed entry forward, promoting it from probation
+func (c *TinyLFUCache[K, V]) touch(e *entry[K, V]) {
+	switch e.list {
+	case &c.window, &c.protected:
+		e.list.moveToFront(e)
+	case &c.probation:
+		c.probation.remove(e)
+		c.protected.pushFront(e)
+		if c.protected.len > c.protectedCap {
+			// Demote the least recently used protected entry
+			demoted := c.protected.back()
+			c.protected.remove(demoted)
+			c.probation.pushFront(demoted)
+		}
+	}
+}
+
+// Put sets a key-value pair in the cache, expiring after the default TTL
+func (c *TinyLFUCache[K, V]) Put(key K, value V) {
+	c.PutWithTTL(key, value, c.ttl)
+}
+
+// PutWithTTL sets a key-value pair that expires after ttl. A new key may be
+// rejected by the admission policy later, when it leaves the window.
+func (c *TinyLFUCache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) {
+	expiresAt := c.deadline(ttl)
+	if e, ok := c.items[key]; ok {
+		old := e.value
+		e.value, e.expiresAt = value, expiresAt
+		c.touch(e)
+		c.stats.Updates++
+		c.evicted(key, old, EvictionReplaced)
+		return
+	}
+
+	c.stats.Insertions++
+	c.sketch.increment(c.hash(key))
+	e := &entry[K, V]{key: key, value: value, expiresAt: expiresAt}
+	c.items[key] = e
+	c.window.pushFront(e)
+	if c.window.len > c.windowCap {
+		candidate := c.window.back()
+		c.window.remove(candidate)
+		c.admit(candidate)
+	}
+}
+
+// admit moves an entry leaving the window into main, evicting whichever of
+// it and main's next victim is used less often when main is full
+func (c *TinyLFUCache[K, V]) admit(candidate *entry[K, V]) {
+	if len(c.items) <= c.capacity {
+		c.probation.pushFront(candidate)
+		return
+	}
+
+	victim := c.probation.back()
+	if victim == nil {
+		victim = c.protected.back()
+	}
+	if victim == nil || c.sketch.estimate(c.hash(candidate.key)) <= c.sketch.estimate(c.hash(victim.key)) {
+		c.evict(candidate)
+		return
+	}
+	victim.list.remove(victim)
+	c.evict(victim)
+	c.probation.pushFront(candidate)
+}
+
+// evict removes an entry that is no longer on any list
+func (c *TinyLFUCache[K, V]) evict(e *entry[K, V]) {
+	delete(c.items, e.key)
+	c.stats.Evictions++
+	c.evicted(e.key, e.value, EvictionCapacity)
+}
+
+// expire drops an expired entry
+func (c *TinyLFUCache[K, V]) expire(e *entry[K, V]) {
+	e.list.remove(e)
+	delete(c.items, e.key)
+	c.stats.Expirations++
+	c.evicted(e.key, e.value, EvictionExpired)
+}
+
+// RemoveExpired removes every expired entry and returns how many there were
+func (c *TinyLFUCache[K, V]) RemoveExpired() int {
+	removed := 0
+	for _, e := range c.items {
+		if c.expired(e.expiresAt) {
+			c.expire(e)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Size returns the current size of the cache
+func (c *TinyLFUCache[K, V]) Size() int {
+	return len(c.items)
+}
+
+// Keys returns the protected keys, then probation, then the window, most
+// recent first within each
+func (c *TinyLFUCache[K, V]) Keys() []K {
+	keys := make([]K, 0, len(c.items))
+	keys = c.protected.appendKeys(keys)
+	keys = c.probation.appendKeys(keys)
+	return c.window.appendKeys(keys)
+}
+
+// Clear removes all entries and the frequency history
+func (c *TinyLFUCache[K, V]) Clear() {
+	c.protected.clear(&c.cacheBase)
+	c.probation.clear(&c.cacheBase)
+	c.window.clear(&c.cacheBase)
+	c.items = make(map[K]*entry[K, V])
+	c.sketch.reset()
+}
+
+// String returns a string representation of the cache
+func (c *TinyLFUCache[K, V]) String() string {
+	return formatCache("TinyLFUCache", c.capacity, c.Keys())
+}
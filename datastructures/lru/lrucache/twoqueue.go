@@ -0,0 +1,162 @@
+package lrucache
+
+import "time"
+
+// TwoQueueCache implements the 2Q algorithm. New keys enter a small FIFO
+// (recent); a key reaches the main LRU (frequent) when it is hit while in
+// the FIFO or stored again while remembered in a ghost FIFO of keys evicted
+// from it. One-off scans therefore never displace the frequent entries.
+type TwoQueueCache[K comparable, V any] struct {
+	cacheBase[K, V]
+	items     map[K]*entry[K, V]
+	ghosts    map[K]*entry[K, V]
+	recent    entryList[K, V] // A1in, FIFO
+	frequent  entryList[K, V] // Am, LRU
+	ghostList entryList[K, V] // A1out, FIFO of keys
+	recentCap int
+	ghostCap  int
+}
+
+// NewTwoQueueCache creates a 2Q cache with the given capacity, using the
+// recommended 25% for the recent FIFO and remembering half as many keys as
+// the capacity
+func NewTwoQueueCache[K comparable, V any](capacity int, opts ...Option[K, V]) *TwoQueueCache[K, V] {
+	return &TwoQueueCache[K, V]{
+		cacheBase: newCacheBase[K, V](capacity, opts, false),
+		items:     make(map[K]*entry[K, V]),
+		ghosts:    make(map[K]*entry[K, V]),
+		recentCap: max(1, capacity/4),
+		ghostCap:  max(1, capacity/2),
+	}
+}
+
+// Get retrieves a value by key, promoting it to the frequent LRU
+func (c *TwoQueueCache[K, V]) Get(key K) (V, bool) {
+	e, ok := c.items[key]
+	if ok && c.expired(e.expiresAt) {
+		c.expire(e)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		var zero V
+		return zero, false
+	}
+	c.stats.Hits++
+	e.list.remove(e)
+	c.frequent.pushFront(e)
+	return e.value, true
+}
+
+// Put sets a key-value pair in the cache, expiring after the default TTL
+func (c *TwoQueueCache[K, V]) Put(key K, value V) {
+	c.PutWithTTL(key, value, c.ttl)
+}
+
+// PutWithTTL sets a key-value pair that expires after ttl
+func (c *TwoQueueCache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) {
+	expiresAt := c.deadline(ttl)
+	if e, ok := c.items[key]; ok {
+		old := e.value
+		e.value, e.expiresAt = value, expiresAt
+		if e.list == &c.frequent {
+			c.frequent.moveToFront(e)
+		}
+		c.stats.Updates++
+		c.evicted(key, old, EvictionReplaced)
+		return
+	}
+
+	c.stats.Insertions++
+	ghost, remembered := c.ghosts[key]
+	if remembered {
+		c.ghostList.remove(ghost)
+		delete(c.ghosts, key)
+	}
+	if len(c.items) >= c.capacity {
+		c.reclaim()
+	}
+	e := &entry[K, V]{key: key, value: value, expiresAt: expiresAt}
+	c.items[key] = e
+	if remembered {
+		c.frequent.pushFront(e)
+		return
+	}
+	c.recent.pushFront(e)
+}
+
+// reclaim evicts one entry: the oldest recent one while the FIFO is over
+// its share, the least recently used frequent one otherwise
+func (c *TwoQueueCache[K, V]) reclaim() {
+	if c.recent.len > c.recentCap || c.frequent.len == 0 {
+		victim := c.recent.back()
+		c.evict(victim)
+		// Remember the key so a second request promotes it
+		var zero V
+		victim.value, victim.expiresAt = zero, time.Time{}
+		c.ghostList.pushFront(victim)
+		c.ghosts[victim.key] = victim
+		if c.ghostList.len > c.ghostCap {
+			oldest := c.ghostList.back()
+			c.ghostList.remove(oldest)
+			delete(c.ghosts, oldest.key)
+		}
+		return
+	}
+	c.evict(c.frequent.back())
+}
+
+// evict removes a resident entry to make room
+func (c *TwoQueueCache[K, V]) evict(e *entry[K, V]) {
+	e.list.remove(e)
+	delete(c.items, e.key)
+	c.stats.Evictions++
+	c.evicted(e.key, e.value, EvictionCapacity)
+}
+
+// expire drops an expired entry
+func (c *TwoQueueCache[K, V]) expire(e *entry[K, V]) {
+	e.list.remove(e)
+	delete(c.items, e.key)
+	c.stats.Expirations++
+	c.evicted(e.key, e.value, EvictionExpired)
+}
+
+// RemoveExpired removes every expired entry and returns how many there were
+func (c *TwoQueueCache[K, V]) RemoveExpired() int {
+	removed := 0
+	for _, e := range c.items {
+		if c.expired(e.expiresAt) {
+			c.expire(e)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Size returns the current size of the cache
+func (c *TwoQueueCache[K, V]) Size() int {
+	return len(c.items)
+}
+
+// Keys returns the frequent keys, most recent first, then the recent ones,
+// newest first
+func (c *TwoQueueCache[K, V]) Keys() []K {
+	keys := make([]K, 0, len(c.items))
+	keys = c.frequent.appendKeys(keys)
+	return c.recent.appendKeys(keys)
+}
+
+// Clear removes all entries and forgets the remembered keys
+func (c *TwoQueueCache[K, V]) Clear() {
+	c.frequent.clear(&c.cacheBase)
+	c.recent.clear(&c.cacheBase)
+	c.ghostList = entryList[K, V]{}
+	c.items = make(map[K]*entry[K, V])
+	c.ghosts = make(map[K]*entry[K, V])
+}
+
+// String returns a string representation of the cache
+func (c *TwoQueueCache[K, V]) String() string {
+	return formatCache("TwoQueueCache", c.capacity, c.Keys())
+}
@@ -0,0 +1,43 @@
+package lrucache
+
+import "errors"
+
+// ErrEntryTooLarge is returned for entries heavier than the cache's maximum weight
+var ErrEntryTooLarge = errors.New("entry exceeds the maximum cache weight")
+
+// WithMaxWeight bounds an LRU cache by the total weight of its entries as
+// well as their count, for example by value size in bytes. The weigher must
+// return the same weight for as long as an entry is cached.
+func WithMaxWeight[K comparable, V any](maxWeight int64, weigher func(key K, value V) int64) Option[K, V] {
+	return func(o *cacheOptions[K, V]) {
+		o.maxWeight = maxWeight
+		o.weigher = weigher
+	}
+}
+
+// weigh returns the weight of an entry, 1 without a weigher
+func (lru *LRUCache[K, V]) weigh(key K, value V) int64 {
+	if lru.weigher == nil {
+		return 1
+	}
+	return lru.weigher(key, value)
+}
+
+// overweight reports whether adding extra to the total breaks the weight limit
+func (lru *LRUCache[K, V]) overweight(extra int64) bool {
+	return lru.weigher != nil && lru.weight+extra > lru.maxWeight
+}
+
+// Weight returns the total weight of the cached entries, their count when
+// the cache has no weigher
+func (lru *LRUCache[K, V]) Weight() int64 {
+	return lru.weight
+}
+
+// MaxWeight returns the weight limit, 0 when there is none
+func (lru *LRUCache[K, V]) MaxWeight() int64 {
+	if lru.weigher == nil {
+		return 0
+	}
+	return lru.maxWeight
+}
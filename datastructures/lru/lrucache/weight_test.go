@@ -0,0 +1,123 @@
+package lrucache
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// byLength weighs string values by their length
+func byLength(key string, value string) int64 {
+	return int64(len(value))
+}
+
+func TestLRUCache_EvictsUntilEntryFits(t *testing.T) {
+	var evicted []string
+	cache := NewLRUCache[string, string](100,
+		WithMaxWeight(10, byLength),
+		WithOnEvict(func(key, value string, reason EvictionReason) { evicted = append(evicted, key) }))
+	cache.Put("a", "xxx")
+	cache.Put("b", "xxx")
+	cache.Put("c", "xxx")
+	if cache.Weight() != 9 {
+		t.Fatalf("Weight() = %d, want 9", cache.Weight())
+	}
+
+	// 9 + 6 > 10: the two least recently used entries must go
+	if err := cache.TryPut("d", "xxxxxx"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cache.Keys(), []string{"d", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("Keys() = %v, want %v", got, want)
+	}
+	if !slices.Equal(evicted, []string{"a", "b"}) || cache.Weight() != 9 {
+		t.Fatalf("evicted %v, weight %d", evicted, cache.Weight())
+	}
+	if cache.Stats().Evictions != 2 {
+		t.Fatalf("Evictions = %d, want 2", cache.Stats().Evictions)
+	}
+}
+
+func TestLRUCache_RejectsEntriesThatNeverFit(t *testing.T) {
+	cache := NewLRUCache[string, string](100, WithMaxWeight(10, byLength))
+	cache.Put("page", "small")
+
+	err := cache.TryPut("huge", strings.Repeat("x", 11))
+	if !errors.Is(err, ErrEntryTooLarge) {
+		t.Fatalf("TryPut() = %v, want ErrEntryTooLarge", err)
+	}
+	if cache.Size() != 1 || cache.Weight() != 5 {
+		t.Fatal("a rejected entry must not evict anything")
+	}
+
+	// Growing an existing entry past the limit drops the stale value
+	cache.Put("page", strings.Repeat("x", 20))
+	if _, ok := cache.Get("page"); ok {
+		t.Fatal("the old value should be gone after a rejected update")
+	}
+	if cache.Weight() != 0 || cache.Stats().Rejections != 2 {
+		t.Fatalf("weight %d, stats %+v", cache.Weight(), cache.Stats())
+	}
+}
+
+func TestLRUCache_UpdateAdjustsWeight(t *testing.T) {
+	cache := NewLRUCache[string, string](100, WithMaxWeight(10, byLength))
+	cache.Put("a", "xxx")
+	cache.Put("b", "xxx")
+	cache.Put("a", "xxxxxxx") // 7 + 3 fits exactly
+
+	if cache.Weight() != 10 || cache.Size() != 2 {
+		t.Fatalf("Weight() = %d, Size() = %d", cache.Weight(), cache.Size())
+	}
+	cache.Put("a", "xxxxxxxx") // 8 + 3 does not, b goes
+	if got := cache.Keys(); !slices.Equal(got, []string{"a"}) || cache.Weight() != 8 {
+		t.Fatalf("Keys() = %v, Weight() = %d", got, cache.Weight())
+	}
+	cache.Clear()
+	if cache.Weight() != 0 {
+		t.Fatalf("Weight() = %d after Clear", cache.Weight())
+	}
+}
+
+func TestLRUCache_CountAndWeightBothApply(t *testing.T) {
+	cache := NewLRUCache[string, string](2, WithMaxWeight(100, byLength))
+	cache.Put("a", "x")
+	cache.Put("b", "x")
+	cache.Put("c", "x")
+	if cache.Size() != 2 {
+		t.Fatalf("Size() = %d, the entry limit still applies", cache.Size())
+	}
+	if cache.MaxWeight() != 100 || NewLRUCache[string, string](2).MaxWeight() != 0 {
+		t.Fatal("MaxWeight() should report the configured limit")
+	}
+}
+
+func TestShardedLRUCache_SplitsMaxWeight(t *testing.T) {
+	cache := NewShardedLRUCache[string, string](100, 4, nil, WithMaxWeight(40, byLength))
+	if err := cache.TryPut("a", strings.Repeat("x", 10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.TryPut("b", strings.Repeat("x", 11)); !errors.Is(err, ErrEntryTooLarge) {
+		t.Fatalf("an entry above one shard's share should be rejected, got %v", err)
+	}
+	if cache.Weight() != 10 {
+		t.Fatalf("Weight() = %d, want 10", cache.Weight())
+	}
+}
+
+func TestWithMaxWeight_Validation(t *testing.T) {
+	for name, build := range map[string]func(){
+		"non-positive limit": func() { NewLRUCache[string, string](1, WithMaxWeight(0, byLength)) },
+		"unsupported policy": func() { NewLFUCache[string, string](1, WithMaxWeight(10, byLength)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected a panic")
+				}
+			}()
+			build()
+		})
+	}
+}
@@ -1,162 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
-)
-
-// Node represents a node in the doubly linked list
-type Node struct {
-	Key, Value interface{}
-	Prev, Next *Node
-}
-
-// LRUCache represents an LRU cache
-type LRUCache struct {
-	capacity int
-	cache    map[interface{}]*Node
-	head     *Node // Most recently used
-	tail     *Node // Least recently used
-}
-
-// NewLRUCache creates a new LRU cache with the given capacity
-func NewLRUCache(capacity int) *LRUCache {
-	if capacity <= 0 {
-		panic("capacity must be positive")
-	}
-	
-	// Create dummy head and tail nodes
-	head := &Node{}
-	tail := &Node{}
-	head.Next = tail
-	tail.Prev = head
-	
-	return &LRUCache{
-		capacity: capacity,
-		cache:    make(map[interface{}]*Node),
-		head:     head,
-		tail:     tail,
-	}
-}
-
-// addNode adds a node right after head
-func (lru *LRUCache) addNode(node *Node) {
-	node.Prev = lru.head
-	node.Next = lru.head.Next
-	
-	lru.head.Next.Prev = node
-	lru.head.Next = node
-}
-
-// removeNode removes an existing node from the linked list
-func (lru *LRUCache) removeNode(node *Node) {
-	prevNode := node.Prev
-	nextNode := node.Next
-	
-	prevNode.Next = nextNode
-	nextNode.Prev = prevNode
-}
-
-// moveToHead moves certain node to the head
-func (lru *LRUCache) moveToHead(node *Node) {
-	lru.removeNode(node)
-	lru.addNode(node)
-}
-
-// popTail removes the last node (LRU node)
-func (lru *LRUCache) popTail() *Node {
-	lastNode := lru.tail.Prev
-	lru.removeNode(lastNode)
-	return lastNode
-}
 
-// Get retrieves a value by key
-func (lru *LRUCache) Get(key interface{}) (interface{}, bool) {
-	node, exists := lru.cache[key]
-	if !exists {
-		return nil, false
-	}
-	
-	// Move the accessed node to the head (mark as recently used)
-	lru.moveToHead(node)
-	
-	return node.Value, true
-}
-
-// Put sets a key-value pair in the cache
-func (lru *LRUCache) Put(key, value interface{}) {
-	node, exists := lru.cache[key]
-	
-	if exists {
-		// Update the value and move to head
-		node.Value = value
-		lru.moveToHead(node)
-		return
-	}
-	
-	// Create new node
-	newNode := &Node{
-		Key:   key,
-		Value: value,
-	}
-	
-	if len(lru.cache) >= lru.capacity {
-		// Remove LRU node
-		tail := lru.popTail()
-		delete(lru.cache, tail.Key)
-	}
-	
-	// Add new node
-	lru.addNode(newNode)
-	lru.cache[key] = newNode
-}
-
-// Size returns the current size of the cache
-func (lru *LRUCache) Size() int {
-	return len(lru.cache)
-}
-
-// Capacity returns the capacity of the cache
-func (lru *LRUCache) Capacity() int {
-	return lru.capacity
-}
-
-// Keys returns all keys in order from most recent to least recent
-func (lru *LRUCache) Keys() []interface{} {
-	keys := make([]interface{}, 0, len(lru.cache))
-	current := lru.head.Next
-	for current != lru.tail {
-		keys = append(keys, current.Key)
-		current = current.Next
-	}
-	return keys
-}
-
-// Clear removes all entries from the cache
-func (lru *LRUCache) Clear() {
-	lru.cache = make(map[interface{}]*Node)
-	lru.head.Next = lru.tail
-	lru.tail.Prev = lru.head
-}
-
-// String returns a string representation of the cache
-func (lru *LRUCache) String() string {
-	keys := lru.Keys()
-	result := fmt.Sprintf("LRUCache{capacity: %d, size: %d, keys: [", lru.capacity, len(lru.cache))
-	for i, key := range keys {
-		if i > 0 {
-			result += ", "
-		}
-		result += fmt.Sprintf("%v", key)
-	}
-	result += "]}"
-	return result
-}
+	"github.com/kenneth-wang/go-demo/datastructures/lru/lrucache"
+)
 
 // Demo function to show LRU cache in action
 func demonstrateBasicOperations() {
 	fmt.Println("=== Basic LRU Cache Operations ===")
-	cache := NewLRUCache(3)
+	cache := lrucache.NewLRUCache[string, int](3)
 	
 	// Test Put operations
 	cache.Put("a", 1)
@@ -185,7 +46,7 @@ func demonstrateBasicOperations() {
 // Demo function to show cache performance characteristics
 func demonstratePerformance() {
 	fmt.Println("=== Performance Demonstration ===")
-	cache := NewLRUCache(1000)
+	cache := lrucache.NewLRUCache[string, int](1000)
 	
 	// Fill cache with data
 	start := time.Now()
@@ -197,17 +58,14 @@ func demonstratePerformance() {
 	
 	// Test random access performance
 	start = time.Now()
-	hits := 0
 	accesses := 10000
 	for i := 0; i < accesses; i++ {
 		key := fmt.Sprintf("key%d", i%1200) // Some keys won't exist
-		if _, ok := cache.Get(key); ok {
-			hits++
-		}
+		cache.Get(key)
 	}
 	accessTime := time.Since(start)
 	fmt.Printf("Time for %d random accesses: %v\n", accesses, accessTime)
-	fmt.Printf("Hit rate: %.2f%%\n", float64(hits)/float64(accesses)*100)
+	fmt.Printf("Hit rate: %.2f%%\n", cache.Stats().HitRate()*100)
 	
 	fmt.Println()
 }
@@ -215,7 +73,9 @@ func demonstratePerformance() {
 // Demo function to show LRU eviction policy
 func demonstrateEvictionPolicy() {
 	fmt.Println("=== LRU Eviction Policy Demonstration ===")
-	cache := NewLRUCache(4)
+	cache := lrucache.NewLRUCache[int, string](4, lrucache.WithOnEvict(func(key int, value string, reason lrucache.EvictionReason) {
+		fmt.Printf("  evicted %d=%s (%s)\n", key, value, reason)
+	}))
 	
 	// Fill cache
 	for i := 1; i <= 4; i++ {
@@ -244,25 +104,266 @@ func demonstrateEvictionPolicy() {
 // Demo function showing practical use case - web page caching
 func demonstrateWebPageCache() {
 	fmt.Println("=== Web Page Cache Use Case ===")
-	pageCache := NewLRUCache(5)
+	// Pages go stale after a minute even if the cache never fills up
+	pageCache := lrucache.NewSyncLRUCache[string, string](5, lrucache.WithTTL[string, string](time.Minute))
+	pages := lrucache.NewLoadingCache[string, string](pageCache)
+	
+	// Simulate loading page content
+	var loads atomic.Int64
+	loadPage := func(ctx context.Context, page string) (string, error) {
+		loads.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return fmt.Sprintf("Content of %s page", page), nil
+	}
 	
 	// Simulate web page requests
-	pages := []string{
+	requests := []string{
 		"/home", "/about", "/products", "/contact", "/blog",
 		"/home", "/news", "/about", "/services", "/home",
 	}
 	
-	for _, page := range pages {
-		if content, exists := pageCache.Get(page); exists {
+	ctx := context.Background()
+	for _, page := range requests {
+		before := loads.Load()
+		content, err := pages.GetOrLoad(ctx, page, loadPage)
+		if err != nil {
+			fmt.Printf("Failed to load %s: %v\n", page, err)
+			continue
+		}
+		if loads.Load() == before {
 			fmt.Printf("Cache HIT: %s -> %s\n", page, content)
 		} else {
-			// Simulate loading page content
-			content := fmt.Sprintf("Content of %s page", page)
-			pageCache.Put(page, content)
 			fmt.Printf("Cache MISS: Loaded %s\n", page)
 		}
 		fmt.Printf("  Current cache: %s\n", pageCache.String())
 	}
+	fmt.Printf("Stats: %v\n", pageCache.Stats())
+	
+	// A burst of concurrent requests for an uncached page loads it only once
+	var wg sync.WaitGroup
+	before := loads.Load()
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pages.GetOrLoad(ctx, "/pricing", loadPage)
+		}()
+	}
+	wg.Wait()
+	fmt.Printf("20 concurrent requests for /pricing caused %d load(s)\n", loads.Load()-before)
+	
+	fmt.Println()
+}
+
+// Demo function showing stale-while-revalidate on a hot key
+func demonstrateStaleWhileRevalidate() {
+	fmt.Println("=== Stale-While-Revalidate ===")
+	// Quotes are refreshed after 50ms but may be served up to a second old
+	quotes := lrucache.NewLoadingCache[string, string](
+		lrucache.NewSyncLRUCache[string, string](10, lrucache.WithTTL[string, string](time.Second)),
+		lrucache.WithRefreshAfter(50*time.Millisecond),
+		lrucache.WithRefreshRateLimit(100, 10),
+	)
+	
+	var version atomic.Int64
+	loadQuote := func(ctx context.Context, symbol string) (string, error) {
+		time.Sleep(20 * time.Millisecond) // a slow backend
+		return fmt.Sprintf("%s v%d", symbol, version.Add(1)), nil
+	}
+	
+	ctx := context.Background()
+	for i := 0; i < 6; i++ {
+		start := time.Now()
+		quote, _ := quotes.GetOrLoad(ctx, "ACME", loadQuote)
+		fmt.Printf("Request %d: %-8s in %v\n", i+1, quote, time.Since(start).Round(time.Millisecond))
+		time.Sleep(30 * time.Millisecond)
+	}
+	fmt.Printf("Load stats: %+v\n", quotes.LoadStats())
+	
+	fmt.Println()
+}
+
+// Demo function showing concurrent access through the thread-safe caches
+func demonstrateConcurrentAccess() {
+	fmt.Println("=== Concurrent Access ===")
+	
+	run := func(name string, get func(int) (int, bool), put func(int, int)) {
+		var wg sync.WaitGroup
+		var hits atomic.Int64
+		start := time.Now()
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(offset int) {
+				defer wg.Done()
+				for i := 0; i < 50000; i++ {
+					key := (offset*7919 + i) % 1200
+					if _, ok := get(key); ok {
+						hits.Add(1)
+					} else {
+						put(key, key*key)
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		fmt.Printf("%-28s 400000 operations in %v, %d hits\n", name, time.Since(start), hits.Load())
+	}
+	
+	single := lrucache.NewSyncLRUCache[int, int](1000)
+	run("single mutex:", single.Get, single.Put)
+	
+	sharded := lrucache.NewShardedLRUCache[int, int](1000, 16, nil)
+	run(fmt.Sprintf("sharded (%d shards):", sharded.Shards()), sharded.Get, sharded.Put)
+	
+	fmt.Println()
+}
+
+// Demo function showing TTL expiry and the background janitor
+func demonstrateExpiration() {
+	fmt.Println("=== TTL Expiration ===")
+	sessions := lrucache.NewSyncLRUCache[string, string](10, lrucache.WithTTL[string, string](100*time.Millisecond))
+	janitor := lrucache.StartJanitor(sessions, 20*time.Millisecond)
+	defer janitor.Stop()
+	
+	sessions.Put("alice", "session-a")
+	sessions.Put("bob", "session-b")
+	sessions.PutWithTTL("admin", "session-admin", 0) // never expires
+	sessions.PutWithTTL("guest", "session-guest", 30*time.Millisecond)
+	fmt.Printf("Sessions stored: %v\n", sessions.Keys())
+	
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := sessions.Get("guest"); !ok {
+		fmt.Println("Guest session expired after 30ms")
+	}
+	
+	time.Sleep(100 * time.Millisecond)
+	fmt.Printf("After 150ms the janitor left: %v\n", sessions.Keys())
+	
+	fmt.Println()
+}
+
+// Demo function comparing garbage collection cost with a slab cache
+func demonstrateSlabCache() {
+	fmt.Println("=== GC-Friendly Slab Cache ===")
+	const entries = 300000
+	timeGC := func() time.Duration {
+		start := time.Now()
+		runtime.GC()
+		return time.Since(start)
+	}
+	
+	lru := lrucache.NewSyncLRUCache[string, []byte](entries)
+	for i := 0; i < entries; i++ {
+		lru.Put(fmt.Sprintf("user:%d", i), make([]byte, 64))
+	}
+	fmt.Printf("SyncLRUCache with %d entries: GC took %v\n", entries, timeGC().Round(time.Microsecond))
+	runtime.KeepAlive(lru)
+	runtime.GC() // let the LRU cache go before measuring the next one
+	
+	slab := lrucache.NewSlabCache(entries*lrucache.SlabEntrySize(12, 64), 16)
+	value := make([]byte, 64)
+	for i := 0; i < entries; i++ {
+		slab.Put(fmt.Sprintf("user:%d", i), value)
+	}
+	fmt.Printf("SlabCache with %d entries:    GC took %v\n", slab.Len(), timeGC().Round(time.Microsecond))
+	fmt.Printf("  %s\n", slab)
+	
+	fmt.Println()
+}
+
+// Demo function showing a warm restart from a snapshot file
+func demonstrateWarmRestart() {
+	fmt.Println("=== Warm Restart ===")
+	dir, err := os.MkdirTemp("", "lru-snapshot")
+	if err != nil {
+		fmt.Printf("Cannot create a snapshot directory: %v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "products.snapshot")
+	
+	// The first process fills its cache and snapshots it while running
+	products := lrucache.NewSyncLRUCache[string, string](4)
+	snapshotter := lrucache.StartSnapshotter(products, path, time.Second, lrucache.GobCodec{})
+	for _, id := range []string{"p1", "p2", "p3", "p4"} {
+		products.Put(id, "details of "+id)
+	}
+	products.Get("p2")
+	fmt.Printf("Before shutdown: %v\n", products.Keys())
+	if err := snapshotter.Stop(); err != nil {
+		fmt.Printf("Final snapshot failed: %v\n", err)
+		return
+	}
+	
+	// The restarted process starts warm, in the same recency order
+	restarted := lrucache.NewSyncLRUCache[string, string](4)
+	if err := lrucache.LoadFile(restarted, path, lrucache.GobCodec{}); err != nil {
+		fmt.Printf("Starting cold: %v\n", err)
+	}
+	fmt.Printf("After restart:   %v\n", restarted.Keys())
+	
+	fmt.Println()
+}
+
+// Demo function comparing eviction policies on a workload with scans
+func demonstrateEvictionPolicies() {
+	fmt.Println("=== Eviction Policies ===")
+	policies := []struct {
+		name  string
+		cache lrucache.Cache[int, int]
+	}{
+		{"LRU", lrucache.NewLRUCache[int, int](100)},
+		{"LFU", lrucache.NewLFUCache[int, int](100)},
+		{"ARC", lrucache.NewARCCache[int, int](100)},
+		{"2Q", lrucache.NewTwoQueueCache[int, int](100)},
+		{"W-TinyLFU", lrucache.NewTinyLFUCache[int, int](100)},
+	}
+	
+	// A hot set of 60 keys, interrupted by scans of 150 one-off keys
+	for _, policy := range policies {
+		scanKey := 1000
+		for round := 0; round < 20; round++ {
+			for key := 0; key < 60; key++ {
+				if _, ok := policy.cache.Get(key); !ok {
+					policy.cache.Put(key, key)
+				}
+			}
+			if round%4 == 3 {
+				for i := 0; i < 150; i++ {
+					policy.cache.Put(scanKey, scanKey)
+					scanKey++
+				}
+			}
+		}
+		fmt.Printf("%-10s hit rate %.2f%%\n", policy.name, policy.cache.Stats().HitRate()*100)
+	}
+	
+	fmt.Println()
+}
+
+// Demo function showing a page cache bounded by rendered size in bytes
+func demonstrateWeightedCache() {
+	fmt.Println("=== Size-Bounded Page Cache ===")
+	pageCache := lrucache.NewLRUCache[string, string](100, lrucache.WithMaxWeight(256, func(page, html string) int64 {
+		return int64(len(html))
+	}))
+	
+	render := func(page string, size int) string {
+		return fmt.Sprintf("<html>%s%s</html>", page, strings.Repeat(".", size))
+	}
+	pages := []struct {
+		path string
+		size int
+	}{
+		{"/home", 60}, {"/about", 40}, {"/products", 120}, {"/catalog", 400}, {"/blog", 80},
+	}
+	for _, page := range pages {
+		if err := pageCache.TryPut(page.path, render(page.path, page.size)); err != nil {
+			fmt.Printf("Not cached: %v\n", err)
+			continue
+		}
+		fmt.Printf("Cached %-9s -> %d/%d bytes, keys: %v\n", page.path, pageCache.Weight(), pageCache.MaxWeight(), pageCache.Keys())
+	}
 	
 	fmt.Println()
 }
@@ -275,6 +376,13 @@ func main() {
 	demonstratePerformance()
 	demonstrateEvictionPolicy()
 	demonstrateWebPageCache()
+	demonstrateStaleWhileRevalidate()
+	demonstrateWeightedCache()
+	demonstrateEvictionPolicies()
+	demonstrateConcurrentAccess()
+	demonstrateExpiration()
+	demonstrateWarmRestart()
+	demonstrateSlabCache()
 	
 	fmt.Println("Demo completed!")
 }
@@ -0,0 +1,149 @@
+package peercache
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/kenneth-wang/go-demo/datastructures/lru/lrucache"
+	"github.com/kenneth-wang/go-demo/grpc/grpc-demo/peercachepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Getter loads the value of a key from the source of truth
+type Getter func(ctx context.Context, key string) ([]byte, error)
+
+// GroupStats counts how a group answered Get
+type GroupStats struct {
+	Gets          uint64 // calls to Get
+	LocalLoads    uint64 // getter calls on this peer
+	PeerFetches   uint64 // values fetched from the owning peer
+	PeerErrors    uint64 // failed fetches that fell back to a local load
+	ServedToPeers uint64 // requests from other peers
+	Main          lrucache.Stats
+	Hot           lrucache.Stats
+}
+
+// groupStats holds the counters of GroupStats while they change
+type groupStats struct {
+	Gets, LocalLoads, PeerFetches, PeerErrors, ServedToPeers atomic.Uint64
+}
+
+// Group is a named cache spread over the peers of a Pool. The keys this peer
+// owns live in the main cache; values fetched from other peers are kept in
+// a small hot cache so that popular keys do not cost a round trip each time.
+type Group struct {
+	name   string
+	pool   *Pool
+	getter Getter
+	main   *lrucache.LoadingCache[string, []byte]
+	hot    *lrucache.LoadingCache[string, []byte]
+	stats  groupStats
+}
+
+// groupConfig collects the options of a group
+type groupConfig struct {
+	cacheSize int
+	ttl       time.Duration
+	hotSize   int
+	hotTTL    time.Duration
+}
+
+// GroupOption configures a Group
+type GroupOption func(*groupConfig)
+
+// WithCacheSize sets how many owned keys a peer keeps; the default is 1000
+func WithCacheSize(size int) GroupOption {
+	return func(c *groupConfig) {
+		c.cacheSize = size
+	}
+}
+
+// WithTTL expires owned keys after ttl; by default they stay until evicted
+func WithTTL(ttl time.Duration) GroupOption {
+	return func(c *groupConfig) {
+		c.ttl = ttl
+	}
+}
+
+// WithHotCache sets the size of the cache for keys owned by other peers and
+// how long they are trusted; the default is 100 keys for ten seconds
+func WithHotCache(size int, ttl time.Duration) GroupOption {
+	return func(c *groupConfig) {
+		c.hotSize, c.hotTTL = size, ttl
+	}
+}
+
+// newGroup builds a group with its two caches
+func newGroup(name string, pool *Pool, getter Getter, opts []GroupOption) *Group {
+	cfg := groupConfig{cacheSize: 1000, hotSize: 100, hotTTL: 10 * time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Group{
+		name:   name,
+		pool:   pool,
+		getter: getter,
+		main: lrucache.NewLoadingCache[string, []byte](
+			lrucache.NewSyncLRUCache[string, []byte](cfg.cacheSize, lrucache.WithTTL[string, []byte](cfg.ttl))),
+		hot: lrucache.NewLoadingCache[string, []byte](
+			lrucache.NewSyncLRUCache[string, []byte](cfg.hotSize, lrucache.WithTTL[string, []byte](cfg.hotTTL))),
+	}
+}
+
+// Name returns the name of the group
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Get returns the value of key, asking the owning peer for it or loading it
+// here if this peer owns it. Concurrent Gets of one key share one request.
+// If the owner cannot be reached the value is loaded here instead.
+func (g *Group) Get(ctx context.Context, key string) ([]byte, error) {
+	g.stats.Gets.Add(1)
+	if peer, ok := g.pool.pick(key); ok {
+		value, err := g.hot.GetOrLoad(ctx, key, func(ctx context.Context, key string) ([]byte, error) {
+			return g.fetch(ctx, peer, key)
+		})
+		if err == nil || ctx.Err() != nil || status.Code(err) == codes.Unknown {
+			return slices.Clone(value), err
+		}
+		g.stats.PeerErrors.Add(1)
+	}
+	value, err := g.getLocally(ctx, key)
+	return slices.Clone(value), err
+}
+
+// getLocally answers from the main cache, loading the key on a miss
+func (g *Group) getLocally(ctx context.Context, key string) ([]byte, error) {
+	return g.main.GetOrLoad(ctx, key, func(ctx context.Context, key string) ([]byte, error) {
+		g.stats.LocalLoads.Add(1)
+		return g.getter(ctx, key)
+	})
+}
+
+// fetch asks the owning peer for key
+func (g *Group) fetch(ctx context.Context, peer *peerClient, key string) ([]byte, error) {
+	resp, err := peer.client.Get(ctx, &peercachepb.GetRequest{Group: g.name, Key: key})
+	if err != nil {
+		return nil, fmt.Errorf("fetch %q from %s: %w", key, peer.addr, err)
+	}
+	g.stats.PeerFetches.Add(1)
+	return resp.Value, nil
+}
+
+// Stats returns a snapshot of the group's counters
+func (g *Group) Stats() GroupStats {
+	return GroupStats{
+		Gets:          g.stats.Gets.Load(),
+		LocalLoads:    g.stats.LocalLoads.Load(),
+		PeerFetches:   g.stats.PeerFetches.Load(),
+		PeerErrors:    g.stats.PeerErrors.Load(),
+		ServedToPeers: g.stats.ServedToPeers.Load(),
+		Main:          g.main.Cache().Stats(),
+		Hot:           g.hot.Cache().Stats(),
+	}
+}
@@ -0,0 +1,203 @@
+package peercache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/kenneth-wang/go-demo/grpc/grpc-demo/peercachepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// defaultReplicas is how many ring points each peer gets by default
+const defaultReplicas = 50
+
+// Pool is one peer of a cache cluster. It knows the other peers, decides
+// which of them owns a key, and serves the keys it owns to them over gRPC.
+type Pool struct {
+	self     string
+	replicas int
+	dialOpts []grpc.DialOption
+
+	mutex   sync.RWMutex
+	ring    *Ring
+	clients map[string]*peerClient
+	groups  map[string]*Group
+}
+
+// peerClient is the connection to another peer
+type peerClient struct {
+	addr   string
+	conn   *grpc.ClientConn
+	client peercachepb.PeerCacheClient
+}
+
+// PoolOption configures a Pool
+type PoolOption func(*Pool)
+
+// WithReplicas sets how many ring points each peer gets; more points spread
+// keys more evenly
+func WithReplicas(replicas int) PoolOption {
+	return func(p *Pool) {
+		p.replicas = replicas
+	}
+}
+
+// WithDialOptions adds options for the connections to other peers, after
+// the default of insecure transport credentials
+func WithDialOptions(opts ...grpc.DialOption) PoolOption {
+	return func(p *Pool) {
+		p.dialOpts = append(p.dialOpts, opts...)
+	}
+}
+
+// NewPool creates the pool of the peer reachable at self; call SetPeers to
+// tell it about the others
+func NewPool(self string, opts ...PoolOption) *Pool {
+	p := &Pool{
+		self:     self,
+		replicas: defaultReplicas,
+		dialOpts: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		clients:  make(map[string]*peerClient),
+		groups:   make(map[string]*Group),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.ring = NewRing(p.replicas, nil)
+	p.ring.Add(self)
+	return p
+}
+
+// Self returns the address of this peer
+func (p *Pool) Self() string {
+	return p.self
+}
+
+// SetPeers replaces the set of peers, this one included or not. Connections
+// to peers that left are closed.
+func (p *Pool) SetPeers(peers ...string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ring := NewRing(p.replicas, nil)
+	ring.Add(p.self)
+	clients := make(map[string]*peerClient)
+	for _, addr := range peers {
+		if addr == p.self {
+			continue
+		}
+		ring.Add(addr)
+		if client, ok := p.clients[addr]; ok {
+			clients[addr] = client
+			continue
+		}
+		// NewClient does not connect, so holding the lock here is cheap
+		conn, err := grpc.NewClient(addr, p.dialOpts...)
+		if err != nil {
+			closeClients(clients, p.clients)
+			return fmt.Errorf("peer %s: %w", addr, err)
+		}
+		clients[addr] = &peerClient{addr: addr, conn: conn, client: peercachepb.NewPeerCacheClient(conn)}
+	}
+
+	closeClients(p.clients, clients)
+	p.ring, p.clients = ring, clients
+	return nil
+}
+
+// closeClients closes the connections in clients that keep does not use
+func closeClients(clients, keep map[string]*peerClient) {
+	for addr, client := range clients {
+		if keep[addr] != client {
+			client.conn.Close()
+		}
+	}
+}
+
+// Peers returns every peer on the ring, this one included
+func (p *Pool) Peers() []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.ring.Peers()
+}
+
+// pick returns the peer that owns key, or false if this peer owns it
+func (p *Pool) pick(key string) (*peerClient, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	owner := p.ring.Owner(key)
+	if owner == p.self {
+		return nil, false
+	}
+	client, ok := p.clients[owner]
+	return client, ok
+}
+
+// NewGroup creates a named cache whose values are loaded by getter on the
+// peer that owns each key. Every peer must create the same groups.
+func (p *Pool) NewGroup(name string, getter Getter, opts ...GroupOption) *Group {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, exists := p.groups[name]; exists {
+		panic("duplicate peer cache group " + name)
+	}
+	g := newGroup(name, p, getter, opts)
+	p.groups[name] = g
+	return g
+}
+
+// Group returns the group called name, or nil
+func (p *Pool) Group(name string) *Group {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.groups[name]
+}
+
+// Register serves this pool's groups to the other peers on s
+func (p *Pool) Register(s grpc.ServiceRegistrar) {
+	peercachepb.RegisterPeerCacheServer(s, &server{pool: p})
+}
+
+// Close closes the connections to the other peers
+func (p *Pool) Close() error {
+	p.mutex.Lock()
+	clients := p.clients
+	p.clients = make(map[string]*peerClient)
+	p.mutex.Unlock()
+
+	var errs []error
+	for _, client := range clients {
+		errs = append(errs, client.conn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// server answers the Get requests of other peers
+type server struct {
+	peercachepb.UnimplementedPeerCacheServer
+	pool *Pool
+}
+
+// Get loads the key on this peer without asking any other, since the
+// caller already decided this peer owns it
+func (s *server) Get(ctx context.Context, req *peercachepb.GetRequest) (*peercachepb.GetResponse, error) {
+	g := s.pool.Group(req.Group)
+	if g == nil {
+		return nil, status.Errorf(codes.NotFound, "no peer cache group %q", req.Group)
+	}
+	g.stats.ServedToPeers.Add(1)
+	value, err := g.getLocally(ctx, req.Key)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, status.FromContextError(ctxErr).Err()
+		}
+		// A loader error is final; the caller must not retry it locally
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+	return &peercachepb.GetResponse{Value: value}, nil
+}
@@ -0,0 +1,217 @@
+package peercache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// cluster runs several peers in one process, connected over bufconn
+type cluster struct {
+	pools     []*Pool
+	groups    []*Group
+	servers   []*grpc.Server
+	listeners map[string]*bufconn.Listener
+	loads     atomic.Int64
+}
+
+// newCluster starts n peers sharing one "users" group whose getter counts
+// its calls and fails for keys starting with "bad"
+func newCluster(t *testing.T, n int) *cluster {
+	t.Helper()
+	c := &cluster{listeners: make(map[string]*bufconn.Listener)}
+	addrs := make([]string, n)
+	for i := range addrs {
+		// passthrough hands the name to the dialer without resolving it
+		addrs[i] = fmt.Sprintf("passthrough:///peer-%d", i)
+		c.listeners[fmt.Sprintf("peer-%d", i)] = bufconn.Listen(1 << 20)
+	}
+	dialer := grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		lis, ok := c.listeners[addr]
+		if !ok {
+			return nil, fmt.Errorf("unknown peer %s", addr)
+		}
+		return lis.DialContext(ctx)
+	})
+
+	for i, addr := range addrs {
+		pool := NewPool(addr, WithDialOptions(dialer))
+		if err := pool.SetPeers(addrs...); err != nil {
+			t.Fatal(err)
+		}
+		group := pool.NewGroup("users", func(ctx context.Context, key string) ([]byte, error) {
+			c.loads.Add(1)
+			if strings.HasPrefix(key, "bad") {
+				return nil, errors.New("no such user")
+			}
+			return []byte("profile of " + key), nil
+		})
+		server := grpc.NewServer()
+		pool.Register(server)
+		go server.Serve(c.listeners[fmt.Sprintf("peer-%d", i)])
+
+		c.pools = append(c.pools, pool)
+		c.groups = append(c.groups, group)
+		c.servers = append(c.servers, server)
+	}
+	t.Cleanup(func() {
+		for i := range c.pools {
+			c.servers[i].Stop()
+			c.pools[i].Close()
+		}
+	})
+	return c
+}
+
+// stop takes peer i down
+func (c *cluster) stop(i int) {
+	c.servers[i].Stop()
+}
+
+func TestCluster_EachKeyIsLoadedOnce(t *testing.T) {
+	c := newCluster(t, 3)
+	ctx := context.Background()
+	for round := 0; round < 2; round++ {
+		for _, g := range c.groups {
+			for k := 0; k < 30; k++ {
+				key := fmt.Sprintf("user-%d", k)
+				value, err := g.Get(ctx, key)
+				if err != nil || string(value) != "profile of "+key {
+					t.Fatalf("Get(%s) = %q, %v", key, value, err)
+				}
+			}
+		}
+	}
+	if loads := c.loads.Load(); loads != 30 {
+		t.Fatalf("30 keys loaded %d times across the cluster", loads)
+	}
+
+	var fetches, served uint64
+	for _, g := range c.groups {
+		stats := g.Stats()
+		fetches += stats.PeerFetches
+		served += stats.ServedToPeers
+	}
+	if fetches == 0 || fetches != served {
+		t.Fatalf("%d fetches but %d requests served", fetches, served)
+	}
+	// The second round is answered from the hot caches
+	if fetches > 60 {
+		t.Fatalf("%d fetches, the hot cache should absorb repeated Gets", fetches)
+	}
+}
+
+func TestCluster_OwnerIsAgreedOn(t *testing.T) {
+	c := newCluster(t, 3)
+	for k := 0; k < 50; k++ {
+		key := fmt.Sprintf("k%d", k)
+		owner := c.pools[0].ring.Owner(key)
+		for _, p := range c.pools[1:] {
+			if p.ring.Owner(key) != owner {
+				t.Fatalf("peers disagree on the owner of %s", key)
+			}
+		}
+	}
+}
+
+func TestCluster_ConcurrentGetsShareOneFetch(t *testing.T) {
+	c := newCluster(t, 2)
+	// Find a key that peer 0 has to fetch from peer 1
+	key := ""
+	for k := 0; key == ""; k++ {
+		if c.pools[0].ring.Owner(fmt.Sprint(k)) == c.pools[1].Self() {
+			key = fmt.Sprint(k)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.groups[0].Get(context.Background(), key); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if loads := c.loads.Load(); loads != 1 {
+		t.Fatalf("loaded %d times, want 1", loads)
+	}
+	if served := c.groups[1].Stats().ServedToPeers; served > 20 || served == 0 {
+		t.Fatalf("owner served %d requests", served)
+	}
+}
+
+func TestCluster_LoaderErrorsAreNotRetriedLocally(t *testing.T) {
+	c := newCluster(t, 2)
+	key := ""
+	for k := 0; key == ""; k++ {
+		if candidate := fmt.Sprintf("bad-%d", k); c.pools[0].ring.Owner(candidate) == c.pools[1].Self() {
+			key = candidate
+		}
+	}
+	_, err := c.groups[0].Get(context.Background(), key)
+	if err == nil || !strings.Contains(err.Error(), "no such user") {
+		t.Fatalf("Get() error = %v, want the owner's loader error", err)
+	}
+	if loads := c.loads.Load(); loads != 1 {
+		t.Fatalf("loaded %d times, a loader error must not be retried", loads)
+	}
+}
+
+func TestCluster_FallsBackWhenOwnerIsDown(t *testing.T) {
+	c := newCluster(t, 2)
+	c.stop(1)
+	key := ""
+	for k := 0; key == ""; k++ {
+		if c.pools[0].ring.Owner(fmt.Sprint(k)) == c.pools[1].Self() {
+			key = fmt.Sprint(k)
+		}
+	}
+	value, err := c.groups[0].Get(context.Background(), key)
+	if err != nil || string(value) != "profile of "+key {
+		t.Fatalf("Get() = %q, %v", value, err)
+	}
+	if stats := c.groups[0].Stats(); stats.PeerErrors != 1 || stats.LocalLoads != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCluster_ReturnedValuesAreCopies(t *testing.T) {
+	c := newCluster(t, 1)
+	value, _ := c.groups[0].Get(context.Background(), "alice")
+	value[0] = 'X'
+	if again, _ := c.groups[0].Get(context.Background(), "alice"); string(again) != "profile of alice" {
+		t.Fatalf("the cached value was modified: %q", again)
+	}
+}
+
+func TestPool_SetPeers(t *testing.T) {
+	p := NewPool("self:1")
+	defer p.Close()
+	if err := p.SetPeers("self:1", "other:1", "other:2"); err != nil {
+		t.Fatal(err)
+	}
+	if peers := p.Peers(); len(peers) != 3 {
+		t.Fatalf("Peers() = %v", peers)
+	}
+	kept := p.clients["other:1"]
+	if err := p.SetPeers("other:1"); err != nil {
+		t.Fatal(err)
+	}
+	if p.clients["other:1"] != kept || len(p.clients) != 1 {
+		t.Fatal("the connection to a remaining peer should be reused")
+	}
+	if peers := p.Peers(); len(peers) != 2 || peers[1] != "self:1" {
+		t.Fatalf("this peer always stays on the ring: %v", peers)
+	}
+}
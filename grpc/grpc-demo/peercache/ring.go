@@ -0,0 +1,96 @@
+// Package peercache shares cached values between several instances: every
+// key is owned by one peer, picked with a consistent-hash ring, and the other
+// peers fetch it from the owner over gRPC instead of loading it themselves.
+package peercache
+
+import (
+	"hash/crc32"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// HashFunc maps bytes to a point on the ring
+type HashFunc func(data []byte) uint32
+
+// Ring is a consistent-hash ring. Each peer is placed at several points so
+// that keys spread evenly, and adding or removing a peer only moves the keys
+// next to its points.
+type Ring struct {
+	replicas int
+	hash     HashFunc
+	points   []uint32          // sorted
+	owners   map[uint32]string // point to peer
+	peers    map[string]bool   // every peer added, even one that lost all its points
+}
+
+// NewRing creates an empty ring placing each peer at replicas points. A nil
+// hash uses CRC-32.
+func NewRing(replicas int, hash HashFunc) *Ring {
+	if replicas <= 0 {
+		panic("ring replicas must be positive")
+	}
+	if hash == nil {
+		hash = crc32.ChecksumIEEE
+	}
+	return &Ring{
+		replicas: replicas,
+		hash:     hash,
+		owners:   make(map[uint32]string),
+		peers:    make(map[string]bool),
+	}
+}
+
+// Add places peers on the ring. When two peers hash to the same point the
+// lexicographically smaller one owns it, so every peer builds the same ring
+// whatever order it learns about the others in.
+func (r *Ring) Add(peers ...string) {
+	for _, peer := range peers {
+		r.peers[peer] = true
+		for i := 0; i < r.replicas; i++ {
+			point := r.hash([]byte(strconv.Itoa(i) + peer))
+			owner, taken := r.owners[point]
+			if !taken {
+				r.points = append(r.points, point)
+			}
+			if !taken || peer < owner {
+				r.owners[point] = peer
+			}
+		}
+	}
+	slices.Sort(r.points)
+}
+
+// Remove takes a peer off the ring. The other peers are placed again so
+// that points the removed peer won in a collision go back to the loser.
+func (r *Ring) Remove(peer string) {
+	delete(r.peers, peer)
+	peers := r.Peers()
+	r.points = r.points[:0]
+	clear(r.owners)
+	r.Add(peers...)
+}
+
+// Owner returns the peer responsible for key, or "" for an empty ring
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	point := r.hash([]byte(key))
+	// The first point clockwise from the key, wrapping around to the start
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= point })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// Peers returns the distinct peers on the ring, sorted
+func (r *Ring) Peers() []string {
+	peers := make([]string, 0, len(r.peers))
+	for peer := range r.peers {
+		peers = append(peers, peer)
+	}
+	slices.Sort(peers)
+	return peers
+}
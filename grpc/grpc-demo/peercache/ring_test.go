@@ -0,0 +1,90 @@
+package peercache
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestRing_OwnerIsStable(t *testing.T) {
+	ring := NewRing(50, nil)
+	if ring.Owner("key") != "" {
+		t.Fatal("an empty ring has no owner")
+	}
+	ring.Add("a", "b", "c")
+	for i := 0; i < 100; i++ {
+		key := "key-" + strconv.Itoa(i)
+		if ring.Owner(key) != ring.Owner(key) {
+			t.Fatalf("owner of %s changed between calls", key)
+		}
+	}
+
+	// The order peers are added in does not matter
+	other := NewRing(50, nil)
+	other.Add("c", "a", "b")
+	for i := 0; i < 100; i++ {
+		key := "key-" + strconv.Itoa(i)
+		if ring.Owner(key) != other.Owner(key) {
+			t.Fatalf("rings disagree on %s", key)
+		}
+	}
+}
+
+func TestRing_SpreadsKeys(t *testing.T) {
+	ring := NewRing(100, nil)
+	ring.Add("a", "b", "c", "d")
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[ring.Owner(fmt.Sprintf("user:%d", i))]++
+	}
+	for _, peer := range []string{"a", "b", "c", "d"} {
+		if counts[peer] < 1500 || counts[peer] > 3500 {
+			t.Fatalf("uneven spread %v", counts)
+		}
+	}
+}
+
+func TestRing_RemoveOnlyMovesItsKeys(t *testing.T) {
+	ring := NewRing(50, nil)
+	ring.Add("a", "b", "c")
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		before[key] = ring.Owner(key)
+	}
+
+	ring.Remove("b")
+	for key, owner := range before {
+		now := ring.Owner(key)
+		if now == "b" {
+			t.Fatalf("%s still owned by a removed peer", key)
+		}
+		if owner != "b" && now != owner {
+			t.Fatalf("%s moved from %s to %s though its owner stayed", key, owner, now)
+		}
+	}
+	if peers := ring.Peers(); len(peers) != 2 || peers[0] != "a" || peers[1] != "c" {
+		t.Fatalf("Peers() = %v", peers)
+	}
+}
+
+func TestRing_CollisionsDoNotDependOnOrder(t *testing.T) {
+	// Every point collides, so one peer owns the whole ring
+	collide := func([]byte) uint32 { return 7 }
+	ab := NewRing(3, collide)
+	ab.Add("a", "b")
+	ba := NewRing(3, collide)
+	ba.Add("b", "a")
+	if ab.Owner("key") != "a" || ba.Owner("key") != "a" {
+		t.Fatalf("owners = %s and %s, want a", ab.Owner("key"), ba.Owner("key"))
+	}
+
+	// Removing the winner hands its points to the peer it beat
+	ba.Remove("a")
+	if owner := ba.Owner("key"); owner != "b" {
+		t.Fatalf("owner after removing a = %q, want b", owner)
+	}
+	if peers := ba.Peers(); len(peers) != 1 || peers[0] != "b" {
+		t.Fatalf("Peers() = %v", peers)
+	}
+}
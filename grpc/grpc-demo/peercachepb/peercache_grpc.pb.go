@@ -0,0 +1,127 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: peercache.proto
+
+package peercachepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PeerCache_Get_FullMethodName = "/peercache.PeerCache/Get"
+)
+
+// PeerCacheClient is the client API for PeerCache service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// PeerCache serves the keys a peer owns to the other peers of a pool
+type PeerCacheClient interface {
+	// Get returns the value of a key, loading it on the owning peer on a miss
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+}
+
+type peerCacheClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPeerCacheClient(cc grpc.ClientConnInterface) PeerCacheClient {
+	return &peerCacheClient{cc}
+}
+
+func (c *peerCacheClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, PeerCache_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PeerCacheServer is the server API for PeerCache service.
+// All implementations must embed UnimplementedPeerCacheServer
+// for forward compatibility.
+//
+// PeerCache serves the keys a peer owns to the other peers of a pool
+type PeerCacheServer interface {
+	// Get returns the value of a key, loading it on the owning peer on a miss
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	mustEmbedUnimplementedPeerCacheServer()
+}
+
+// UnimplementedPeerCacheServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPeerCacheServer struct{}
+
+func (UnimplementedPeerCacheServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedPeerCacheServer) mustEmbedUnimplementedPeerCacheServer() {}
+func (UnimplementedPeerCacheServer) testEmbeddedByValue()                   {}
+
+// UnsafePeerCacheServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PeerCacheServer will
+// result in compilation errors.
+type UnsafePeerCacheServer interface {
+	mustEmbedUnimplementedPeerCacheServer()
+}
+
+func RegisterPeerCacheServer(s grpc.ServiceRegistrar, srv PeerCacheServer) {
+	// If the following call pancis, it indicates UnimplementedPeerCacheServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PeerCache_ServiceDesc, srv)
+}
+
+func _PeerCache_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerCacheServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PeerCache_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerCacheServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PeerCache_ServiceDesc is the grpc.ServiceDesc for PeerCache service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PeerCache_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "peercache.PeerCache",
+	HandlerType: (*PeerCacheServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _PeerCache_Get_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "peercache.proto",
+}
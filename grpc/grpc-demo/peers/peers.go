@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/kenneth-wang/go-demo/grpc/grpc-demo/peercache"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	// 在一个进程里启动三个节点, 每个节点监听自己的端口
+	addrs := []string{"localhost:50061", "localhost:50062", "localhost:50063"}
+	var loads atomic.Int64
+	groups := make([]*peercache.Group, len(addrs))
+	for i, addr := range addrs {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalf("Failed to listen: %v", err)
+		}
+		pool := peercache.NewPool(addr)
+		if err := pool.SetPeers(addrs...); err != nil {
+			log.Fatalf("Failed to set peers: %v", err)
+		}
+		defer pool.Close()
+
+		// 模拟一个慢的数据库
+		groups[i] = pool.NewGroup("products", func(ctx context.Context, key string) ([]byte, error) {
+			loads.Add(1)
+			time.Sleep(20 * time.Millisecond)
+			return []byte("details of " + key), nil
+		})
+
+		grpcServer := grpc.NewServer()
+		pool.Register(grpcServer)
+		go grpcServer.Serve(listener)
+		defer grpcServer.Stop()
+	}
+
+	// 每个节点都请求同样的 20 个 key
+	ctx := context.Background()
+	start := time.Now()
+	for _, group := range groups {
+		for k := 0; k < 20; k++ {
+			if _, err := group.Get(ctx, fmt.Sprintf("product-%d", k)); err != nil {
+				log.Fatalf("Get failed: %v", err)
+			}
+		}
+	}
+	fmt.Printf("60 lookups of 20 keys on 3 peers took %v and %d loads\n", time.Since(start).Round(time.Millisecond), loads.Load())
+	for i, group := range groups {
+		stats := group.Stats()
+		fmt.Printf("%s: local loads=%d fetched from peers=%d served to peers=%d\n",
+			addrs[i], stats.LocalLoads, stats.PeerFetches, stats.ServedToPeers)
+	}
+}
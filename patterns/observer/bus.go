@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PayloadTypeError reports an event whose payload does not have the type its topic declares
+type PayloadTypeError struct {
+	Topic string
+	Want  reflect.Type
+	Got   reflect.Type
+}
+
+// Error implements error
+func (e *PayloadTypeError) Error() string {
+	return fmt.Sprintf("topic %s: expected payload %v, got %v", e.Topic, e.Want, e.Got)
+}
+
+// PayloadAs returns the event payload as T, or a *PayloadTypeError if it has another type
+func PayloadAs[T any](event Event) (T, error) {
+	data, ok := event.Data.(T)
+	if !ok {
+		return data, &PayloadTypeError{
+			Topic: event.Type,
+			Want:  reflect.TypeOf((*T)(nil)).Elem(),
+			Got:   reflect.TypeOf(event.Data),
+		}
+	}
+	return data, nil
+}
+
+// TypedEvent is an Event whose payload type is known at compile time
+type TypedEvent[T any] struct {
+	Type      string
+	Data      T
+	Timestamp time.Time
+	Source    string
+	Sequence  uint64            // position in the event store, 0 when not stored
+	Metadata  map[string]string // set by publish middleware, read-only
+}
+
+// Untyped converts the event back to the dispatcher's Event
+func (e TypedEvent[T]) Untyped() Event {
+	return Event{
+		Type:      e.Type,
+		Data:      e.Data,
+		Timestamp: e.Timestamp,
+		Source:    e.Source,
+		Sequence:  e.Sequence,
+		Metadata:  e.Metadata,
+	}
+}
+
+// Bus is a type-safe layer over EventDispatcher. Every topic declared on a
+// bus is bound to one payload type, shared by its publishers and subscribers.
+type Bus struct {
+	dispatcher *EventDispatcher
+	mutex      sync.Mutex
+	types      map[string]reflect.Type
+	mismatches atomic.Uint64
+}
+
+// NewBus creates a bus on top of an existing dispatcher, so typed and
+// untyped observers can share the same event stream
+func NewBus(dispatcher *EventDispatcher) *Bus {
+	return &Bus{
+		dispatcher: dispatcher,
+		types:      make(map[string]reflect.Type),
+	}
+}
+
+// Dispatcher returns the underlying untyped dispatcher
+func (b *Bus) Dispatcher() *EventDispatcher {
+	return b.dispatcher
+}
+
+// Mismatches returns how many events typed subscribers rejected because an
+// untyped publisher sent a payload of the wrong type
+func (b *Bus) Mismatches() uint64 {
+	return b.mismatches.Load()
+}
+
+// Topic is a named event stream carrying payloads of type T
+type Topic[T any] struct {
+	bus  *Bus
+	name string
+}
+
+// NewTopic declares a topic on the bus. Declaring the same name again with
+// the same payload type returns an equivalent topic; a different payload
+// type or a name with wildcards is a programming error and panics.
+func NewTopic[T any](bus *Bus, name string) *Topic[T] {
+	if err := ValidateTopic(name); err != nil {
+		panic(err)
+	}
+
+	payloadType := reflect.TypeOf((*T)(nil)).Elem()
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	if existing, ok := bus.types[name]; ok && existing != payloadType {
+		panic(fmt.Sprintf("topic %s already declared with payload %v, not %v", name, existing, payloadType))
+	}
+	bus.types[name] = payloadType
+	return &Topic[T]{bus: bus, name: name}
+}
+
+// Name returns the topic name
+func (t *Topic[T]) Name() string {
+	return t.name
+}
+
+// Publish sends a payload to every subscriber of the topic
+func (t *Topic[T]) Publish(source string, data T) {
+	t.bus.dispatcher.Notify(Event{
+		Type:      t.name,
+		Data:      data,
+		Timestamp: time.Now(),
+		Source:    source,
+	})
+}
+
+// Subscribe registers a typed handler under the given observer ID
+func (t *Topic[T]) Subscribe(id string, handler func(TypedEvent[T]) error, opts ...SubscribeOption) (*Subscription, error) {
+	return t.bus.dispatcher.Subscribe(t.name, &typedObserver[T]{id: id, handler: handler, bus: t.bus}, opts...)
+}
+
+// SubscribeObserver adapts an existing untyped Observer to the topic
+func (t *Topic[T]) SubscribeObserver(observer Observer, opts ...SubscribeOption) (*Subscription, error) {
+	return t.bus.dispatcher.Subscribe(t.name, observer, opts...)
+}
+
+// Unsubscribe removes the handler or observer registered under id
+func (t *Topic[T]) Unsubscribe(id string) {
+	t.bus.dispatcher.Unsubscribe(t.name, &typedObserver[T]{id: id})
+}
+
+// typedObserver adapts a typed handler to the Observer interface. Events
+// with the wrong payload are counted and fail with a *PayloadTypeError
+// instead of being silently skipped.
+type typedObserver[T any] struct {
+	id      string
+	handler func(TypedEvent[T]) error
+	bus     *Bus
+}
+
+// Update checks the payload type and calls the handler
+func (o *typedObserver[T]) Update(event Event) error {
+	data, err := PayloadAs[T](event)
+	if err != nil {
+		o.bus.mismatches.Add(1)
+		return err
+	}
+	return o.handler(TypedEvent[T]{
+		Type:      event.Type,
+		Data:      data,
+		Timestamp: event.Timestamp,
+		Source:    event.Source,
+		Sequence:  event.Sequence,
+		Metadata:  event.Metadata,
+	})
+}
+
+// GetID returns the observer ID
+func (o *typedObserver[T]) GetID() string {
+	return o.id
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTopic_PublishSubscribe(t *testing.T) {
+	bus := NewBus(NewEventDispatcher())
+	alerts := NewTopic[SystemAlert](bus, "system_alert")
+
+	var got []SystemAlert
+	alerts.Subscribe("pager", func(event TypedEvent[SystemAlert]) error {
+		got = append(got, event.Data)
+		return nil
+	})
+	alerts.Publish("test", SystemAlert{Severity: "low", Message: "hello"})
+
+	if len(got) != 1 || got[0].Message != "hello" {
+		t.Fatalf("unexpected events: %+v", got)
+	}
+
+	alerts.Unsubscribe("pager")
+	alerts.Publish("test", SystemAlert{Severity: "low", Message: "ignored"})
+	if len(got) != 1 {
+		t.Fatalf("handler still called after unsubscribe: %+v", got)
+	}
+}
+
+func TestTopic_CarriesSequenceAndMetadata(t *testing.T) {
+	dispatcher := NewEventDispatcher(
+		WithEventStore(NewMemoryEventStore()),
+		WithPublishMiddleware(TracingPublishMiddleware()))
+	bus := NewBus(dispatcher)
+	alerts := NewTopic[SystemAlert](bus, "system_alert")
+
+	var got []TypedEvent[SystemAlert]
+	alerts.Subscribe("pager", func(event TypedEvent[SystemAlert]) error {
+		got = append(got, event)
+		return nil
+	})
+	alerts.Publish("test", SystemAlert{Severity: "low", Message: "hello"})
+	if len(got) != 1 || got[0].Sequence != 1 || got[0].Metadata[TraceIDKey] == "" {
+		t.Fatalf("typed event lost its sequence or trace ID: %+v", got)
+	}
+
+	// Republishing keeps the trace ID
+	dispatcher.Notify(got[0].Untyped())
+	if len(got) != 2 || got[1].Metadata[TraceIDKey] != got[0].Metadata[TraceIDKey] {
+		t.Fatalf("republished event lost its metadata: %+v", got)
+	}
+}
+
+func TestTopic_UntypedPublisherMismatch(t *testing.T) {
+	bus := NewBus(NewEventDispatcher())
+	alerts := NewTopic[SystemAlert](bus, "system_alert")
+
+	called := false
+	alerts.Subscribe("pager", func(TypedEvent[SystemAlert]) error {
+		called = true
+		return nil
+	})
+	bus.Dispatcher().Notify(Event{Type: "system_alert", Data: "plain string", Timestamp: time.Now()})
+
+	if called {
+		t.Fatal("handler should not receive a mismatched payload")
+	}
+	if bus.Mismatches() != 1 {
+		t.Fatalf("expected 1 mismatch, got %d", bus.Mismatches())
+	}
+}
+
+func TestNewTopic_ConflictingPayloadPanics(t *testing.T) {
+	bus := NewBus(NewEventDispatcher())
+	NewTopic[SystemAlert](bus, "system_alert")
+	NewTopic[SystemAlert](bus, "system_alert")
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected panic for conflicting payload type")
+		}
+	}()
+	NewTopic[string](bus, "system_alert")
+}
+
+func TestNewTopic_WildcardPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a wildcard topic")
+		}
+	}()
+	NewTopic[StockQuote](NewBus(NewEventDispatcher()), "stock.*.price_update")
+}
+
+func TestPayloadAs(t *testing.T) {
+	event := Event{Type: "price_update", Data: 42.0}
+	if price, err := PayloadAs[float64](event); err != nil || price != 42.0 {
+		t.Fatalf("unexpected result: %v, %v", price, err)
+	}
+
+	_, err := PayloadAs[StockQuote](event)
+	var typeErr *PayloadTypeError
+	if !errors.As(err, &typeErr) || typeErr.Got.String() != "float64" {
+		t.Fatalf("expected PayloadTypeError, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Candle is an OHLC summary of one symbol's ticks over an interval
+type Candle struct {
+	Symbol string
+	Start  time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Ticks  int
+}
+
+// CandleAggregator implements Observer by folding price updates into OHLC
+// candles per symbol. It is safe for concurrent use, e.g. behind ordered lanes.
+type CandleAggregator struct {
+	ID       string
+	interval time.Duration
+	onClose  func(Candle)
+
+	mutex   sync.Mutex
+	current map[string]*Candle
+	closed  map[string][]Candle
+	late    int
+}
+
+// NewCandleAggregator creates an aggregator with the given candle interval.
+// onClose, if not nil, is called with every candle once a tick for a later
+// interval arrives.
+func NewCandleAggregator(id string, interval time.Duration, onClose func(Candle)) *CandleAggregator {
+	return &CandleAggregator{
+		ID:       id,
+		interval: interval,
+		onClose:  onClose,
+		current:  make(map[string]*Candle),
+		closed:   make(map[string][]Candle),
+	}
+}
+
+// Update adds a quote to the candle of its interval
+func (ca *CandleAggregator) Update(event Event) error {
+	quote, err := PayloadAs[StockQuote](event)
+	if err != nil {
+		return err
+	}
+
+	start := quote.Timestamp.Truncate(ca.interval)
+	ca.mutex.Lock()
+	candle := ca.current[quote.Symbol]
+	var finished *Candle
+	switch {
+	case candle != nil && start.Before(candle.Start):
+		// Ticks for an already closed interval are counted but not applied
+		ca.late++
+		ca.mutex.Unlock()
+		return nil
+	case candle != nil && start.Equal(candle.Start):
+		candle.High = max(candle.High, quote.Price)
+		candle.Low = min(candle.Low, quote.Price)
+		candle.Close = quote.Price
+		candle.Ticks++
+		ca.mutex.Unlock()
+		return nil
+	case candle != nil:
+		finished = candle
+		ca.closed[quote.Symbol] = append(ca.closed[quote.Symbol], *candle)
+	}
+	ca.current[quote.Symbol] = &Candle{
+		Symbol: quote.Symbol,
+		Start:  start,
+		Open:   quote.Price,
+		High:   quote.Price,
+		Low:    quote.Price,
+		Close:  quote.Price,
+		Ticks:  1,
+	}
+	ca.mutex.Unlock()
+
+	if finished != nil && ca.onClose != nil {
+		ca.onClose(*finished)
+	}
+	return nil
+}
+
+// Candles returns the closed candles of a symbol followed by the one in progress
+func (ca *CandleAggregator) Candles(symbol string) []Candle {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	candles := append([]Candle(nil), ca.closed[symbol]...)
+	if current := ca.current[symbol]; current != nil {
+		candles = append(candles, *current)
+	}
+	return candles
+}
+
+// LateTicks returns how many ticks arrived after their interval had closed
+func (ca *CandleAggregator) LateTicks() int {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	return ca.late
+}
+
+// GetID returns the observer ID
+func (ca *CandleAggregator) GetID() string {
+	return ca.ID
+}
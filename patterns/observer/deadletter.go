@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrDeadLetterNotFound is returned when redriving an unknown dead letter
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// ErrSubscriptionGone is returned when the subscription of a dead letter no longer exists
+var ErrSubscriptionGone = errors.New("subscription no longer exists")
+
+// ErrQueueFull is returned by Redrive when an asynchronous subscription's
+// queue refuses the event
+var ErrQueueFull = errors.New("subscription queue refused the event")
+
+// DeadLetter is an event that could not be delivered to an observer
+type DeadLetter struct {
+	ID         uint64
+	Event      Event
+	EventType  string // subscription pattern the event was delivered through
+	ObserverID string
+	Err        error
+	Attempts   int
+	FailedAt   time.Time
+}
+
+// defaultMaxDeadLetters bounds a dead-letter store unless WithMaxDeadLetters says otherwise
+const defaultMaxDeadLetters = 10000
+
+// DeadLetterStore keeps failed deliveries for inspection and redrive. Once it
+// is full the oldest letters are dropped to make room.
+type DeadLetterStore struct {
+	mutex      sync.Mutex
+	letters    []DeadLetter
+	nextID     uint64
+	maxLetters int
+	dropped    uint64
+}
+
+// DeadLetterOption configures a DeadLetterStore
+type DeadLetterOption func(*DeadLetterStore)
+
+// WithMaxDeadLetters sets how many letters are kept; the default is 10000
+func WithMaxDeadLetters(n int) DeadLetterOption {
+	return func(s *DeadLetterStore) {
+		s.maxLetters = n
+	}
+}
+
+// NewDeadLetterStore creates an empty dead-letter store
+func NewDeadLetterStore(opts ...DeadLetterOption) *DeadLetterStore {
+	s := &DeadLetterStore{maxLetters: defaultMaxDeadLetters}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.maxLetters <= 0 {
+		panic("dead-letter store limit must be positive")
+	}
+	return s
+}
+
+// add records a failed delivery and returns its ID
+func (s *DeadLetterStore) add(letter DeadLetter) uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.nextID++
+	letter.ID = s.nextID
+	if over := len(s.letters) + 1 - s.maxLetters; over > 0 {
+		s.letters = append(s.letters[:0], s.letters[over:]...)
+		s.dropped += uint64(over)
+	}
+	s.letters = append(s.letters, letter)
+	return letter.ID
+}
+
+// get returns the dead letter with the given ID without removing it
+func (s *DeadLetterStore) get(id uint64) (DeadLetter, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, letter := range s.letters {
+		if letter.ID == id {
+			return letter, true
+		}
+	}
+	return DeadLetter{}, false
+}
+
+// take removes and returns the dead letter with the given ID
+func (s *DeadLetterStore) take(id uint64) (DeadLetter, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, letter := range s.letters {
+		if letter.ID == id {
+			s.letters = append(s.letters[:i:i], s.letters[i+1:]...)
+			return letter, true
+		}
+	}
+	return DeadLetter{}, false
+}
+
+// List returns all dead letters, oldest first
+func (s *DeadLetterStore) List() []DeadLetter {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]DeadLetter(nil), s.letters...)
+}
+
+// Len returns the number of dead letters
+func (s *DeadLetterStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.letters)
+}
+
+// Dropped returns how many letters were discarded because the store was full
+func (s *DeadLetterStore) Dropped() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.dropped
+}
+
+// WithDeadLetterStore sends events that exhaust their retries to store
+// instead of the dispatcher's own in-memory store
+func WithDeadLetterStore(store *DeadLetterStore) DispatcherOption {
+	return func(ed *EventDispatcher) {
+		ed.deadLetters = store
+	}
+}
+
+// DeadLetters returns the dispatcher's dead-letter store
+func (ed *EventDispatcher) DeadLetters() *DeadLetterStore {
+	return ed.deadLetters
+}
+
+// Redrive delivers a dead letter again through its original subscription.
+// If it fails again it goes back to the store under a new ID. A letter
+// whose subscription is gone stays in the store under its ID.
+//
+// An asynchronous subscription gets the event through its queue, so that
+// its observer still sees one delivery at a time in lane order. Such a
+// redrive is fire-and-forget: nil means the event was queued, and a failed
+// delivery shows up as a new dead letter. If the queue refuses the event
+// it goes back to the store under a new ID.
+func (ed *EventDispatcher) Redrive(id uint64) error {
+	letter, ok := ed.deadLetters.get(id)
+	if !ok {
+		return fmt.Errorf("redrive %d: %w", id, ErrDeadLetterNotFound)
+	}
+
+	var target *subscription
+	for _, sub := range ed.subscriptions() {
+		if sub.eventType == letter.EventType && sub.observer.GetID() == letter.ObserverID {
+			target = sub
+			break
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("redrive %d to %s: %w", id, letter.ObserverID, ErrSubscriptionGone)
+	}
+	// Another Redrive of the same letter may have taken it meanwhile
+	if _, ok := ed.deadLetters.take(id); !ok {
+		return fmt.Errorf("redrive %d: %w", id, ErrDeadLetterNotFound)
+	}
+	if !target.async() {
+		return target.process(letter.Event)
+	}
+	if !target.laneFor(letter.Event).push(letter.Event) {
+		ed.deadLetters.add(letter)
+		return fmt.Errorf("redrive %d to %s: %w", id, letter.ObserverID, ErrQueueFull)
+	}
+	return nil
+}
+
+// RedriveAll redrives every dead letter and reports how many succeeded
+func (ed *EventDispatcher) RedriveAll() (succeeded, failed int) {
+	for _, letter := range ed.deadLetters.List() {
+		if err := ed.Redrive(letter.ID); err != nil {
+			failed++
+		} else {
+			succeeded++
+		}
+	}
+	return succeeded, failed
+}
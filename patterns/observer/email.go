@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrEmailDropped reports events an EmailNotifier gave up on, because they
+// could not be rendered or the mail server kept rejecting them
+var ErrEmailDropped = errors.New("email dropped")
+
+// Digest retry defaults, see WithDigestRetry
+const (
+	defaultDigestAttempts = 3
+	defaultMaxPending     = 1000
+)
+
+// SMTPConfig describes the mail server an EmailNotifier sends through
+type SMTPConfig struct {
+	Addr      string // host:port
+	From      string
+	Username  string // AUTH PLAIN is used when set
+	Password  string
+	StartTLS  bool          // upgrade the connection before authenticating
+	TLSConfig *tls.Config   // defaults to verifying the host of Addr
+	LocalName string        // EHLO name, "localhost" when empty
+	Timeout   time.Duration // per message, 30s when zero
+}
+
+// EmailTemplate renders the subject and bodies of one event type.
+// Templates are executed with the Event; HTML is optional.
+type EmailTemplate struct {
+	Subject *texttemplate.Template
+	Text    *texttemplate.Template
+	HTML    *htmltemplate.Template
+}
+
+// ParseEmailTemplate parses the parts of an EmailTemplate; html may be empty
+// to send plain-text messages only
+func ParseEmailTemplate(subject, text, html string) (*EmailTemplate, error) {
+	tmpl := &EmailTemplate{}
+	var err error
+	if tmpl.Subject, err = texttemplate.New("subject").Parse(subject); err != nil {
+		return nil, err
+	}
+	if tmpl.Text, err = texttemplate.New("text").Parse(text); err != nil {
+		return nil, err
+	}
+	if html != "" {
+		if tmpl.HTML, err = htmltemplate.New("html").Parse(html); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
+
+// mustParseEmailTemplate is ParseEmailTemplate for the built-in templates
+func mustParseEmailTemplate(subject, text, html string) *EmailTemplate {
+	tmpl, err := ParseEmailTemplate(subject, text, html)
+	if err != nil {
+		panic(err)
+	}
+	return tmpl
+}
+
+// defaultEmailTemplates are used for event kinds without a custom template
+var defaultEmailTemplates = map[string]*EmailTemplate{
+	"price_update": mustParseEmailTemplate(
+		`{{.Data.Symbol}} is now ${{printf "%.2f" .Data.Price}}`,
+		`{{.Data.Symbol}} price changed to ${{printf "%.2f" .Data.Price}} (change: {{printf "%+.2f" .Data.Change}}) at {{.Timestamp.Format "15:04:05"}}`,
+		`<p><strong>{{.Data.Symbol}}</strong> price changed to ${{printf "%.2f" .Data.Price}} (change: {{printf "%+.2f" .Data.Change}}) at {{.Timestamp.Format "15:04:05"}}</p>`,
+	),
+	"system_alert": mustParseEmailTemplate(
+		`ALERT from {{.Source}}`,
+		`{{.Data}} at {{.Timestamp.Format "15:04:05"}}`,
+		`<p><strong>ALERT:</strong> {{.Data}} at {{.Timestamp.Format "15:04:05"}}</p>`,
+	),
+}
+
+// fallbackEmailTemplate renders events of unknown kinds
+var fallbackEmailTemplate = mustParseEmailTemplate(
+	`{{.Type}} from {{.Source}}`,
+	`{{.Type}}: {{.Data}} at {{.Timestamp.Format "15:04:05"}}`,
+	"",
+)
+
+// EmailOption configures an EmailNotifier
+type EmailOption func(*EmailNotifier)
+
+// WithSMTP sends messages through a mail server instead of printing them
+func WithSMTP(cfg SMTPConfig) EmailOption {
+	return func(en *EmailNotifier) {
+		en.smtp = &cfg
+	}
+}
+
+// WithEmailTemplate overrides the template of one event kind, such as
+// "price_update"; topic events use their last segment
+func WithEmailTemplate(kind string, tmpl *EmailTemplate) EmailOption {
+	return func(en *EmailNotifier) {
+		en.templates[kind] = tmpl
+	}
+}
+
+// WithDigest collects events arriving within window into a single digest
+// message. A digest is sent early once it holds maxEvents events; zero means
+// no limit.
+func WithDigest(window time.Duration, maxEvents int) EmailOption {
+	return func(en *EmailNotifier) {
+		en.digestWindow = window
+		en.digestMax = maxEvents
+	}
+}
+
+// WithDigestRetry sets how many times a digest is sent before its events
+// are dropped, and how many events may wait for the next digest before the
+// oldest are dropped. It panics unless both are at least 1.
+func WithDigestRetry(attempts, maxPending int) EmailOption {
+	if attempts < 1 || maxPending < 1 {
+		panic(fmt.Sprintf("digest retry needs at least 1 attempt and 1 pending event, got %d and %d", attempts, maxPending))
+	}
+	return func(en *EmailNotifier) {
+		en.digestAttempts = attempts
+		en.maxPending = maxPending
+	}
+}
+
+// WithEmailErrorHandler receives failures of digests sent outside of a Flush
+// or Close call, such as when their window closes. By default they are
+// printed.
+func WithEmailErrorHandler(handler func(error)) EmailOption {
+	return func(en *EmailNotifier) {
+		en.onError = handler
+	}
+}
+
+// EmailNotifier implements Observer for email notifications.
+// Without WithSMTP it prints the rendered message instead of sending it.
+// An event of a digest that cannot be rendered is dropped and reported. A
+// digest the mail server fails with a temporary error is sent again with the
+// next one, up to the retry limit; a permanent rejection drops it.
+type EmailNotifier struct {
+	ID    string
+	Email string
+
+	smtp           *SMTPConfig
+	templates      map[string]*EmailTemplate
+	digestWindow   time.Duration
+	digestMax      int
+	digestAttempts int
+	maxPending     int
+	onError        func(error)
+
+	mutex    sync.Mutex
+	pending  []pendingEmail
+	timer    *time.Timer
+	retrying bool // a digest failed; wait for the window instead of sending early
+	closed   bool
+}
+
+// pendingEmail is an event waiting for the next digest
+type pendingEmail struct {
+	event    Event
+	attempts int // failed sends so far
+}
+
+// NewEmailNotifier creates a new email notifier
+func NewEmailNotifier(id, email string, opts ...EmailOption) *EmailNotifier {
+	en := &EmailNotifier{
+		ID:             id,
+		Email:          email,
+		templates:      make(map[string]*EmailTemplate),
+		digestAttempts: defaultDigestAttempts,
+		maxPending:     defaultMaxPending,
+	}
+	for kind, tmpl := range defaultEmailTemplates {
+		en.templates[kind] = tmpl
+	}
+	en.onError = func(err error) {
+		fmt.Printf("📧 Digest to %s failed: %v\n", en.Email, err)
+	}
+	for _, opt := range opts {
+		opt(en)
+	}
+	return en
+}
+
+// Update sends the event, or adds it to the pending digest. Failures of a
+// digest sent early because it is full, and events dropped because too many
+// are pending, go to the error handler.
+func (en *EmailNotifier) Update(event Event) error {
+	en.mutex.Lock()
+	if en.digestWindow <= 0 || en.closed {
+		en.mutex.Unlock()
+		return en.send(event)
+	}
+
+	en.pending = append(en.pending, pendingEmail{event: event})
+	if dropped := en.trimPendingLocked(); dropped > 0 {
+		en.onError(fmt.Errorf("%w: %d events over the pending limit of %d", ErrEmailDropped, dropped, en.maxPending))
+	}
+	if en.digestMax > 0 && len(en.pending) >= en.digestMax && !en.retrying {
+		en.mutex.Unlock()
+		if err := en.Flush(); err != nil {
+			en.onError(err)
+		}
+		return nil
+	}
+	en.startTimerLocked()
+	en.mutex.Unlock()
+	return nil
+}
+
+// Flush sends the pending digest without waiting for its window to close.
+// Events that cannot be rendered are dropped; the rest are sent, and kept
+// for the next window if the mail server fails with a temporary error.
+func (en *EmailNotifier) Flush() error {
+	en.mutex.Lock()
+	batch := en.takePendingLocked()
+	en.mutex.Unlock()
+
+	var errs []error
+	var sent []pendingEmail
+	var contents []emailContent
+	for _, p := range batch {
+		content, err := en.render(p.event)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %w", ErrEmailDropped, err))
+			continue
+		}
+		sent = append(sent, p)
+		contents = append(contents, content)
+	}
+	if len(contents) > 0 {
+		if err := en.deliver(digestContent(contents)); err != nil {
+			errs = append(errs, en.requeue(sent, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// requeue puts a digest that failed to send back in front of the pending
+// events. It is dropped instead on a permanent error, after Close, or once
+// it has used up its attempts.
+func (en *EmailNotifier) requeue(batch []pendingEmail, err error) error {
+	en.mutex.Lock()
+	defer en.mutex.Unlock()
+
+	var retry []pendingEmail
+	for _, p := range batch {
+		p.attempts++
+		if !en.closed && temporarySMTPError(err) && p.attempts < en.digestAttempts {
+			retry = append(retry, p)
+		}
+	}
+	en.pending = append(retry, en.pending...)
+	dropped := len(batch) - len(retry) + en.trimPendingLocked()
+	if len(en.pending) > 0 && !en.closed {
+		en.retrying = true
+		en.startTimerLocked()
+	}
+	if dropped > 0 {
+		return fmt.Errorf("%w: %d events: %w", ErrEmailDropped, dropped, err)
+	}
+	return err
+}
+
+// trimPendingLocked drops the oldest pending events over the limit and
+// returns how many; the caller holds the mutex
+func (en *EmailNotifier) trimPendingLocked() int {
+	over := len(en.pending) - en.maxPending
+	if over <= 0 {
+		return 0
+	}
+	en.pending = slices.Delete(en.pending, 0, over)
+	return over
+}
+
+// temporarySMTPError reports whether sending again may succeed: SMTP 4xx
+// replies and network errors are temporary, 5xx replies are not
+func temporarySMTPError(err error) bool {
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return reply.Code < 500
+	}
+	return true
+}
+
+// Close stops the digest timer and sends the pending digest. Events arriving
+// later are sent one by one.
+func (en *EmailNotifier) Close() error {
+	en.mutex.Lock()
+	en.closed = true
+	if en.timer != nil {
+		en.timer.Stop()
+		en.timer = nil
+	}
+	en.mutex.Unlock()
+	return en.Flush()
+}
+
+// startTimerLocked sends the digest once its window closes, unless a timer
+// is already running; the caller holds the mutex
+func (en *EmailNotifier) startTimerLocked() {
+	if en.timer != nil {
+		return
+	}
+	en.timer = time.AfterFunc(en.digestWindow, func() {
+		if err := en.Flush(); err != nil {
+			en.onError(err)
+		}
+	})
+}
+
+// takePendingLocked empties the digest; the caller holds the mutex
+func (en *EmailNotifier) takePendingLocked() []pendingEmail {
+	batch := en.pending
+	en.pending = nil
+	en.retrying = false
+	if en.timer != nil {
+		en.timer.Stop()
+		en.timer = nil
+	}
+	return batch
+}
+
+// GetID returns the observer ID
+func (en *EmailNotifier) GetID() string {
+	return en.ID
+}
+
+// emailContent is a rendered message
+type emailContent struct {
+	subject string
+	text    string
+	html    string // empty for plain-text messages
+}
+
+// send renders one event and delivers it
+func (en *EmailNotifier) send(event Event) error {
+	content, err := en.render(event)
+	if err != nil {
+		return err
+	}
+	return en.deliver(content)
+}
+
+// deliver sends a rendered message
+func (en *EmailNotifier) deliver(content emailContent) error {
+	if en.smtp == nil {
+		fmt.Printf("📧 EMAIL to %s: %s\n     %s\n",
+			en.Email, content.subject, strings.ReplaceAll(content.text, "\n", "\n     "))
+		// Simulate email sending delay
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}
+	message, err := buildEmailMessage(en.smtp.From, en.Email, content, time.Now())
+	if err != nil {
+		return err
+	}
+	return sendSMTP(*en.smtp, en.Email, message)
+}
+
+// render executes the template of the event's kind
+func (en *EmailNotifier) render(event Event) (emailContent, error) {
+	tmpl, ok := en.templates[eventKind(event.Type)]
+	if !ok {
+		tmpl = fallbackEmailTemplate
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.Subject.Execute(&subject, event); err != nil {
+		return emailContent{}, fmt.Errorf("render %s subject: %w", event.Type, err)
+	}
+	if err := tmpl.Text.Execute(&text, event); err != nil {
+		return emailContent{}, fmt.Errorf("render %s text: %w", event.Type, err)
+	}
+	if tmpl.HTML != nil {
+		if err := tmpl.HTML.Execute(&html, event); err != nil {
+			return emailContent{}, fmt.Errorf("render %s html: %w", event.Type, err)
+		}
+	}
+	return emailContent{subject: subject.String(), text: text.String(), html: html.String()}, nil
+}
+
+// digestContent combines rendered events into one message; a lone event is
+// sent as is, and events whose template has no HTML part are included as
+// preformatted text
+func digestContent(contents []emailContent) emailContent {
+	if len(contents) == 1 {
+		return contents[0]
+	}
+	var text, html strings.Builder
+	for i, content := range contents {
+		if i > 0 {
+			text.WriteString("\n")
+			html.WriteString("<hr>\n")
+		}
+		fmt.Fprintf(&text, "- %s\n  %s", content.subject, strings.ReplaceAll(content.text, "\n", "\n  "))
+		if content.html != "" {
+			html.WriteString(content.html)
+		} else {
+			fmt.Fprintf(&html, "<pre>%s</pre>", htmltemplate.HTMLEscapeString(content.text))
+		}
+		html.WriteString("\n")
+	}
+	return emailContent{
+		subject: fmt.Sprintf("Digest: %d events", len(contents)),
+		text:    text.String(),
+		html:    html.String(),
+	}
+}
+
+// buildEmailMessage formats a MIME message, multipart/alternative when it has an HTML part
+func buildEmailMessage(from, to string, content emailContent, at time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", content.subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", at.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@go-demo>\r\n", uuid.NewString())
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if content.html == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&buf, content.text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", content.text},
+		{"text/html; charset=utf-8", content.html},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// writeQuotedPrintable encodes s onto w
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// sendSMTP delivers one message, upgrading to TLS and authenticating as configured
+func sendSMTP(cfg SMTPConfig, to string, message []byte) error {
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp address %q: %w", cfg.Addr, err)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	conn, err := net.DialTimeout("tcp", cfg.Addr, timeout)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	localName := cfg.LocalName
+	if localName == "" {
+		localName = "localhost"
+	}
+	if err := client.Hello(localName); err != nil {
+		return err
+	}
+	if cfg.StartTLS {
+		tlsConfig := cfg.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{ServerName: host}
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := client.Mail(cfg.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// capturedMail is a message accepted by fakeSMTPServer
+type capturedMail struct {
+	From     string
+	To       []string
+	Data     []byte
+	TLS      bool
+	AuthUser string
+}
+
+// fakeSMTPServer is a minimal in-process SMTP server that captures messages.
+// It offers STARTTLS when given a certificate and AUTH PLAIN when given users.
+type fakeSMTPServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	users     map[string]string
+
+	mutex    sync.Mutex
+	messages []capturedMail
+	received chan struct{}
+	busy     int // MAIL commands still to refuse with a temporary error
+}
+
+func newFakeSMTPServer(t *testing.T, tlsConfig *tls.Config, users map[string]string) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTPServer{
+		listener:  listener,
+		tlsConfig: tlsConfig,
+		users:     users,
+		received:  make(chan struct{}, 100),
+	}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *fakeSMTPServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeSMTPServer) Messages() []capturedMail {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]capturedMail(nil), s.messages...)
+}
+
+// wait blocks until n messages have been captured
+func (s *fakeSMTPServer) wait(t *testing.T, n int) []capturedMail {
+	t.Helper()
+	deadline := time.After(2 * time.Second)
+	for len(s.Messages()) < n {
+		select {
+		case <-s.received:
+		case <-deadline:
+			t.Fatalf("captured %d messages, want %d", len(s.Messages()), n)
+		}
+	}
+	return s.Messages()
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	var current capturedMail
+	text.PrintfLine("220 fake.smtp ESMTP ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			lines := []string{"250-fake.smtp"}
+			if s.tlsConfig != nil && !current.TLS {
+				lines = append(lines, "250-STARTTLS")
+			}
+			if s.users != nil {
+				lines = append(lines, "250-AUTH PLAIN")
+			}
+			lines = append(lines, "250 8BITMIME")
+			for _, l := range lines {
+				text.PrintfLine("%s", l)
+			}
+		case "STARTTLS":
+			if s.tlsConfig == nil {
+				text.PrintfLine("502 not supported")
+				continue
+			}
+			text.PrintfLine("220 go ahead")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			text = textproto.NewConn(conn)
+			current = capturedMail{TLS: true}
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			decoded, err := base64.StdEncoding.DecodeString(initial)
+			parts := strings.Split(string(decoded), "\x00")
+			if mechanism != "PLAIN" || err != nil || len(parts) != 3 || s.users[parts[1]] != parts[2] || parts[2] == "" {
+				text.PrintfLine("535 authentication failed")
+				continue
+			}
+			current.AuthUser = parts[1]
+			text.PrintfLine("235 authenticated")
+		case "MAIL":
+			if s.users != nil && current.AuthUser == "" {
+				text.PrintfLine("530 authentication required")
+				continue
+			}
+			s.mutex.Lock()
+			busy := s.busy > 0
+			if busy {
+				s.busy--
+			}
+			s.mutex.Unlock()
+			if busy {
+				text.PrintfLine("451 try again later")
+				continue
+			}
+			from, _, _ := strings.Cut(strings.TrimPrefix(arg, "FROM:"), " ") // drop parameters such as BODY=8BITMIME
+			current.From = strings.Trim(from, "<>")
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			current.To = append(current.To, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 end with .")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			current.Data = data
+			s.mutex.Lock()
+			s.messages = append(s.messages, current)
+			s.mutex.Unlock()
+			s.received <- struct{}{}
+			current = capturedMail{TLS: current.TLS, AuthUser: current.AuthUser}
+			text.PrintfLine("250 queued")
+		case "RSET", "NOOP":
+			text.PrintfLine("250 ok")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("502 unknown command")
+		}
+	}
+}
+
+// selfSignedTLS returns a server config for 127.0.0.1 and a client config trusting it
+func selfSignedTLS(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake.smtp"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+	return server, client
+}
+
+// parseMail returns the decoded subject and the text and html bodies of a message
+func parseMail(t *testing.T, data []byte) (subject, text, html string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+		return subject, string(body), ""
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return subject, text, html
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// multipart decodes quoted-printable parts itself
+		body, _ := io.ReadAll(part)
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/html") {
+			html = string(body)
+		} else {
+			text = string(body)
+		}
+	}
+}
+
+func TestEmailNotifier_SendsRenderedTemplates(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, nil)
+	email := NewEmailNotifier("email", "trader@example.com", WithSMTP(SMTPConfig{
+		Addr: server.Addr(),
+		From: "alerts@example.com",
+	}))
+
+	if err := email.Update(stockEvent("AAPL", 150, 2.5)); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := server.wait(t, 1)[0]
+	if msg.From != "alerts@example.com" || len(msg.To) != 1 || msg.To[0] != "trader@example.com" {
+		t.Fatalf("unexpected envelope %q -> %v", msg.From, msg.To)
+	}
+	subject, text, html := parseMail(t, msg.Data)
+	if subject != "AAPL is now $150.00" {
+		t.Fatalf("subject = %q", subject)
+	}
+	if !strings.Contains(text, "change: +2.50") {
+		t.Fatalf("text body = %q", text)
+	}
+	if !strings.Contains(html, "<strong>AAPL</strong>") {
+		t.Fatalf("html body = %q", html)
+	}
+}
+
+func TestEmailNotifier_CustomTemplateEscapesHTML(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, nil)
+	tmpl, err := ParseEmailTemplate(`[{{.Data.Severity}}] alert`, `{{.Data.Message}}`, `<p>{{.Data.Message}}</p>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := NewEmailNotifier("email", "ops@example.com",
+		WithSMTP(SMTPConfig{Addr: server.Addr(), From: "alerts@example.com"}),
+		WithEmailTemplate("system_alert", tmpl))
+
+	alert := Event{Type: "system_alert", Data: SystemAlert{Severity: "CRIT", Message: "<script>x</script> ünïcode"}, Source: "monitor"}
+	if err := email.Update(alert); err != nil {
+		t.Fatal(err)
+	}
+
+	subject, text, html := parseMail(t, server.wait(t, 1)[0].Data)
+	if subject != "[CRIT] alert" || text != "<script>x</script> ünïcode" {
+		t.Fatalf("subject=%q text=%q", subject, text)
+	}
+	if strings.Contains(html, "<script>") || !strings.Contains(html, "&lt;script&gt;") {
+		t.Fatalf("html body was not escaped: %q", html)
+	}
+}
+
+func TestEmailNotifier_TemplateErrorFailsDelivery(t *testing.T) {
+	email := NewEmailNotifier("email", "trader@example.com")
+	// A price_update without a StockQuote cannot be rendered
+	if err := email.Update(Event{Type: "price_update", Data: "not a quote"}); err == nil {
+		t.Fatal("expected a render error")
+	}
+}
+
+func TestEmailNotifier_StartTLSAndAuth(t *testing.T) {
+	serverTLS, clientTLS := selfSignedTLS(t)
+	server := newFakeSMTPServer(t, serverTLS, map[string]string{"alice": "s3cret"})
+	cfg := SMTPConfig{
+		Addr:      server.Addr(),
+		From:      "alerts@example.com",
+		Username:  "alice",
+		Password:  "s3cret",
+		StartTLS:  true,
+		TLSConfig: clientTLS,
+	}
+	email := NewEmailNotifier("email", "trader@example.com", WithSMTP(cfg))
+
+	if err := email.Update(stockEvent("AAPL", 150, 1)); err != nil {
+		t.Fatal(err)
+	}
+	msg := server.wait(t, 1)[0]
+	if !msg.TLS || msg.AuthUser != "alice" {
+		t.Fatalf("expected an authenticated TLS session, got tls=%v user=%q", msg.TLS, msg.AuthUser)
+	}
+
+	cfg.Password = "wrong"
+	rejected := NewEmailNotifier("email", "trader@example.com", WithSMTP(cfg))
+	if err := rejected.Update(stockEvent("AAPL", 151, 1)); err == nil || !strings.Contains(err.Error(), "smtp auth") {
+		t.Fatalf("expected an auth error, got %v", err)
+	}
+}
+
+func TestEmailNotifier_StartTLSNotOffered(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, nil)
+	email := NewEmailNotifier("email", "trader@example.com", WithSMTP(SMTPConfig{
+		Addr:     server.Addr(),
+		From:     "alerts@example.com",
+		StartTLS: true,
+	}))
+	if err := email.Update(stockEvent("AAPL", 150, 1)); err == nil || !strings.Contains(err.Error(), "starttls") {
+		t.Fatalf("expected a starttls error, got %v", err)
+	}
+	if len(server.Messages()) != 0 {
+		t.Fatal("nothing should be sent in plain text")
+	}
+}
+
+func TestEmailNotifier_DigestBatchesBurst(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, nil)
+	email := NewEmailNotifier("email", "trader@example.com",
+		WithSMTP(SMTPConfig{Addr: server.Addr(), From: "alerts@example.com"}),
+		WithDigest(50*time.Millisecond, 0))
+
+	for _, symbol := range []string{"AAPL", "GOOGL", "MSFT"} {
+		if err := email.Update(stockEvent(symbol, 100, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(server.Messages()) != 0 {
+		t.Fatal("digest should wait for its window")
+	}
+
+	messages := server.wait(t, 1)
+	subject, text, html := parseMail(t, messages[0].Data)
+	if subject != "Digest: 3 events" {
+		t.Fatalf("subject = %q", subject)
+	}
+	for _, symbol := range []string{"AAPL", "GOOGL", "MSFT"} {
+		if !strings.Contains(text, symbol+" is now") || !strings.Contains(html, "<strong>"+symbol+"</strong>") {
+			t.Fatalf("digest is missing %s:\n%s\n%s", symbol, text, html)
+		}
+	}
+
+	time.Sleep(80 * time.Millisecond)
+	if len(server.Messages()) != 1 {
+		t.Fatalf("expected exactly one digest, got %d", len(server.Messages()))
+	}
+}
+
+func TestEmailNotifier_DigestMaxEventsAndFlush(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, nil)
+	email := NewEmailNotifier("email", "trader@example.com",
+		WithSMTP(SMTPConfig{Addr: server.Addr(), From: "alerts@example.com"}),
+		WithDigest(time.Hour, 2))
+
+	email.Update(stockEvent("AAPL", 100, 1))
+	if err := email.Update(stockEvent("GOOGL", 100, 1)); err != nil {
+		t.Fatal(err)
+	}
+	server.wait(t, 1)
+
+	// A lone pending event is sent as a regular message
+	email.Update(stockEvent("MSFT", 100, 1))
+	if err := email.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	subject, _, _ := parseMail(t, server.wait(t, 2)[1].Data)
+	if subject != "MSFT is now $100.00" {
+		t.Fatalf("subject = %q", subject)
+	}
+}
+
+func TestEmailNotifier_DigestErrorsReachHandler(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close() // nothing listens here any more
+
+	errs := make(chan error, 1)
+	email := NewEmailNotifier("email", "trader@example.com",
+		WithSMTP(SMTPConfig{Addr: addr, From: "alerts@example.com", Timeout: time.Second}),
+		WithDigest(10*time.Millisecond, 0),
+		WithDigestRetry(3, 10),
+		WithEmailErrorHandler(func(err error) { errs <- err }))
+
+	if err := email.Update(stockEvent("AAPL", 100, 1)); err != nil {
+		t.Fatal(err)
+	}
+	// The digest is sent once per window until it has used up its attempts
+	for attempt := 1; attempt <= 3; attempt++ {
+		select {
+		case err := <-errs:
+			if dropped := errors.Is(err, ErrEmailDropped); dropped != (attempt == 3) {
+				t.Fatalf("attempt %d: %v", attempt, err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("error handler was not called for attempt %d", attempt)
+		}
+	}
+	select {
+	case err := <-errs:
+		t.Fatalf("a dropped digest should not be retried: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := email.Close(); err != nil {
+		t.Fatalf("nothing should be pending: %v", err)
+	}
+}
+
+func TestEmailNotifier_FailedDigestIsKept(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, nil)
+	server.busy = 1
+	email := NewEmailNotifier("email", "trader@example.com",
+		WithSMTP(SMTPConfig{Addr: server.Addr(), From: "alerts@example.com"}),
+		WithDigest(time.Hour, 0))
+
+	email.Update(stockEvent("AAPL", 100, 1))
+	email.Update(stockEvent("GOOGL", 100, 1))
+	if err := email.Flush(); err == nil || errors.Is(err, ErrEmailDropped) {
+		t.Fatalf("expected a temporary error, got %v", err)
+	}
+
+	email.Update(stockEvent("MSFT", 100, 1))
+	if err := email.Close(); err != nil {
+		t.Fatal(err)
+	}
+	subject, text, _ := parseMail(t, server.wait(t, 1)[0].Data)
+	if subject != "Digest: 3 events" || !strings.Contains(text, "AAPL") {
+		t.Fatalf("the failed batch should be sent with the next digest, got %q:\n%s", subject, text)
+	}
+
+	// After Close events are sent right away
+	if err := email.Update(stockEvent("AAPL", 101, 1)); err != nil {
+		t.Fatal(err)
+	}
+	server.wait(t, 2)
+}
+
+func TestEmailNotifier_RejectedDigestIsDropped(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, map[string]string{"alice": "s3cret"})
+	email := NewEmailNotifier("email", "trader@example.com",
+		WithSMTP(SMTPConfig{Addr: server.Addr(), From: "alerts@example.com", Username: "alice", Password: "wrong"}),
+		WithDigest(time.Hour, 0))
+
+	email.Update(stockEvent("AAPL", 100, 1))
+	if err := email.Flush(); !errors.Is(err, ErrEmailDropped) {
+		t.Fatalf("a 5xx reply should drop the digest, got %v", err)
+	}
+
+	email.smtp.Password = "s3cret"
+	email.Update(stockEvent("GOOGL", 100, 1))
+	if err := email.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	subject, _, _ := parseMail(t, server.wait(t, 1)[0].Data)
+	if subject != "GOOGL is now $100.00" {
+		t.Fatalf("subject = %q", subject)
+	}
+}
+
+func TestEmailNotifier_BadEventDoesNotBlockDigest(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, nil)
+	email := NewEmailNotifier("email", "trader@example.com",
+		WithSMTP(SMTPConfig{Addr: server.Addr(), From: "alerts@example.com"}),
+		WithDigest(time.Hour, 0))
+
+	// A price_update without a StockQuote cannot be rendered
+	email.Update(Event{Type: "price_update", Data: "not a quote"})
+	email.Update(stockEvent("AAPL", 100, 1))
+	if err := email.Flush(); !errors.Is(err, ErrEmailDropped) {
+		t.Fatalf("expected the bad event to be reported, got %v", err)
+	}
+	subject, _, _ := parseMail(t, server.wait(t, 1)[0].Data)
+	if subject != "AAPL is now $100.00" {
+		t.Fatalf("subject = %q", subject)
+	}
+
+	email.Update(stockEvent("GOOGL", 100, 1))
+	if err := email.Flush(); err != nil {
+		t.Fatalf("the dropped event should not be retried: %v", err)
+	}
+	subject, _, _ = parseMail(t, server.wait(t, 2)[1].Data)
+	if subject != "GOOGL is now $100.00" {
+		t.Fatalf("subject = %q", subject)
+	}
+}
+
+func TestEmailNotifier_PendingIsBounded(t *testing.T) {
+	var dropped []error
+	email := NewEmailNotifier("email", "trader@example.com",
+		WithDigest(time.Hour, 0),
+		WithDigestRetry(1, 2),
+		WithEmailErrorHandler(func(err error) { dropped = append(dropped, err) }))
+	for _, symbol := range []string{"AAPL", "GOOGL", "MSFT"} {
+		email.Update(stockEvent(symbol, 100, 1))
+	}
+	if len(email.pending) != 2 || email.pending[0].event.Data.(StockQuote).Symbol != "GOOGL" {
+		t.Fatalf("expected the oldest event to be dropped, pending = %v", email.pending)
+	}
+	if len(dropped) != 1 || !errors.Is(dropped[0], ErrEmailDropped) {
+		t.Fatalf("errors = %v", dropped)
+	}
+	email.mutex.Lock()
+	email.takePendingLocked()
+	email.mutex.Unlock()
+}
@@ -0,0 +1,589 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FieldLookup resolves a field name used in a filter expression
+type FieldLookup func(name string) (interface{}, bool)
+
+// FilterExpr is a compiled filter expression such as
+//
+//	symbol == "AAPL" && abs(change) > 5
+//
+// It supports number, string and boolean literals, event fields, the
+// operators ! - * / + < <= > >= == != && || and parentheses, and the
+// functions abs, len, lower, upper, contains and hasPrefix.
+type FilterExpr struct {
+	source string
+	root   exprNode
+}
+
+// ParseFilterExpr compiles a filter expression
+func ParseFilterExpr(source string) (*FilterExpr, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %w", source, err)
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokEOF {
+		err = fmt.Errorf("unexpected %q at offset %d", p.peek().text, p.peek().pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %w", source, err)
+	}
+	return &FilterExpr{source: source, root: root}, nil
+}
+
+// String returns the expression source
+func (e *FilterExpr) String() string {
+	return e.source
+}
+
+// Eval evaluates the expression; it must produce a boolean
+func (e *FilterExpr) Eval(fields FieldLookup) (bool, error) {
+	v, err := e.root.eval(fields)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("filter %q evaluated to %v, not a boolean", e.source, v)
+	}
+	return b, nil
+}
+
+// EventFields exposes an event to filter expressions. The names type, source
+// and sequence refer to the event itself; any other name is looked up as a
+// case-insensitive field of the payload struct, and data is the payload itself.
+func EventFields(event Event) FieldLookup {
+	return func(name string) (interface{}, bool) {
+		switch name {
+		case "type":
+			return event.Type, true
+		case "source":
+			return event.Source, true
+		case "sequence":
+			return float64(event.Sequence), true
+		case "data":
+			return normalizeValue(reflect.ValueOf(event.Data))
+		}
+
+		v := reflect.ValueOf(event.Data)
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil, false
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return nil, false
+		}
+		field := v.FieldByNameFunc(func(fieldName string) bool {
+			return strings.EqualFold(fieldName, name)
+		})
+		if !field.IsValid() || !field.CanInterface() {
+			return nil, false
+		}
+		return normalizeValue(field)
+	}
+}
+
+// normalizeValue maps Go values onto the expression types float64, string and bool
+func normalizeValue(v reflect.Value) (interface{}, bool) {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return v.Bool(), true
+	default:
+		return nil, false
+	}
+}
+
+// exprNode is a node of the expression tree
+type exprNode interface {
+	eval(fields FieldLookup) (interface{}, error)
+}
+
+type literalNode struct{ value interface{} }
+
+func (n literalNode) eval(FieldLookup) (interface{}, error) {
+	return n.value, nil
+}
+
+type fieldNode struct{ name string }
+
+func (n fieldNode) eval(fields FieldLookup) (interface{}, error) {
+	v, ok := fields(n.name)
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", n.name)
+	}
+	return v, nil
+}
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+
+func (n unaryNode) eval(fields FieldLookup) (interface{}, error) {
+	v, err := n.operand.eval(fields)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "!":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("! needs a boolean, got %v", v)
+		}
+		return !b, nil
+	default:
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("- needs a number, got %v", v)
+		}
+		return -f, nil
+	}
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n binaryNode) eval(fields FieldLookup) (interface{}, error) {
+	left, err := n.left.eval(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// && and || short-circuit
+	if n.op == "&&" || n.op == "||" {
+		l, ok := left.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s needs booleans, got %v", n.op, left)
+		}
+		if (n.op == "&&" && !l) || (n.op == "||" && l) {
+			return l, nil
+		}
+		right, err := n.right.eval(fields)
+		if err != nil {
+			return nil, err
+		}
+		r, ok := right.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s needs booleans, got %v", n.op, right)
+		}
+		return r, nil
+	}
+
+	right, err := n.right.eval(fields)
+	if err != nil {
+		return nil, err
+	}
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot apply %s to %v and %v", n.op, left, right)
+		}
+		return applyNumber(n.op, l, r)
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot apply %s to %q and %v", n.op, l, right)
+		}
+		return applyString(n.op, l, r)
+	case bool:
+		r, ok := right.(bool)
+		if !ok || (n.op != "==" && n.op != "!=") {
+			return nil, fmt.Errorf("cannot apply %s to %v and %v", n.op, left, right)
+		}
+		return (l == r) == (n.op == "=="), nil
+	default:
+		return nil, fmt.Errorf("cannot apply %s to %v", n.op, left)
+	}
+}
+
+func applyNumber(op string, l, r float64) (interface{}, error) {
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	case "==":
+		return l == r, nil
+	case "!=":
+		return l != r, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", op)
+}
+
+func applyString(op string, l, r string) (interface{}, error) {
+	switch op {
+	case "+":
+		return l + r, nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	case "==":
+		return l == r, nil
+	case "!=":
+		return l != r, nil
+	}
+	return nil, fmt.Errorf("cannot apply %s to strings", op)
+}
+
+type callNode struct {
+	name string
+	args []exprNode
+}
+
+// exprFunctions are the functions available to filter expressions
+var exprFunctions = map[string]func(args []interface{}) (interface{}, error){
+	"abs": func(args []interface{}) (interface{}, error) {
+		f, err := numberArg("abs", args, 0, 1)
+		if err != nil {
+			return nil, err
+		}
+		return math.Abs(f), nil
+	},
+	"len": func(args []interface{}) (interface{}, error) {
+		s, err := stringArg("len", args, 0, 1)
+		if err != nil {
+			return nil, err
+		}
+		return float64(len(s)), nil
+	},
+	"lower": func(args []interface{}) (interface{}, error) {
+		s, err := stringArg("lower", args, 0, 1)
+		return strings.ToLower(s), err
+	},
+	"upper": func(args []interface{}) (interface{}, error) {
+		s, err := stringArg("upper", args, 0, 1)
+		return strings.ToUpper(s), err
+	},
+	"contains": func(args []interface{}) (interface{}, error) {
+		s, err := stringArg("contains", args, 0, 2)
+		if err != nil {
+			return nil, err
+		}
+		sub, err := stringArg("contains", args, 1, 2)
+		return strings.Contains(s, sub), err
+	},
+	"hasPrefix": func(args []interface{}) (interface{}, error) {
+		s, err := stringArg("hasPrefix", args, 0, 2)
+		if err != nil {
+			return nil, err
+		}
+		prefix, err := stringArg("hasPrefix", args, 1, 2)
+		return strings.HasPrefix(s, prefix), err
+	},
+}
+
+func numberArg(fn string, args []interface{}, i, want int) (float64, error) {
+	if len(args) != want {
+		return 0, fmt.Errorf("%s takes %d argument(s), got %d", fn, want, len(args))
+	}
+	f, ok := args[i].(float64)
+	if !ok {
+		return 0, fmt.Errorf("%s needs a number, got %v", fn, args[i])
+	}
+	return f, nil
+}
+
+func stringArg(fn string, args []interface{}, i, want int) (string, error) {
+	if len(args) != want {
+		return "", fmt.Errorf("%s takes %d argument(s), got %d", fn, want, len(args))
+	}
+	s, ok := args[i].(string)
+	if !ok {
+		return "", fmt.Errorf("%s needs a string, got %v", fn, args[i])
+	}
+	return s, nil
+}
+
+func (n callNode) eval(fields FieldLookup) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(fields)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return exprFunctions[n.name](args)
+}
+
+// tokenKind classifies lexer tokens
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// tokenize splits an expression into tokens
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(src) && unicode.IsDigit(rune(src[i+1]))):
+			start := i
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, src[start:i], start})
+		case c == '"' || c == '\'':
+			start := i
+			for i++; i < len(src) && rune(src[i]) != c; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			i++
+			tokens = append(tokens, token{tokString, src[start:i], start})
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, src[start:i], start})
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "(", ")", ","} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokEOF, "end of expression", len(src)}), nil
+}
+
+// exprParser is a recursive-descent parser over the token stream
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the given operators
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		return fmt.Errorf("expected %q at offset %d, got %q", op, p.peek().pos, p.peek().text)
+	}
+	return nil
+}
+
+// parseBinary parses a left-associative chain of operators over operand
+func (p *exprParser) parseBinary(operand func() (exprNode, error), ops ...string) (exprNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	return p.parseBinary(p.parseNot, "&&")
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if _, ok := p.accept("!"); ok {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{op: "!", operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	return binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	return p.parseBinary(p.parseProduct, "+", "-")
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	return p.parseBinary(p.parseNegation, "*", "/")
+}
+
+func (p *exprParser) parseNegation() (exprNode, error) {
+	if _, ok := p.accept("-"); ok {
+		operand, err := p.parseNegation()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{op: "-", operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", t.text, t.pos)
+		}
+		return literalNode{f}, nil
+	case tokString:
+		s := t.text
+		if s[0] == '\'' {
+			s = `"` + strings.ReplaceAll(s[1:len(s)-1], `"`, `\"`) + `"`
+		}
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s at offset %d", t.text, t.pos)
+		}
+		return literalNode{unquoted}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literalNode{true}, nil
+		case "false":
+			return literalNode{false}, nil
+		}
+		if _, ok := p.accept("("); !ok {
+			return fieldNode{t.text}, nil
+		}
+		if _, ok := exprFunctions[t.text]; !ok {
+			return nil, fmt.Errorf("unknown function %q at offset %d", t.text, t.pos)
+		}
+		call := callNode{name: t.text}
+		if _, ok := p.accept(")"); ok {
+			return call, nil
+		}
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if _, ok := p.accept(","); !ok {
+				break
+			}
+		}
+		return call, p.expect(")")
+	case tokOp:
+		if t.text == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// SignificantChangeFilter only lets through price moves larger than $5
+const SignificantChangeFilter = "abs(change) > 5"
+
+// subscriptionFilter is one filter attached to a subscription, with counters
+// that show operators how often it let events through
+type subscriptionFilter struct {
+	description string
+	match       func(Event) (bool, error)
+
+	passed   atomic.Uint64
+	rejected atomic.Uint64
+	errors   atomic.Uint64
+
+	mutex   sync.Mutex
+	lastErr error
+}
+
+// evaluate runs the filter and updates its counters; errors count as rejections
+func (f *subscriptionFilter) evaluate(event Event) (bool, error) {
+	ok, err := f.match(event)
+	switch {
+	case err != nil:
+		f.errors.Add(1)
+		f.mutex.Lock()
+		f.lastErr = err
+		f.mutex.Unlock()
+		return false, err
+	case ok:
+		f.passed.Add(1)
+	default:
+		f.rejected.Add(1)
+	}
+	return ok, nil
+}
+
+// WithFilter only delivers events for which predicate returns true.
+// The description is what Filters and Explain report for it.
+func WithFilter(description string, predicate func(Event) bool) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.filters = append(cfg.filters, &subscriptionFilter{
+			description: description,
+			match: func(event Event) (bool, error) {
+				return predicate(event), nil
+			},
+		})
+	}
+}
+
+// WithFilterExpr only delivers events for which the expression is true, e.g.
+// `symbol == "AAPL" && abs(change) > 5`. See FilterExpr for the syntax and
+// EventFields for the available fields. An invalid expression makes
+// Subscribe fail with the parse error.
+func WithFilterExpr(expr string) SubscribeOption {
+	compiled, err := ParseFilterExpr(expr)
+	return func(cfg *subscribeConfig) {
+		if err != nil {
+			if cfg.err == nil {
+				cfg.err = err
+			}
+			return
+		}
+		cfg.filters = append(cfg.filters, &subscriptionFilter{
+			description: compiled.String(),
+			match: func(event Event) (bool, error) {
+				return compiled.Eval(EventFields(event))
+			},
+		})
+	}
+}
+
+// accepts runs the subscription's filters in order, stopping at the first rejection
+func (s *subscription) accepts(event Event) bool {
+	for _, filter := range s.filters {
+		if ok, _ := filter.evaluate(event); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterInfo describes a filter attached to a subscription
+type FilterInfo struct {
+	ObserverID string
+	EventType  string
+	Filter     string
+	Passed     uint64
+	Rejected   uint64
+	Errors     uint64 // evaluations that failed, e.g. on an unknown field
+	LastError  error
+}
+
+// Filters lists every subscription filter with its pass/reject counters
+func (ed *EventDispatcher) Filters() []FilterInfo {
+	var infos []FilterInfo
+	for _, sub := range ed.subscriptions() {
+		for _, filter := range sub.filters {
+			filter.mutex.Lock()
+			lastErr := filter.lastErr
+			filter.mutex.Unlock()
+
+			infos = append(infos, FilterInfo{
+				ObserverID: sub.observer.GetID(),
+				EventType:  sub.eventType,
+				Filter:     filter.description,
+				Passed:     filter.passed.Load(),
+				Rejected:   filter.rejected.Load(),
+				Errors:     filter.errors.Load(),
+				LastError:  lastErr,
+			})
+		}
+	}
+	return infos
+}
+
+// FilterDecision explains whether a subscription would receive an event
+type FilterDecision struct {
+	ObserverID string
+	EventType  string
+	Delivered  bool
+	RejectedBy string // the first filter that rejected the event
+	Err        error  // set when that filter failed to evaluate
+}
+
+// String formats the decision for logs
+func (d FilterDecision) String() string {
+	switch {
+	case d.Delivered:
+		return fmt.Sprintf("%s (%s): delivered", d.ObserverID, d.EventType)
+	case d.Err != nil:
+		return fmt.Sprintf("%s (%s): rejected by %q: %v", d.ObserverID, d.EventType, d.RejectedBy, d.Err)
+	default:
+		return fmt.Sprintf("%s (%s): rejected by %q", d.ObserverID, d.EventType, d.RejectedBy)
+	}
+}
+
+// Explain reports, for every subscription matching the event's type, whether
+// its filters would let the event through. It does not deliver the event or
+// change any filter counters.
+func (ed *EventDispatcher) Explain(event Event) []FilterDecision {
+	ed.mutex.RLock()
+	subs := ed.topics.match(event.Type)
+	ed.mutex.RUnlock()
+
+	decisions := make([]FilterDecision, 0, len(subs))
+	for _, sub := range subs {
+		decision := FilterDecision{ObserverID: sub.observer.GetID(), EventType: sub.eventType, Delivered: true}
+		for _, filter := range sub.filters {
+			ok, err := filter.match(event)
+			if !ok || err != nil {
+				decision.Delivered = false
+				decision.RejectedBy = filter.description
+				decision.Err = err
+				break
+			}
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions
+}
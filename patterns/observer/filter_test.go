@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func stockEvent(symbol string, price, change float64) Event {
+	return Event{
+		Type:   "price_update",
+		Data:   StockQuote{Symbol: symbol, Price: price, Change: change},
+		Source: "StockPrice-" + symbol,
+	}
+}
+
+func TestFilterExpr_Eval(t *testing.T) {
+	event := stockEvent("AAPL", 160, -7.5)
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`symbol == "AAPL" && abs(change) > 5`, true},
+		{`symbol == 'MSFT' || price >= 200`, false},
+		{`!(change > 0) && price - change == 167.5`, true},
+		{`-change * 2 / 3 == 5`, true},
+		{`lower(symbol) == "aapl" && len(source) == 15`, true},
+		{`contains(source, "AAPL") && hasPrefix(type, "price")`, true},
+		{`Symbol != "AAPL" || false`, false},
+		{`1 + 2 * 3 == 7`, true},
+	}
+	for _, tt := range tests {
+		expr, err := ParseFilterExpr(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		got, err := expr.Eval(EventFields(event))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.expr, tt.want, got)
+		}
+	}
+}
+
+func TestFilterExpr_Errors(t *testing.T) {
+	parseErrors := []string{`symbol ==`, `abs(change`, `"open`, `symbol # 1`, `nope(1)`, `(1 > 0))`}
+	for _, src := range parseErrors {
+		if _, err := ParseFilterExpr(src); err == nil {
+			t.Errorf("%s: expected parse error", src)
+		}
+	}
+
+	evalErrors := []string{`volume > 1`, `symbol > 1`, `price`, `abs(symbol) > 1`, `price / 0 > 1`}
+	for _, src := range evalErrors {
+		expr, err := ParseFilterExpr(src)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		if _, err := expr.Eval(EventFields(stockEvent("AAPL", 1, 1))); err == nil {
+			t.Errorf("%s: expected evaluation error", src)
+		}
+	}
+}
+
+func TestEventDispatcher_FiltersBeforeDelivery(t *testing.T) {
+	dispatcher := NewEventDispatcher()
+	sms := &recordingObserver{id: "sms"}
+	dispatcher.Subscribe("price_update", sms, WithFilterExpr(SignificantChangeFilter))
+
+	dispatcher.Notify(stockEvent("AAPL", 150, 2))
+	dispatcher.Notify(Event{Type: "price_update", Data: "not a stock"})
+
+	infos := dispatcher.Filters()
+	if len(infos) != 1 || infos[0].Rejected != 1 || infos[0].Errors != 1 || infos[0].Passed != 0 {
+		t.Fatalf("unexpected filter info: %+v", infos)
+	}
+	if !strings.Contains(infos[0].LastError.Error(), "unknown field") {
+		t.Fatalf("unexpected last error: %v", infos[0].LastError)
+	}
+
+	decisions := dispatcher.Explain(stockEvent("AAPL", 150, 2))
+	if len(decisions) != 1 || decisions[0].Delivered || decisions[0].RejectedBy != SignificantChangeFilter {
+		t.Fatalf("unexpected decisions: %+v", decisions)
+	}
+	if len(sms.prices()) != 0 {
+		t.Fatal("filtered events must not reach the observer")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrDuplicateObserverID is returned when a different observer already
+// subscribed under the same ID
+var ErrDuplicateObserverID = errors.New("observer ID already in use by another observer")
+
+// ErrDispatcherClosed is returned when subscribing to a closed dispatcher
+var ErrDispatcherClosed = errors.New("dispatcher is closed")
+
+// Subscription is the handle returned by Subscribe. Unsubscribe removes
+// exactly this subscription, regardless of other subscriptions of the same
+// observer.
+type Subscription struct {
+	dispatcher *EventDispatcher
+	sub        *subscription
+	once       sync.Once
+	done       chan struct{}
+	mutex      sync.Mutex  // guards stopCtx and ended, which may change while the context fires
+	stopCtx    func() bool // detaches the context watcher of SubscribeContext
+	ended      bool
+}
+
+// EventType returns the pattern the subscription was made for
+func (s *Subscription) EventType() string {
+	return s.sub.eventType
+}
+
+// ObserverID returns the ID of the subscribed observer
+func (s *Subscription) ObserverID() string {
+	return s.sub.observer.GetID()
+}
+
+// Done is closed once the subscription has been removed, whether through
+// Unsubscribe, EventDispatcher.Unsubscribe or EventDispatcher.Close
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Unsubscribe removes the subscription and drains its queue, if any.
+// Calling it more than once is harmless.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.dispatcher.remove(s.sub.eventType, func(sub *subscription) bool { return sub == s.sub })
+		// Already removed another way, such as by Close
+		s.end()
+	})
+}
+
+// end closes Done and detaches the context watcher, once, however the
+// subscription was removed
+func (s *Subscription) end() {
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	stop := s.stopCtx
+	s.mutex.Unlock()
+	if stop != nil {
+		stop()
+	}
+	close(s.done)
+}
+
+// SubscribeContext subscribes like Subscribe and unsubscribes automatically
+// when ctx is cancelled
+func (ed *EventDispatcher) SubscribeContext(ctx context.Context, eventType string, observer Observer, opts ...SubscribeOption) (*Subscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	handle, err := ed.Subscribe(eventType, observer, opts...)
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, handle.Unsubscribe)
+	handle.mutex.Lock()
+	ended := handle.ended
+	handle.stopCtx = stop
+	handle.mutex.Unlock()
+	if ended {
+		// Removed before the watcher was in place, e.g. by Close
+		stop()
+	}
+	return handle, nil
+}
+
+// observerRef counts the subscriptions held by one observer ID
+type observerRef struct {
+	observer Observer
+	count    int
+}
+
+// claimID registers a subscription for the observer's ID; the caller holds the write lock
+func (ed *EventDispatcher) claimID(observer Observer) error {
+	id := observer.GetID()
+	ref, ok := ed.ids[id]
+	if ok && !sameObserver(ref.observer, observer) {
+		return fmt.Errorf("subscribe %s: %w", id, ErrDuplicateObserverID)
+	}
+	if !ok {
+		ref = &observerRef{observer: observer}
+		ed.ids[id] = ref
+	}
+	ref.count++
+	return nil
+}
+
+// releaseID drops a subscription of the observer's ID; the caller holds the write lock
+func (ed *EventDispatcher) releaseID(observer Observer) {
+	id := observer.GetID()
+	if ref, ok := ed.ids[id]; ok {
+		if ref.count--; ref.count == 0 {
+			delete(ed.ids, id)
+		}
+	}
+}
+
+// sameObserver reports whether a and b are the same observer instance
+func sameObserver(a, b Observer) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubscription_UnsubscribeOnlyRemovesItself(t *testing.T) {
+	dispatcher := NewEventDispatcher()
+	obs := &recordingObserver{id: "rec"}
+
+	exact, err := dispatcher.Subscribe("price_update", obs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dispatcher.Subscribe("#", obs); err != nil {
+		t.Fatalf("same observer should be allowed on another pattern: %v", err)
+	}
+
+	dispatcher.Notify(priceEvent(1))
+	exact.Unsubscribe()
+	exact.Unsubscribe()
+	dispatcher.Notify(priceEvent(2))
+
+	assertPrices(t, obs.prices(), 1, 1, 2)
+	select {
+	case <-exact.Done():
+	default:
+		t.Fatal("Done should be closed after Unsubscribe")
+	}
+}
+
+func TestSubscribe_RejectsDuplicateID(t *testing.T) {
+	dispatcher := NewEventDispatcher()
+	first := &recordingObserver{id: "same"}
+	handle, err := dispatcher.Subscribe("price_update", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dispatcher.Subscribe("system_alert", &recordingObserver{id: "same"})
+	if !errors.Is(err, ErrDuplicateObserverID) {
+		t.Fatalf("expected ErrDuplicateObserverID, got %v", err)
+	}
+
+	// Once the ID is released it can be reused
+	handle.Unsubscribe()
+	if _, err := dispatcher.Subscribe("system_alert", &recordingObserver{id: "same"}); err != nil {
+		t.Fatalf("ID should be free after unsubscribe: %v", err)
+	}
+}
+
+func TestSubscribe_InvalidArguments(t *testing.T) {
+	dispatcher := NewEventDispatcher()
+	obs := &recordingObserver{id: "rec"}
+
+	if _, err := dispatcher.Subscribe("stock.#.price", obs); err == nil {
+		t.Fatal("expected an invalid pattern to be rejected")
+	}
+	if _, err := dispatcher.Subscribe("price_update", obs, WithFilterExpr("price >")); err == nil {
+		t.Fatal("expected an invalid filter to be rejected")
+	}
+	if _, err := dispatcher.Subscribe("price_update", obs, WithOrderedLanes(0, KeyBySource)); err == nil {
+		t.Fatal("expected zero lanes to be rejected")
+	}
+	if _, err := dispatcher.Subscribe("price_update", obs, WithOrderedLanes(4, nil)); err == nil {
+		t.Fatal("expected lanes without a key function to be rejected")
+	}
+
+	dispatcher.Close()
+	if _, err := dispatcher.Subscribe("price_update", obs); !errors.Is(err, ErrDispatcherClosed) {
+		t.Fatalf("expected ErrDispatcherClosed, got %v", err)
+	}
+}
+
+func TestSubscribeContext_UnsubscribesOnCancel(t *testing.T) {
+	dispatcher := NewEventDispatcher()
+	obs := &recordingObserver{id: "rec"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handle, err := dispatcher.SubscribeContext(ctx, "price_update", obs, WithAsyncQueue(4, Block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Notify(priceEvent(1))
+	cancel()
+
+	select {
+	case <-handle.Done():
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not removed after cancel")
+	}
+	dispatcher.Notify(priceEvent(2))
+	assertPrices(t, obs.prices(), 1)
+
+	if _, err := dispatcher.SubscribeContext(ctx, "price_update", obs); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSubscribeContext_CancelDuringSubscribe(t *testing.T) {
+	dispatcher := NewEventDispatcher()
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		go cancel()
+		handle, err := dispatcher.SubscribeContext(ctx, "price_update", &recordingObserver{id: "rec"})
+		if err != nil {
+			continue
+		}
+		select {
+		case <-handle.Done():
+		case <-time.After(time.Second):
+			t.Fatal("subscription was not removed after cancel")
+		}
+	}
+}
+
+func TestSubscription_DoneOnDispatcherRemoval(t *testing.T) {
+	dispatcher := NewEventDispatcher()
+	obs := &recordingObserver{id: "rec"}
+	legacy, err := dispatcher.Subscribe("price_update", obs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Unsubscribe("price_update", obs)
+	select {
+	case <-legacy.Done():
+	default:
+		t.Fatal("Done should be closed after EventDispatcher.Unsubscribe")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handle, err := dispatcher.SubscribeContext(ctx, "price_update", obs, WithAsyncQueue(4, Block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Close()
+	select {
+	case <-handle.Done():
+	default:
+		t.Fatal("Done should be closed after EventDispatcher.Close")
+	}
+	// The context watcher was detached, so stopping it again reports false
+	if handle.stopCtx() {
+		t.Fatal("the context watcher should be detached by Close")
+	}
+	handle.Unsubscribe()
+}
@@ -0,0 +1,51 @@
+package main
+
+import "hash/maphash"
+
+// defaultLaneCapacity is the per-lane queue size when WithAsyncQueue is not given
+const defaultLaneCapacity = 64
+
+// KeyFunc extracts the ordering key of an event
+type KeyFunc func(Event) string
+
+// KeyBySource orders events per Event.Source
+func KeyBySource(event Event) string {
+	return event.Source
+}
+
+// KeyBySymbol orders events per stock symbol, falling back to the source for
+// payloads without a Symbol field
+func KeyBySymbol(event Event) string {
+	if symbol, ok := EventFields(event)("symbol"); ok {
+		if s, ok := symbol.(string); ok {
+			return s
+		}
+	}
+	return event.Source
+}
+
+// WithOrderedLanes delivers events through a fixed number of lanes, each a
+// queue with its own goroutine. Events with the same key always use the same
+// lane, so the observer sees them in publish order, while events for
+// different keys are processed in parallel. The observer must therefore be
+// safe for concurrent use. Lane capacity and overflow policy come from
+// WithAsyncQueue, defaulting to 64 events and Block.
+func WithOrderedLanes(lanes int, key KeyFunc) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		if !cfg.async {
+			cfg.capacity = defaultLaneCapacity
+			cfg.policy = Block
+		}
+		cfg.async = true
+		cfg.lanes = lanes
+		cfg.laneKey = key
+	}
+}
+
+// laneFor picks the lane for an event by hashing its key
+func (s *subscription) laneFor(event Event) *deliveryQueue {
+	if len(s.lanes) == 1 {
+		return s.lanes[0]
+	}
+	return s.lanes[maphash.String(s.laneSeed, s.laneKey(event))%uint64(len(s.lanes))]
+}
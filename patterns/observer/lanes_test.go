@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// keyedObserver records the values it sees per source and can block one source
+type keyedObserver struct {
+	mu      sync.Mutex
+	seen    map[string][]float64
+	blocked string
+	release chan struct{}
+}
+
+func (k *keyedObserver) Update(event Event) error {
+	if event.Source == k.blocked {
+		<-k.release
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.seen[event.Source] = append(k.seen[event.Source], event.Data.(float64))
+	return nil
+}
+
+func (k *keyedObserver) GetID() string {
+	return "keyed"
+}
+
+func (k *keyedObserver) count(source string) int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.seen[source])
+}
+
+func TestOrderedLanes_PreservePerKeyOrder(t *testing.T) {
+	obs := &keyedObserver{seen: make(map[string][]float64)}
+	dispatcher := NewEventDispatcher()
+	dispatcher.Subscribe("price_update", obs, WithOrderedLanes(4, KeyBySource))
+
+	const keys, perKey = 8, 200
+	var wg sync.WaitGroup
+	for k := 0; k < keys; k++ {
+		wg.Add(1)
+		go func(source string) {
+			defer wg.Done()
+			for i := 0; i < perKey; i++ {
+				dispatcher.Notify(Event{Type: "price_update", Data: float64(i), Source: source})
+			}
+		}(fmt.Sprintf("key%d", k))
+	}
+	wg.Wait()
+	dispatcher.Close()
+
+	for source, values := range obs.seen {
+		if len(values) != perKey {
+			t.Fatalf("%s: expected %d events, got %d", source, perKey, len(values))
+		}
+		for i, v := range values {
+			if v != float64(i) {
+				t.Fatalf("%s: out of order at %d: %v", source, i, values[:i+1])
+			}
+		}
+	}
+}
+
+func TestOrderedLanes_SlowKeyDoesNotBlockOtherLanes(t *testing.T) {
+	obs := &keyedObserver{seen: make(map[string][]float64), blocked: "slow", release: make(chan struct{})}
+	dispatcher := NewEventDispatcher()
+	dispatcher.Subscribe("price_update", obs, WithOrderedLanes(4, KeyBySource))
+	sub := dispatcher.topics.match("price_update")[0]
+
+	// Find a key that lands on a different lane than the blocked one
+	slowLane := sub.laneFor(Event{Source: "slow"})
+	fast := ""
+	for i := 0; fast == ""; i++ {
+		candidate := fmt.Sprintf("fast%d", i)
+		if sub.laneFor(Event{Source: candidate}) != slowLane {
+			fast = candidate
+		}
+	}
+
+	dispatcher.Notify(Event{Type: "price_update", Data: 1.0, Source: "slow"})
+	dispatcher.Notify(Event{Type: "price_update", Data: 1.0, Source: fast})
+
+	deadline := time.Now().Add(time.Second)
+	for obs.count(fast) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("event on another lane was held up by the slow key")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(obs.release)
+	dispatcher.Close()
+}
+
+func TestKeyBySymbol(t *testing.T) {
+	if key := KeyBySymbol(stockEvent("AAPL", 1, 1)); key != "AAPL" {
+		t.Fatalf("expected AAPL, got %s", key)
+	}
+	if key := KeyBySymbol(Event{Data: "alert", Source: "SystemManager"}); key != "SystemManager" {
+		t.Fatalf("expected fallback to source, got %s", key)
+	}
+}
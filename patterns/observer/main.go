@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Observer interface defines the contract for observers
+// Observer interface defines the contract for observers.
+// A returned error (or a panic) counts as a failed delivery and is retried
+// according to the subscription's RetryPolicy before dead-lettering.
 type Observer interface {
-	Update(event Event)
+	Update(event Event) error
 	GetID() string
 }
 
@@ -25,134 +35,331 @@ type Event struct {
 	Data      interface{}
 	Timestamp time.Time
 	Source    string
+	Sequence  uint64            // position in the event store, 0 when not stored
+	Metadata  map[string]string // set by publish middleware, read-only once published
 }
 
 // EventDispatcher manages observers and handles event distribution
 type EventDispatcher struct {
-	observers map[string][]Observer
-	mutex     sync.RWMutex
+	topics      *topicTrie
+	ids         map[string]*observerRef
+	store       *EventStore
+	deadLetters *DeadLetterStore
+	mutex       sync.RWMutex
+	closed      bool
+
+	publishMiddleware  []PublishMiddleware
+	deliveryMiddleware []DeliveryMiddleware
+	publish            PublishFunc
+	deliver            DeliverFunc
+}
+
+// DispatcherOption configures an EventDispatcher
+type DispatcherOption func(*EventDispatcher)
+
+// WithEventStore records every published event in the store, which lets
+// subscribers replay history with FromSequence or FromLatestSnapshot
+func WithEventStore(store *EventStore) DispatcherOption {
+	return func(ed *EventDispatcher) {
+		ed.store = store
+	}
 }
 
 // NewEventDispatcher creates a new event dispatcher
-func NewEventDispatcher() *EventDispatcher {
-	return &EventDispatcher{
-		observers: make(map[string][]Observer),
+func NewEventDispatcher(opts ...DispatcherOption) *EventDispatcher {
+	ed := &EventDispatcher{
+		topics:      newTopicTrie(),
+		ids:         make(map[string]*observerRef),
+		deadLetters: NewDeadLetterStore(),
+	}
+	for _, opt := range opts {
+		opt(ed)
 	}
+	ed.publish = chainPublish(ed.broadcast, ed.publishMiddleware)
+	ed.deliver = chainDelivery(safeDeliver, ed.deliveryMiddleware)
+	return ed
 }
 
-// Subscribe adds an observer for a specific event type
-func (ed *EventDispatcher) Subscribe(eventType string, observer Observer) {
+// Subscribe adds an observer for an event type or topic pattern.
+// Patterns are dotted topics where '*' matches one level and '#' any number
+// of trailing levels, e.g. "stock.AAPL.*" or "stock.#". A plain event type
+// such as "price_update" only matches events of exactly that type.
+//
+// The same observer may subscribe to several patterns, but a different
+// observer reusing its ID is rejected with ErrDuplicateObserverID. The
+// returned handle unsubscribes exactly this subscription.
+//
+// With an event store, FromSequence and FromLatestSnapshot replay matching
+// history to the observer before any live event, with no gaps or duplicates.
+func (ed *EventDispatcher) Subscribe(eventType string, observer Observer, opts ...SubscribeOption) (*Subscription, error) {
+	if err := ValidateTopicPattern(eventType); err != nil {
+		return nil, err
+	}
+	cfg, err := newSubscribeConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
 	ed.mutex.Lock()
-	defer ed.mutex.Unlock()
-	
-	ed.observers[eventType] = append(ed.observers[eventType], observer)
+	if ed.closed {
+		ed.mutex.Unlock()
+		return nil, ErrDispatcherClosed
+	}
+	if err := ed.claimID(observer); err != nil {
+		ed.mutex.Unlock()
+		return nil, err
+	}
+	sub := newSubscription(eventType, observer, ed.deadLetters, ed.deliver, cfg)
+	handle := &Subscription{dispatcher: ed, sub: sub, done: make(chan struct{})}
+	sub.handle = handle
+	// Taking the history and inserting the subscription under the write lock
+	// keeps publishers out, so the replay ends exactly where live delivery
+	// begins. The history is delivered after the lock is released; live
+	// events published meanwhile are held until it is done.
+	history := ed.history(sub)
+	sub.replaying = len(history) > 0
+	ed.topics.insert(eventType, sub)
+	ed.mutex.Unlock()
+
 	fmt.Printf("Observer %s subscribed to event type: %s\n", observer.GetID(), eventType)
+	sub.catchUp(history)
+	return handle, nil
 }
 
-// Unsubscribe removes an observer for a specific event type
+// Unsubscribe removes an observer for a specific event type.
+// Prefer Subscription.Unsubscribe, which does not need the pattern.
 func (ed *EventDispatcher) Unsubscribe(eventType string, observer Observer) {
+	ed.remove(eventType, func(sub *subscription) bool {
+		return sub.observer.GetID() == observer.GetID()
+	})
+}
+
+// remove deletes the first subscription under eventType accepted by match
+func (ed *EventDispatcher) remove(eventType string, match func(*subscription) bool) {
 	ed.mutex.Lock()
-	defer ed.mutex.Unlock()
-	
-	observers := ed.observers[eventType]
-	for i, obs := range observers {
-		if obs.GetID() == observer.GetID() {
-			ed.observers[eventType] = append(observers[:i], observers[i+1:]...)
-			fmt.Printf("Observer %s unsubscribed from event type: %s\n", observer.GetID(), eventType)
-			return
-		}
+	removed := ed.topics.remove(eventType, match)
+	if removed != nil {
+		ed.releaseID(removed.observer)
+	}
+	ed.mutex.Unlock()
+
+	if removed == nil {
+		return
 	}
+	// Drain outside the lock so publishers are not held up by a slow observer
+	removed.close()
+	removed.handle.end()
+	fmt.Printf("Observer %s unsubscribed from event type: %s\n", removed.observer.GetID(), eventType)
 }
 
-// Notify sends an event to all subscribed observers
+// Notify sends an event to all subscribed observers.
+// Synchronous subscriptions are updated concurrently and waited for;
+// asynchronous ones only have the event enqueued. An event whose type is
+// not a valid topic, such as one with wildcards, is logged and dropped.
 func (ed *EventDispatcher) Notify(event Event) {
+	if err := ValidateTopic(event.Type); err != nil {
+		fmt.Printf("Dropping event from %s: %v\n", event.Source, err)
+		return
+	}
+	ed.publish(event)
+}
+
+// broadcast is the innermost PublishFunc: it stores the event and fans it
+// out. An event the store rejects is not delivered.
+func (ed *EventDispatcher) broadcast(event Event) {
 	ed.mutex.RLock()
-	observers := ed.observers[event.Type]
+	if ed.closed {
+		ed.mutex.RUnlock()
+		return
+	}
+	if ed.store != nil {
+		stored, err := ed.store.Append(event)
+		if err != nil {
+			// Subscribers replaying from the store must not see events
+			// it does not hold, so the event is dropped
+			ed.mutex.RUnlock()
+			fmt.Printf("Event store append failed for %s, event dropped: %v\n", event.Type, err)
+			return
+		}
+		event = stored
+	}
+	subs := ed.topics.match(event.Type)
 	ed.mutex.RUnlock()
 	
 	fmt.Printf("Broadcasting event: %s from %s\n", event.Type, event.Source)
 	
 	// Notify all observers concurrently
 	var wg sync.WaitGroup
-	for _, observer := range observers {
+	for _, sub := range subs {
+		if sub.hold(event) {
+			continue
+		}
+		// Filters run here so rejected events never pay for delivery
+		if !sub.accepts(event) {
+			continue
+		}
+		if sub.async() {
+			sub.laneFor(event).push(event)
+			continue
+		}
 		wg.Add(1)
-		go func(obs Observer) {
+		go func(sub *subscription) {
 			defer wg.Done()
-			obs.Update(event)
-		}(observer)
+			sub.process(event)
+		}(sub)
 	}
 	wg.Wait()
 }
 
-// StockPrice represents a stock price subject
+// Flush blocks until every asynchronous subscription has delivered its queued events
+func (ed *EventDispatcher) Flush() {
+	for _, sub := range ed.subscriptions() {
+		sub.flush()
+	}
+}
+
+// Close stops accepting events and drains all asynchronous subscriptions
+func (ed *EventDispatcher) Close() {
+	ed.mutex.Lock()
+	ed.closed = true
+	ed.mutex.Unlock()
+
+	for _, sub := range ed.subscriptions() {
+		sub.close()
+		sub.handle.end()
+	}
+}
+
+// QueueStats reports the queue state of every lane of every asynchronous subscription
+func (ed *EventDispatcher) QueueStats() []QueueStats {
+	var stats []QueueStats
+	for _, sub := range ed.subscriptions() {
+		for i, lane := range sub.lanes {
+			laneStats := lane.stats(sub.eventType, sub.observer.GetID())
+			laneStats.Lane = i
+			stats = append(stats, laneStats)
+		}
+	}
+	return stats
+}
+
+// history returns the stored events a new subscription replays; the caller
+// holds the write lock
+func (ed *EventDispatcher) history(sub *subscription) []Event {
+	if ed.store == nil || sub.replay == replayNone {
+		return nil
+	}
+
+	var history []Event
+	if sub.replay == replaySnapshot {
+		history = ed.store.Snapshot()
+	} else {
+		history = ed.store.Since(sub.fromSequence)
+	}
+
+	matcher := newTopicTrie()
+	matcher.insert(sub.eventType, sub)
+	matching := history[:0]
+	for _, event := range history {
+		if len(matcher.match(event.Type)) > 0 {
+			matching = append(matching, event)
+		}
+	}
+	return matching
+}
+
+// subscriptions returns a snapshot of all current subscriptions
+func (ed *EventDispatcher) subscriptions() []*subscription {
+	ed.mutex.RLock()
+	defer ed.mutex.RUnlock()
+
+	return ed.topics.all()
+}
+
+// StockQuote is an immutable snapshot of a stock price, published as the
+// payload of price update events
+type StockQuote struct {
+	Symbol    string
+	Price     float64
+	Change    float64
+	Timestamp time.Time
+	Seq       uint64 // counts the updates of the symbol; a higher Seq is newer
+}
+
+// StockPrice represents a stock price subject. It is safe for concurrent use:
+// updates are serialized and each one publishes a StockQuote value, so
+// observers never share mutable state with the subject. Concurrent updates
+// may reach the dispatcher out of order; the quote's Seq tells which is newer.
 type StockPrice struct {
 	Symbol     string
-	Price      float64
-	Change     float64
+	mutex      sync.Mutex
+	price      float64
+	change     float64
+	seq        uint64
 	dispatcher *EventDispatcher
+	eventType  string
 }
 
-// NewStockPrice creates a new stock price subject
+// NewStockPrice creates a new stock price subject publishing "price_update" events
 func NewStockPrice(symbol string, dispatcher *EventDispatcher) *StockPrice {
 	return &StockPrice{
 		Symbol:     symbol,
 		dispatcher: dispatcher,
+		eventType:  "price_update",
 	}
 }
 
-// SetPrice updates the stock price and notifies observers
+// NewTopicStockPrice creates a stock price subject publishing on the
+// hierarchical topic stock.<symbol>.price_update
+func NewTopicStockPrice(symbol string, dispatcher *EventDispatcher) *StockPrice {
+	sp := NewStockPrice(symbol, dispatcher)
+	sp.eventType = StockTopic(symbol, "price_update")
+	return sp
+}
+
+// SetPrice updates the stock price and notifies observers. The quote is
+// built under the lock but published after it is released, so a slow
+// observer does not hold up other updates of the stock.
 func (sp *StockPrice) SetPrice(newPrice float64) {
-	oldPrice := sp.Price
-	sp.Price = newPrice
-	sp.Change = newPrice - oldPrice
+	sp.mutex.Lock()
+	sp.change = newPrice - sp.price
+	sp.price = newPrice
+	sp.seq++
+	quote := sp.quoteLocked(time.Now())
+	sp.mutex.Unlock()
 	
 	event := Event{
-		Type:      "price_update",
-		Data:      sp,
-		Timestamp: time.Now(),
+		Type:      sp.eventType,
+		Data:      quote,
+		Timestamp: quote.Timestamp,
 		Source:    fmt.Sprintf("StockPrice-%s", sp.Symbol),
 	}
 	
 	sp.dispatcher.Notify(event)
 }
 
-// EmailNotifier implements Observer for email notifications
-type EmailNotifier struct {
-	ID    string
-	Email string
-}
-
-// NewEmailNotifier creates a new email notifier
-func NewEmailNotifier(id, email string) *EmailNotifier {
-	return &EmailNotifier{
-		ID:    id,
-		Email: email,
-	}
-}
-
-// Update handles incoming events
-func (en *EmailNotifier) Update(event Event) {
-	switch event.Type {
-	case "price_update":
-		if stock, ok := event.Data.(*StockPrice); ok {
-			fmt.Printf("📧 EMAIL to %s: %s price changed to $%.2f (change: %+.2f) at %s\n",
-				en.Email, stock.Symbol, stock.Price, stock.Change, event.Timestamp.Format("15:04:05"))
-		}
-	case "system_alert":
-		fmt.Printf("📧 EMAIL ALERT to %s: %s at %s\n",
-			en.Email, event.Data, event.Timestamp.Format("15:04:05"))
-	}
+// Quote returns a snapshot of the current price
+func (sp *StockPrice) Quote() StockQuote {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
 	
-	// Simulate email sending delay
-	time.Sleep(50 * time.Millisecond)
+	return sp.quoteLocked(time.Now())
 }
 
-// GetID returns the observer ID
-func (en *EmailNotifier) GetID() string {
-	return en.ID
+// quoteLocked builds a snapshot; the caller holds the mutex
+func (sp *StockPrice) quoteLocked(at time.Time) StockQuote {
+	return StockQuote{
+		Symbol:    sp.Symbol,
+		Price:     sp.price,
+		Change:    sp.change,
+		Timestamp: at,
+		Seq:       sp.seq,
+	}
 }
 
-// SMSNotifier implements Observer for SMS notifications
+// SMSNotifier implements Observer for SMS notifications.
+// It texts every price update it receives, so subscribe it with
+// WithFilterExpr(SignificantChangeFilter) to only alert on large moves.
 type SMSNotifier struct {
 	ID    string
 	Phone string
@@ -167,14 +374,12 @@ func NewSMSNotifier(id, phone string) *SMSNotifier {
 }
 
 // Update handles incoming events
-func (sn *SMSNotifier) Update(event Event) {
-	switch event.Type {
+func (sn *SMSNotifier) Update(event Event) error {
+	switch eventKind(event.Type) {
 	case "price_update":
-		if stock, ok := event.Data.(*StockPrice); ok {
-			if stock.Change > 5.0 || stock.Change < -5.0 {
-				fmt.Printf("📱 SMS to %s: ALERT! %s significant change: $%.2f (%+.2f) at %s\n",
-					sn.Phone, stock.Symbol, stock.Price, stock.Change, event.Timestamp.Format("15:04:05"))
-			}
+		if stock, ok := event.Data.(StockQuote); ok {
+			fmt.Printf("📱 SMS to %s: ALERT! %s significant change: $%.2f (%+.2f) at %s\n",
+				sn.Phone, stock.Symbol, stock.Price, stock.Change, event.Timestamp.Format("15:04:05"))
 		}
 	case "system_alert":
 		fmt.Printf("📱 SMS ALERT to %s: %s at %s\n",
@@ -183,6 +388,7 @@ func (sn *SMSNotifier) Update(event Event) {
 	
 	// Simulate SMS sending delay
 	time.Sleep(30 * time.Millisecond)
+	return nil
 }
 
 // GetID returns the observer ID
@@ -205,10 +411,10 @@ func NewLoggingObserver(id, logLevel string) *LoggingObserver {
 }
 
 // Update handles incoming events
-func (lo *LoggingObserver) Update(event Event) {
-	switch event.Type {
+func (lo *LoggingObserver) Update(event Event) error {
+	switch eventKind(event.Type) {
 	case "price_update":
-		if stock, ok := event.Data.(*StockPrice); ok {
+		if stock, ok := event.Data.(StockQuote); ok {
 			fmt.Printf("📝 LOG [%s]: Price update - %s: $%.2f (change: %+.2f) at %s\n",
 				lo.LogLevel, stock.Symbol, stock.Price, stock.Change, event.Timestamp.Format("15:04:05"))
 		}
@@ -216,6 +422,7 @@ func (lo *LoggingObserver) Update(event Event) {
 		fmt.Printf("📝 LOG [%s]: System Alert - %s at %s\n",
 			lo.LogLevel, event.Data, event.Timestamp.Format("15:04:05"))
 	}
+	return nil
 }
 
 // GetID returns the observer ID
@@ -234,10 +441,10 @@ func NewDatabaseObserver(id string) *DatabaseObserver {
 }
 
 // Update handles incoming events
-func (do *DatabaseObserver) Update(event Event) {
-	switch event.Type {
+func (do *DatabaseObserver) Update(event Event) error {
+	switch eventKind(event.Type) {
 	case "price_update":
-		if stock, ok := event.Data.(*StockPrice); ok {
+		if stock, ok := event.Data.(StockQuote); ok {
 			fmt.Printf("💾 DB: Saved price record - %s: $%.2f at %s\n",
 				stock.Symbol, stock.Price, event.Timestamp.Format("15:04:05"))
 		}
@@ -245,6 +452,7 @@ func (do *DatabaseObserver) Update(event Event) {
 	
 	// Simulate database write delay
 	time.Sleep(80 * time.Millisecond)
+	return nil
 }
 
 // GetID returns the observer ID
@@ -265,7 +473,7 @@ func demonstrateBasicObserverPattern() {
 	
 	// Subscribe observers to events
 	dispatcher.Subscribe("price_update", emailNotifier)
-	dispatcher.Subscribe("price_update", smsNotifier)
+	dispatcher.Subscribe("price_update", smsNotifier, WithFilterExpr(SignificantChangeFilter))
 	dispatcher.Subscribe("price_update", logger)
 	dispatcher.Subscribe("system_alert", emailNotifier)
 	dispatcher.Subscribe("system_alert", smsNotifier)
@@ -306,9 +514,13 @@ func demonstrateAdvancedScenario() {
 		NewDatabaseObserver("db1"),
 	}
 	
-	// Subscribe all observers to price updates
+	// Subscribe all observers to price updates, SMS only for significant moves
 	for _, observer := range observers {
-		dispatcher.Subscribe("price_update", observer)
+		var opts []SubscribeOption
+		if _, ok := observer.(*SMSNotifier); ok {
+			opts = append(opts, WithFilterExpr(SignificantChangeFilter))
+		}
+		dispatcher.Subscribe("price_update", observer, opts...)
 	}
 	
 	// Create multiple stocks
@@ -345,6 +557,429 @@ func demonstrateAdvancedScenario() {
 	fmt.Println()
 }
 
+func demonstrateAsyncDelivery() {
+	fmt.Println("=== Async Delivery with Bounded Queues ===")
+	
+	dispatcher := NewEventDispatcher()
+	
+	// The slow database observer gets its own queue so it no longer holds up
+	// publishers; the logger keeps the old synchronous behaviour.
+	dispatcher.Subscribe("price_update", NewDatabaseObserver("db1"), WithAsyncQueue(2, DropOldest))
+	dispatcher.Subscribe("price_update", NewEmailNotifier("email1", "trader@example.com"), WithAsyncQueue(2, SpillToDisk))
+	dispatcher.Subscribe("price_update", NewLoggingObserver("logger1", "INFO"))
+	
+	stock := NewStockPrice("AAPL", dispatcher)
+	start := time.Now()
+	for _, price := range []float64{150.0, 151.0, 149.5, 152.0, 153.5, 151.5} {
+		stock.SetPrice(price)
+	}
+	fmt.Printf("Published 6 updates in %v\n", time.Since(start).Round(time.Millisecond))
+	
+	dispatcher.Flush()
+	for _, stats := range dispatcher.QueueStats() {
+		fmt.Printf("Queue %s/%s (%s): delivered=%d dropped=%d spilled=%d\n",
+			stats.ObserverID, stats.EventType, stats.Policy, stats.Delivered, stats.Dropped, stats.Spilled)
+	}
+	dispatcher.Close()
+	
+	fmt.Println()
+}
+
+func demonstrateTopicSubscriptions() {
+	fmt.Println("=== Hierarchical Topic Subscriptions ===")
+	
+	dispatcher := NewEventDispatcher()
+	
+	// Everything about AAPL, price updates for any symbol, and all stock events
+	dispatcher.Subscribe("stock.AAPL.#", NewLoggingObserver("aapl-logger", "DEBUG"))
+	dispatcher.Subscribe("stock.*.price_update", NewEmailNotifier("email1", "trader@example.com"))
+	dispatcher.Subscribe("stock.#", NewDatabaseObserver("db1"))
+	// Exact event types keep working alongside patterns
+	dispatcher.Subscribe("system_alert", NewLoggingObserver("alert-logger", "WARN"))
+	
+	NewTopicStockPrice("AAPL", dispatcher).SetPrice(150.0)
+	NewTopicStockPrice("MSFT", dispatcher).SetPrice(310.0)
+	dispatcher.Notify(Event{
+		Type:      "system_alert",
+		Data:      "Market closes early today",
+		Timestamp: time.Now(),
+		Source:    "SystemManager",
+	})
+	
+	fmt.Println()
+}
+
+// SystemAlert is the payload of typed system alert events
+type SystemAlert struct {
+	Severity string
+	Message  string
+}
+
+func demonstrateTypedBus() {
+	fmt.Println("=== Type-Safe Event Bus ===")
+	
+	bus := NewBus(NewEventDispatcher())
+	alerts := NewTopic[SystemAlert](bus, "system_alert")
+	
+	// Typed subscribers get the payload without any type assertion
+	alerts.Subscribe("pager", func(event TypedEvent[SystemAlert]) error {
+		fmt.Printf("📟 PAGER [%s]: %s from %s\n", event.Data.Severity, event.Data.Message, event.Source)
+		return nil
+	})
+	// Existing untyped observers can still listen through the adapter
+	alerts.SubscribeObserver(NewLoggingObserver("logger1", "WARN"))
+	
+	alerts.Publish("SystemManager", SystemAlert{Severity: "high", Message: "Order gateway degraded"})
+	
+	// A legacy publisher sending the wrong payload is reported, not ignored
+	bus.Dispatcher().Notify(Event{
+		Type:      "system_alert",
+		Data:      "untyped alert",
+		Timestamp: time.Now(),
+		Source:    "LegacyService",
+	})
+	fmt.Printf("Payload mismatches: %d\n", bus.Mismatches())
+	
+	fmt.Println()
+}
+
+func demonstrateEventReplay() {
+	fmt.Println("=== Event Store and Replay ===")
+	
+	store, err := OpenEventStore(filepath.Join(os.TempDir(), "observer-demo-events.seg"))
+	if err != nil {
+		fmt.Printf("Cannot open event store: %v\n", err)
+		return
+	}
+	defer store.Close()
+	fmt.Printf("Event store opened at sequence %d\n", store.LastSequence())
+	
+	dispatcher := NewEventDispatcher(WithEventStore(store))
+	for _, symbol := range []string{"AAPL", "MSFT"} {
+		stock := NewStockPrice(symbol, dispatcher)
+		stock.SetPrice(100.0)
+		stock.SetPrice(101.5)
+	}
+	
+	// A late joiner only needs the current price of every stock...
+	dispatcher.Subscribe("price_update", NewLoggingObserver("late-logger", "INFO"), FromLatestSnapshot())
+	// ...while a restarted database observer resumes after the last sequence it saved
+	resumeFrom := store.LastSequence() - 1
+	dispatcher.Subscribe("price_update", NewDatabaseObserver("db1"), FromSequence(resumeFrom))
+	
+	NewStockPrice("GOOGL", dispatcher).SetPrice(2500.0)
+	
+	fmt.Println()
+}
+
+// FlakyObserver fails its first few deliveries, panicking on the very first one
+type FlakyObserver struct {
+	ID       string
+	failures int
+	calls    int
+}
+
+// NewFlakyObserver creates an observer that fails the first failures deliveries
+func NewFlakyObserver(id string, failures int) *FlakyObserver {
+	return &FlakyObserver{ID: id, failures: failures}
+}
+
+// Update handles incoming events
+func (fo *FlakyObserver) Update(event Event) error {
+	fo.calls++
+	if fo.calls == 1 {
+		panic("downstream client not initialised")
+	}
+	if fo.calls <= fo.failures {
+		return fmt.Errorf("downstream unavailable (call %d)", fo.calls)
+	}
+	fmt.Printf("✅ %s processed %s on call %d\n", fo.ID, event.Type, fo.calls)
+	return nil
+}
+
+// GetID returns the observer ID
+func (fo *FlakyObserver) GetID() string {
+	return fo.ID
+}
+
+func demonstrateFailureHandling() {
+	fmt.Println("=== Panic Isolation, Retries and Dead Letters ===")
+	
+	dispatcher := NewEventDispatcher()
+	retry := RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, Multiplier: 2}
+	
+	// Recovers after two failures, so retries are enough
+	dispatcher.Subscribe("system_alert", NewFlakyObserver("flaky1", 2), WithRetry(retry))
+	// Needs more attempts than the policy allows, so the event is dead-lettered
+	dispatcher.Subscribe("system_alert", NewFlakyObserver("flaky2", 4), WithRetry(retry))
+	
+	dispatcher.Notify(Event{
+		Type:      "system_alert",
+		Data:      "Risk limits updated",
+		Timestamp: time.Now(),
+		Source:    "RiskManager",
+	})
+	
+	for _, letter := range dispatcher.DeadLetters().List() {
+		fmt.Printf("Dead letter #%d: %s -> %s after %d attempts: %v\n",
+			letter.ID, letter.Event.Type, letter.ObserverID, letter.Attempts, letter.Err)
+	}
+	succeeded, failed := dispatcher.RedriveAll()
+	fmt.Printf("Redrive: %d succeeded, %d failed, %d left\n", succeeded, failed, dispatcher.DeadLetters().Len())
+	
+	fmt.Println()
+}
+
+func demonstrateFilters() {
+	fmt.Println("=== Declarative Subscription Filters ===")
+	
+	dispatcher := NewEventDispatcher()
+	dispatcher.Subscribe("price_update", NewSMSNotifier("sms1", "+1234567890"),
+		WithFilterExpr(`symbol == "AAPL" && abs(change) > 5`))
+	dispatcher.Subscribe("price_update", NewLoggingObserver("big-caps", "INFO"),
+		WithFilter("price above $1000", func(event Event) bool {
+			stock, ok := event.Data.(StockQuote)
+			return ok && stock.Price > 1000
+		}))
+	
+	aapl := NewStockPrice("AAPL", dispatcher)
+	aapl.SetPrice(150.0)
+	aapl.SetPrice(152.0)
+	aapl.SetPrice(160.0)
+	NewStockPrice("GOOGL", dispatcher).SetPrice(2500.0)
+	
+	fmt.Println("Filter statistics:")
+	for _, info := range dispatcher.Filters() {
+		fmt.Printf("  %s (%s) %q: passed=%d rejected=%d errors=%d\n",
+			info.ObserverID, info.EventType, info.Filter, info.Passed, info.Rejected, info.Errors)
+	}
+	
+	probe := StockQuote{Symbol: "MSFT", Price: 310.0, Change: 12.0}
+	fmt.Println("Why would a MSFT +12.00 update not page anyone?")
+	for _, decision := range dispatcher.Explain(Event{Type: "price_update", Data: probe, Source: "probe"}) {
+		fmt.Printf("  %s\n", decision)
+	}
+	
+	fmt.Println()
+}
+
+func demonstrateWebhooks() {
+	fmt.Println("=== Signed Webhook Delivery ===")
+	
+	// A local receiver that verifies signatures and fails its first request
+	const secret = "demo-secret"
+	var requests atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !VerifyWebhookSignature(secret, r.Header.Get(WebhookTimestampHeader), body, r.Header.Get(WebhookSignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Printf("🌐 WEBHOOK received: %s\n", body)
+	}))
+	defer receiver.Close()
+	
+	dispatcher := NewEventDispatcher()
+	webhook := NewWebhookObserver("webhook1", []WebhookEndpoint{{URL: receiver.URL, Secret: secret}},
+		WithWebhookTimeout(time.Second),
+		WithWebhookRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: 20 * time.Millisecond, Multiplier: 2}))
+	dispatcher.Subscribe("price_update", webhook)
+	
+	NewStockPrice("AAPL", dispatcher).SetPrice(150.0)
+	
+	for _, attempt := range webhook.DeliveryLog(receiver.URL) {
+		fmt.Printf("  attempt %d: status=%d ok=%v in %v\n",
+			attempt.Attempt, attempt.StatusCode, attempt.Succeeded(), attempt.Duration.Round(time.Millisecond))
+	}
+	
+	fmt.Println()
+}
+
+func demonstrateOrderedLanes() {
+	fmt.Println("=== Per-Key Ordered Delivery Lanes ===")
+	
+	dispatcher := NewEventDispatcher()
+	// Each symbol is pinned to one of four lanes: the database sees every
+	// symbol's updates in order, while different symbols are written in parallel
+	dispatcher.Subscribe("price_update", NewDatabaseObserver("db1"), WithOrderedLanes(4, KeyBySymbol))
+	
+	symbols := []string{"AAPL", "GOOGL", "MSFT", "AMZN"}
+	stocks := make([]*StockPrice, len(symbols))
+	for i, symbol := range symbols {
+		stocks[i] = NewStockPrice(symbol, dispatcher)
+	}
+	
+	start := time.Now()
+	for round := 1; round <= 3; round++ {
+		for i, stock := range stocks {
+			stock.SetPrice(float64(100*(i+1) + round))
+		}
+	}
+	dispatcher.Flush()
+	fmt.Printf("Stored 12 updates in %v (one at a time would take ~960ms)\n",
+		time.Since(start).Round(10*time.Millisecond))
+	dispatcher.Close()
+	
+	fmt.Println()
+}
+
+func demonstrateMarketSimulation() {
+	fmt.Println("=== Market Simulation with OHLC Candles ===")
+	
+	dispatcher := NewEventDispatcher()
+	candles := NewCandleAggregator("candles100ms", 100*time.Millisecond, func(c Candle) {
+		fmt.Printf("🕯️  %-5s %s O=%.2f H=%.2f L=%.2f C=%.2f ticks=%d\n",
+			c.Symbol, c.Start.Format("15:04:05.000"), c.Open, c.High, c.Low, c.Close, c.Ticks)
+	})
+	// Quotes are immutable values, so the aggregator can consume them on
+	// parallel lanes while the simulator keeps publishing
+	dispatcher.Subscribe("price_update", candles, WithOrderedLanes(4, KeyBySymbol))
+	
+	market := NewMarketSimulator(dispatcher, MarketConfig{
+		Symbols:    map[string]float64{"AAPL": 150, "GOOGL": 2500, "MSFT": 300},
+		Volatility: 0.002,
+		Seed:       42,
+	})
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 350*time.Millisecond)
+	defer cancel()
+	market.Run(ctx, 10*time.Millisecond)
+	dispatcher.Close()
+	
+	for _, stock := range market.Stocks() {
+		quote := stock.Quote()
+		fmt.Printf("Final %s: $%.2f (%d candles)\n", quote.Symbol, quote.Price, len(candles.Candles(quote.Symbol)))
+	}
+	
+	fmt.Println()
+}
+
+func demonstrateSubscriptionHandles() {
+	fmt.Println("=== Subscription Handles and Context Lifetimes ===")
+	
+	dispatcher := NewEventDispatcher()
+	logger := NewLoggingObserver("logger1", "INFO")
+	
+	// One observer, two subscriptions: each handle removes only its own
+	prices, err := dispatcher.Subscribe("price_update", logger)
+	if err != nil {
+		fmt.Printf("Subscribe failed: %v\n", err)
+		return
+	}
+	if _, err := dispatcher.Subscribe("system_alert", logger); err != nil {
+		fmt.Printf("Subscribe failed: %v\n", err)
+		return
+	}
+	
+	// Another observer reusing the ID is rejected instead of clobbering it
+	if _, err := dispatcher.Subscribe("price_update", NewLoggingObserver("logger1", "DEBUG")); err != nil {
+		fmt.Printf("Rejected: %v\n", err)
+	}
+	
+	// A session-scoped observer goes away with its context
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session, err := dispatcher.SubscribeContext(ctx, "price_update", NewEmailNotifier("session-email", "user@example.com"))
+	if err != nil {
+		fmt.Printf("Subscribe failed: %v\n", err)
+		return
+	}
+	
+	stock := NewStockPrice("AAPL", dispatcher)
+	stock.SetPrice(150.0)
+	
+	prices.Unsubscribe()
+	cancel()
+	<-session.Done()
+	
+	stock.SetPrice(151.0) // nobody is listening to prices any more
+	dispatcher.Notify(Event{Type: "system_alert", Data: "Still subscribed to alerts", Timestamp: time.Now(), Source: "SystemManager"})
+	
+	fmt.Println()
+}
+
+func demonstrateMiddleware() {
+	fmt.Println("=== Delivery Middleware ===")
+	
+	histogram := NewLatencyHistogram()
+	sampler := NewSampler(0.5, 1)
+	var spans atomic.Int64
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	
+	// Middleware runs outermost first: every attempt is traced, timed and logged
+	dispatcher := NewEventDispatcher(
+		WithPublishMiddleware(TracingPublishMiddleware()),
+		WithDeliveryMiddleware(
+			TracingMiddleware(func(Span) { spans.Add(1) }),
+			MetricsMiddleware(histogram),
+			LoggingMiddleware(logger),
+			RateLimitMiddleware(1000, 10),
+		),
+	)
+	dispatcher.Subscribe("price_update", NewLoggingObserver("logger1", "INFO"))
+	dispatcher.Subscribe("price_update", NewFlakyObserver("flaky", 1), WithRetry(RetryPolicy{MaxAttempts: 2}))
+	
+	stock := NewStockPrice("AAPL", dispatcher)
+	stock.SetPrice(150.0)
+	stock.SetPrice(152.5)
+	
+	for _, snapshot := range histogram.Snapshot() {
+		fmt.Printf("Latency %v\n", snapshot)
+	}
+	fmt.Printf("Recorded %d spans\n", spans.Load())
+	
+	// Sampling keeps a fraction of deliveries for a noisy observer
+	sampled := NewEventDispatcher(WithDeliveryMiddleware(SamplingMiddleware(sampler)))
+	sampled.Subscribe("price_update", NewDatabaseObserver("db1"))
+	sampledStock := NewStockPrice("MSFT", sampled)
+	for i := 0; i < 6; i++ {
+		sampledStock.SetPrice(300.0 + float64(i))
+	}
+	fmt.Printf("Sampler kept %d and skipped %d deliveries\n", sampler.Kept(), sampler.Skipped())
+	
+	fmt.Println()
+}
+
+func demonstrateEmailDigest() {
+	fmt.Println("=== Email Templates and Digests ===")
+	
+	alertTemplate, err := ParseEmailTemplate(
+		`[{{.Data.Severity}}] {{.Source}}`,
+		`{{.Data.Message}}`,
+		`<p><em>{{.Data.Severity}}</em>: {{.Data.Message}}</p>`)
+	if err != nil {
+		fmt.Printf("Template error: %v\n", err)
+		return
+	}
+	
+	// Bursts of price updates within 100ms arrive as one digest
+	dispatcher := NewEventDispatcher()
+	email := NewEmailNotifier("email-digest", "trader@example.com",
+		WithDigest(100*time.Millisecond, 10),
+		WithEmailTemplate("system_alert", alertTemplate))
+	dispatcher.Subscribe("#", email)
+	
+	for _, symbol := range []string{"AAPL", "GOOGL", "MSFT"} {
+		NewTopicStockPrice(symbol, dispatcher).SetPrice(100.0)
+	}
+	dispatcher.Notify(Event{
+		Type:      "system_alert",
+		Data:      SystemAlert{Severity: "WARN", Message: "Feed latency above 200ms"},
+		Timestamp: time.Now(),
+		Source:    "FeedMonitor",
+	})
+	// Close sends what is still pending instead of waiting for the window
+	if err := email.Close(); err != nil {
+		fmt.Printf("Digest error: %v\n", err)
+	}
+	
+	fmt.Println()
+}
+
 func main() {
 	fmt.Println("Observer Pattern Implementation Demo")
 	fmt.Println("===================================")
@@ -354,5 +989,29 @@ func main() {
 	
 	demonstrateAdvancedScenario()
 	
+	demonstrateAsyncDelivery()
+	
+	demonstrateTopicSubscriptions()
+	
+	demonstrateTypedBus()
+	
+	demonstrateEventReplay()
+	
+	demonstrateFailureHandling()
+	
+	demonstrateFilters()
+	
+	demonstrateWebhooks()
+	
+	demonstrateOrderedLanes()
+	
+	demonstrateMarketSimulation()
+	
+	demonstrateSubscriptionHandles()
+	
+	demonstrateMiddleware()
+	
+	demonstrateEmailDigest()
+	
 	fmt.Println("Observer pattern demo completed!")
 }
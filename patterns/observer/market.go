@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MarketConfig configures a MarketSimulator
+type MarketConfig struct {
+	Symbols     map[string]float64 // symbol -> starting price
+	Volatility  float64            // standard deviation of the per-tick log return
+	Drift       float64            // mean per-tick log return
+	Seed        int64              // seeds the random walk, equal seeds give equal prices
+	TopicEvents bool               // publish on stock.<symbol>.price_update instead of price_update
+}
+
+// MarketSimulator moves many StockPrice subjects along independent random walks
+type MarketSimulator struct {
+	stocks     []*StockPrice
+	prices     []float64
+	volatility float64
+	drift      float64
+
+	tickMutex sync.Mutex // serializes ticks so each stock's updates stay ordered
+	rng       *rand.Rand
+}
+
+// NewMarketSimulator creates a simulator publishing through dispatcher
+func NewMarketSimulator(dispatcher *EventDispatcher, cfg MarketConfig) *MarketSimulator {
+	symbols := make([]string, 0, len(cfg.Symbols))
+	for symbol := range cfg.Symbols {
+		symbols = append(symbols, symbol)
+	}
+	// Map order is random; sorting keeps seeded runs reproducible
+	sort.Strings(symbols)
+
+	m := &MarketSimulator{
+		volatility: cfg.Volatility,
+		drift:      cfg.Drift,
+		rng:        rand.New(rand.NewSource(cfg.Seed)),
+	}
+	for _, symbol := range symbols {
+		stock := NewStockPrice(symbol, dispatcher)
+		if cfg.TopicEvents {
+			stock = NewTopicStockPrice(symbol, dispatcher)
+		}
+		m.stocks = append(m.stocks, stock)
+		m.prices = append(m.prices, cfg.Symbols[symbol])
+	}
+	return m
+}
+
+// Stocks returns the simulated subjects, sorted by symbol
+func (m *MarketSimulator) Stocks() []*StockPrice {
+	return m.stocks
+}
+
+// Tick advances every symbol by one step and publishes the new prices,
+// one goroutine per symbol, returning once all of them are published
+func (m *MarketSimulator) Tick() {
+	m.tickMutex.Lock()
+	defer m.tickMutex.Unlock()
+
+	for i, price := range m.prices {
+		next := price * math.Exp(m.drift+m.volatility*m.rng.NormFloat64())
+		m.prices[i] = math.Round(next*100) / 100
+	}
+
+	var wg sync.WaitGroup
+	for i, stock := range m.stocks {
+		wg.Add(1)
+		go func(stock *StockPrice, price float64) {
+			defer wg.Done()
+			stock.SetPrice(price)
+		}(stock, m.prices[i])
+	}
+	wg.Wait()
+}
+
+// Run ticks every interval until the context is cancelled
+func (m *MarketSimulator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Tick()
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCandleAggregator_BuildsOHLC(t *testing.T) {
+	var closed []Candle
+	candles := NewCandleAggregator("candles", time.Minute, func(c Candle) { closed = append(closed, c) })
+
+	base := time.Date(2026, 1, 2, 9, 30, 0, 0, time.UTC)
+	ticks := []struct {
+		offset time.Duration
+		price  float64
+	}{
+		{0, 100}, {10 * time.Second, 104}, {20 * time.Second, 98}, {50 * time.Second, 101},
+		{70 * time.Second, 102},
+		{30 * time.Second, 500}, // late tick for the closed first minute
+	}
+	for _, tick := range ticks {
+		quote := StockQuote{Symbol: "AAPL", Price: tick.price, Timestamp: base.Add(tick.offset)}
+		if err := candles.Update(Event{Type: "price_update", Data: quote}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := Candle{Symbol: "AAPL", Start: base, Open: 100, High: 104, Low: 98, Close: 101, Ticks: 4}
+	if len(closed) != 1 || closed[0] != want {
+		t.Fatalf("expected closed candle %+v, got %+v", want, closed)
+	}
+	all := candles.Candles("AAPL")
+	if len(all) != 2 || all[1].Open != 102 || all[1].Ticks != 1 {
+		t.Fatalf("unexpected candles: %+v", all)
+	}
+	if candles.LateTicks() != 1 {
+		t.Fatalf("expected 1 late tick, got %d", candles.LateTicks())
+	}
+	if err := candles.Update(Event{Type: "price_update", Data: "not a quote"}); err == nil {
+		t.Fatal("expected an error for a non-quote payload")
+	}
+}
+
+func TestMarketSimulator_SeedIsReproducible(t *testing.T) {
+	run := func() []float64 {
+		market := NewMarketSimulator(NewEventDispatcher(), MarketConfig{
+			Symbols:    map[string]float64{"AAPL": 150, "MSFT": 300, "GOOGL": 2500},
+			Volatility: 0.01,
+			Seed:       7,
+		})
+		for i := 0; i < 20; i++ {
+			market.Tick()
+		}
+		var prices []float64
+		for _, stock := range market.Stocks() {
+			prices = append(prices, stock.Quote().Price)
+		}
+		return prices
+	}
+
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("runs diverged: %v vs %v", first, second)
+		}
+	}
+}
+
+// TestStockPrice_ConcurrentPublishing is meant to run under -race: many
+// goroutines update the same stocks while asynchronous observers read the quotes
+func TestStockPrice_ConcurrentPublishing(t *testing.T) {
+	dispatcher := NewEventDispatcher(WithEventStore(NewMemoryEventStore()))
+	candles := NewCandleAggregator("candles", time.Hour, nil)
+	dispatcher.Subscribe("price_update", candles, WithOrderedLanes(4, KeyBySymbol))
+	dispatcher.Subscribe("price_update", &quoteChecker{t: t}, WithAsyncQueue(16, DropOldest))
+
+	market := NewMarketSimulator(dispatcher, MarketConfig{
+		Symbols:    map[string]float64{"AAPL": 150, "MSFT": 300, "GOOGL": 2500, "AMZN": 180},
+		Volatility: 0.01,
+		Seed:       1,
+	})
+
+	const writers, updates = 4, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				if w == 0 {
+					market.Tick()
+				} else {
+					stock := market.Stocks()[i%len(market.Stocks())]
+					stock.SetPrice(stock.Quote().Price + 0.01)
+					_ = stock.Quote()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	dispatcher.Close()
+
+	total := 0
+	for _, stock := range market.Stocks() {
+		for _, candle := range candles.Candles(stock.Symbol) {
+			total += candle.Ticks
+			if candle.High < candle.Low || candle.Open > candle.High || candle.Close < candle.Low {
+				t.Fatalf("inconsistent candle: %+v", candle)
+			}
+		}
+	}
+	if want := updates*len(market.Stocks()) + (writers-1)*updates; total != want {
+		t.Fatalf("expected %d ticks in candles, got %d", want, total)
+	}
+}
+
+// quoteChecker verifies that every quote is internally consistent
+type quoteChecker struct {
+	t *testing.T
+}
+
+func (q *quoteChecker) Update(event Event) error {
+	quote, err := PayloadAs[StockQuote](event)
+	if err != nil {
+		return err
+	}
+	if event.Source != fmt.Sprintf("StockPrice-%s", quote.Symbol) || !event.Timestamp.Equal(quote.Timestamp) {
+		q.t.Errorf("event %+v does not match its quote", event)
+	}
+	return nil
+}
+
+func (q *quoteChecker) GetID() string {
+	return "checker"
+}
+
+func TestStockPrice_PublishesOutsideTheLock(t *testing.T) {
+	dispatcher := NewEventDispatcher()
+	stock := NewStockPrice("AAPL", dispatcher)
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	seqs := make(chan uint64, 2)
+	dispatcher.Subscribe("price_update", &funcObserver{id: "slow", fn: func(event Event) error {
+		started <- struct{}{}
+		<-release
+		seqs <- event.Data.(StockQuote).Seq
+		return nil
+	}})
+
+	go stock.SetPrice(100)
+	<-started
+	done := make(chan StockQuote)
+	go func() { done <- stock.Quote() }()
+	select {
+	case quote := <-done:
+		if quote.Seq != 1 || quote.Price != 100 {
+			t.Fatalf("unexpected quote %+v", quote)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Quote was blocked by a slow observer")
+	}
+
+	close(release)
+	stock.SetPrice(101)
+	// The two deliveries may finish in either order; Seq tells them apart
+	if first, second := <-seqs, <-seqs; min(first, second) != 1 || max(first, second) != 2 {
+		t.Fatalf("quotes carry sequence numbers %d and %d, want 1 and 2", first, second)
+	}
+}
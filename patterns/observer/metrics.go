@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the histogram upper bounds used by NewLatencyHistogram
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 5 * time.Second,
+}
+
+// LatencyHistogram tracks delivery latencies per observer in fixed buckets
+type LatencyHistogram struct {
+	bounds []time.Duration
+	mutex  sync.Mutex
+	series map[string]*histogramSeries
+}
+
+// histogramSeries holds the counters of one observer
+type histogramSeries struct {
+	counts []uint64 // one per bound plus an overflow bucket
+	sum    time.Duration
+	errors uint64
+}
+
+// NewLatencyHistogram creates a histogram with the given upper bounds, or
+// DefaultLatencyBuckets when none are passed
+func NewLatencyHistogram(bounds ...time.Duration) *LatencyHistogram {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBuckets
+	}
+	bounds = append([]time.Duration(nil), bounds...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	return &LatencyHistogram{bounds: bounds, series: make(map[string]*histogramSeries)}
+}
+
+// Observe records one delivery
+func (h *LatencyHistogram) Observe(observerID string, latency time.Duration, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s, ok := h.series[observerID]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.bounds)+1)}
+		h.series[observerID] = s
+	}
+	i := sort.Search(len(h.bounds), func(i int) bool { return latency <= h.bounds[i] })
+	s.counts[i]++
+	s.sum += latency
+	if err != nil {
+		s.errors++
+	}
+}
+
+// HistogramSnapshot is a copy of one observer's latency distribution
+type HistogramSnapshot struct {
+	ObserverID string
+	Bounds     []time.Duration
+	Counts     []uint64 // Counts[len(Bounds)] holds latencies above the last bound
+	Count      uint64
+	Errors     uint64
+	Sum        time.Duration
+}
+
+// Mean returns the average latency
+func (s HistogramSnapshot) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / time.Duration(s.Count)
+}
+
+// Quantile returns the upper bound of the bucket holding quantile q (0..1);
+// latencies above the last bound report that bound
+func (s HistogramSnapshot) Quantile(q float64) time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	target := uint64(q*float64(s.Count) + 0.5)
+	if target == 0 {
+		target = 1
+	}
+	var seen uint64
+	for i, count := range s.Counts {
+		seen += count
+		if seen >= target {
+			return s.Bounds[min(i, len(s.Bounds)-1)]
+		}
+	}
+	return s.Bounds[len(s.Bounds)-1]
+}
+
+// String formats the snapshot as a single log line
+func (s HistogramSnapshot) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: n=%d errors=%d mean=%v p50<=%v p99<=%v",
+		s.ObserverID, s.Count, s.Errors, s.Mean().Round(time.Microsecond), s.Quantile(0.5), s.Quantile(0.99))
+	return b.String()
+}
+
+// Snapshot returns the distribution of every observer, sorted by ID
+func (h *LatencyHistogram) Snapshot() []HistogramSnapshot {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	snapshots := make([]HistogramSnapshot, 0, len(h.series))
+	for id, s := range h.series {
+		snapshot := HistogramSnapshot{
+			ObserverID: id,
+			Bounds:     h.bounds,
+			Counts:     append([]uint64(nil), s.counts...),
+			Errors:     s.errors,
+			Sum:        s.sum,
+		}
+		for _, count := range s.counts {
+			snapshot.Count += count
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].ObserverID < snapshots[j].ObserverID })
+	return snapshots
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Metadata keys set by the built-in middleware
+const (
+	TraceIDKey = "trace_id"
+)
+
+// PublishFunc publishes an event to the matching subscriptions
+type PublishFunc func(event Event)
+
+// PublishMiddleware wraps every Notify call
+type PublishMiddleware func(next PublishFunc) PublishFunc
+
+// Delivery describes a single attempt to update one observer
+type Delivery struct {
+	Observer  Observer
+	EventType string // the subscription pattern
+	Event     Event
+	Attempt   int // starts at 1, increases with retries
+}
+
+// DeliverFunc performs a delivery
+type DeliverFunc func(delivery *Delivery) error
+
+// DeliveryMiddleware wraps every Observer.Update call, retries included
+type DeliveryMiddleware func(next DeliverFunc) DeliverFunc
+
+// WithPublishMiddleware adds publish middleware; the first one is the outermost
+func WithPublishMiddleware(middleware ...PublishMiddleware) DispatcherOption {
+	return func(ed *EventDispatcher) {
+		ed.publishMiddleware = append(ed.publishMiddleware, middleware...)
+	}
+}
+
+// WithDeliveryMiddleware adds delivery middleware; the first one is the outermost
+func WithDeliveryMiddleware(middleware ...DeliveryMiddleware) DispatcherOption {
+	return func(ed *EventDispatcher) {
+		ed.deliveryMiddleware = append(ed.deliveryMiddleware, middleware...)
+	}
+}
+
+// chainPublish wraps base with the middleware, first one outermost
+func chainPublish(base PublishFunc, middleware []PublishMiddleware) PublishFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		base = middleware[i](base)
+	}
+	return base
+}
+
+// chainDelivery wraps base with the middleware, first one outermost
+func chainDelivery(base DeliverFunc, middleware []DeliveryMiddleware) DeliverFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		base = middleware[i](base)
+	}
+	return base
+}
+
+// safeDeliver is the innermost DeliverFunc: it calls the observer with panic recovery
+func safeDeliver(delivery *Delivery) error {
+	return SafeUpdate(delivery.Observer, delivery.Event)
+}
+
+// withMetadata returns a copy of the event with one metadata entry set;
+// published events share their metadata map, so it is never modified in place
+func withMetadata(event Event, key, value string) Event {
+	metadata := make(map[string]string, len(event.Metadata)+1)
+	for k, v := range event.Metadata {
+		metadata[k] = v
+	}
+	metadata[key] = value
+	event.Metadata = metadata
+	return event
+}
+
+// PublishLoggingMiddleware logs every published event
+func PublishLoggingMiddleware(logger *slog.Logger) PublishMiddleware {
+	return func(next PublishFunc) PublishFunc {
+		return func(event Event) {
+			logger.Info("publish",
+				"event_type", event.Type,
+				"source", event.Source,
+				"trace_id", event.Metadata[TraceIDKey])
+			next(event)
+		}
+	}
+}
+
+// LoggingMiddleware logs every delivery attempt with its outcome and latency
+func LoggingMiddleware(logger *slog.Logger) DeliveryMiddleware {
+	return func(next DeliverFunc) DeliverFunc {
+		return func(delivery *Delivery) error {
+			start := time.Now()
+			err := next(delivery)
+			attrs := []any{
+				"observer", delivery.Observer.GetID(),
+				"event_type", delivery.Event.Type,
+				"subscription", delivery.EventType,
+				"attempt", delivery.Attempt,
+				"duration", time.Since(start),
+			}
+			if traceID := delivery.Event.Metadata[TraceIDKey]; traceID != "" {
+				attrs = append(attrs, "trace_id", traceID)
+			}
+			if err != nil {
+				logger.Error("delivery failed", append(attrs, "error", err)...)
+			} else {
+				logger.Debug("delivered", attrs...)
+			}
+			return err
+		}
+	}
+}
+
+// MetricsMiddleware records the latency of every delivery attempt per observer
+func MetricsMiddleware(histogram *LatencyHistogram) DeliveryMiddleware {
+	return func(next DeliverFunc) DeliverFunc {
+		return func(delivery *Delivery) error {
+			start := time.Now()
+			err := next(delivery)
+			histogram.Observe(delivery.Observer.GetID(), time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// Span is a timed unit of work within a trace
+type Span struct {
+	TraceID    string
+	SpanID     string
+	Name       string
+	ObserverID string
+	Start      time.Time
+	Duration   time.Duration
+	Err        error
+}
+
+// TracingPublishMiddleware gives every published event a trace ID unless it
+// already carries one, so all of its deliveries can be correlated
+func TracingPublishMiddleware() PublishMiddleware {
+	return func(next PublishFunc) PublishFunc {
+		return func(event Event) {
+			if event.Metadata[TraceIDKey] == "" {
+				event = withMetadata(event, TraceIDKey, uuid.NewString())
+			}
+			next(event)
+		}
+	}
+}
+
+// TracingMiddleware records a span for every delivery attempt, linked to the
+// event's trace ID
+func TracingMiddleware(record func(Span)) DeliveryMiddleware {
+	return func(next DeliverFunc) DeliverFunc {
+		return func(delivery *Delivery) error {
+			span := Span{
+				TraceID:    delivery.Event.Metadata[TraceIDKey],
+				SpanID:     uuid.NewString(),
+				Name:       "deliver " + delivery.Event.Type,
+				ObserverID: delivery.Observer.GetID(),
+				Start:      time.Now(),
+			}
+			err := next(delivery)
+			span.Duration = time.Since(span.Start)
+			span.Err = err
+			record(span)
+			return err
+		}
+	}
+}
+
+// tokenBucket is a simple token-bucket limiter
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// wait blocks until a token is available
+func (b *tokenBucket) wait() {
+	for {
+		b.mutex.Lock()
+		now := time.Now()
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mutex.Unlock()
+			return
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mutex.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// rateLimiter holds the token buckets of RateLimitMiddleware, one per
+// observer ID
+type rateLimiter struct {
+	rate      float64
+	burst     float64
+	mutex     sync.Mutex
+	buckets   map[string]*limitedBucket
+	lastSweep time.Time
+}
+
+// limitedBucket is a bucket with the number of deliveries waiting on it
+type limitedBucket struct {
+	tokenBucket
+	inUse int // guarded by the limiter's mutex
+}
+
+// acquire returns the observer's bucket, creating it on first use. Buckets
+// left alone long enough to refill completely are dropped on the way, as a
+// full bucket is no different from a new one; this also forgets observers
+// that have unsubscribed.
+func (l *rateLimiter) acquire(id string, now time.Time) *limitedBucket {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastSweep) >= refill {
+		for key, bucket := range l.buckets {
+			bucket.mutex.Lock()
+			idle := now.Sub(bucket.last)
+			bucket.mutex.Unlock()
+			if bucket.inUse == 0 && idle >= refill {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	bucket, ok := l.buckets[id]
+	if !ok {
+		bucket = &limitedBucket{tokenBucket: tokenBucket{rate: l.rate, burst: l.burst, tokens: l.burst, last: now}}
+		l.buckets[id] = bucket
+	}
+	bucket.inUse++
+	return bucket
+}
+
+// release marks a delivery as done with its bucket
+func (l *rateLimiter) release(bucket *limitedBucket) {
+	l.mutex.Lock()
+	bucket.inUse--
+	l.mutex.Unlock()
+}
+
+// size returns how many buckets are kept
+func (l *rateLimiter) size() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return len(l.buckets)
+}
+
+// RateLimitMiddleware limits each observer to rate deliveries per second with
+// the given burst. Deliveries over the limit wait for a token, which pushes
+// back on the publisher or the subscription's queue. It panics unless rate
+// is positive and burst at least 1, since no delivery could ever pass.
+func RateLimitMiddleware(rate float64, burst int) DeliveryMiddleware {
+	if !(rate > 0) || burst < 1 {
+		panic(fmt.Sprintf("rate limit needs a positive rate and a burst of at least 1, got %v and %d", rate, burst))
+	}
+	return newRateLimiter(rate, burst).middleware
+}
+
+// newRateLimiter creates an empty limiter
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*limitedBucket)}
+}
+
+// middleware is the DeliveryMiddleware of the limiter
+func (l *rateLimiter) middleware(next DeliverFunc) DeliverFunc {
+	return func(delivery *Delivery) error {
+		bucket := l.acquire(delivery.Observer.GetID(), time.Now())
+		bucket.wait()
+		l.release(bucket)
+		return next(delivery)
+	}
+}
+
+// Sampler counts the deliveries kept and skipped by SamplingMiddleware
+type Sampler struct {
+	rate    float64
+	mutex   sync.Mutex
+	rng     *rand.Rand
+	kept    atomic.Uint64
+	skipped atomic.Uint64
+}
+
+// NewSampler keeps roughly rate (0..1) of all deliveries
+func NewSampler(rate float64, seed int64) *Sampler {
+	return &Sampler{rate: rate, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Kept returns how many deliveries were passed on
+func (s *Sampler) Kept() uint64 {
+	return s.kept.Load()
+}
+
+// Skipped returns how many deliveries were dropped
+func (s *Sampler) Skipped() uint64 {
+	return s.skipped.Load()
+}
+
+// keep decides whether to sample the next delivery
+func (s *Sampler) keep() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.rng.Float64() < s.rate
+}
+
+// SamplingMiddleware only delivers a random sample of events; skipped
+// deliveries count as successful. Retries of a kept delivery are always kept.
+func SamplingMiddleware(sampler *Sampler) DeliveryMiddleware {
+	return func(next DeliverFunc) DeliverFunc {
+		return func(delivery *Delivery) error {
+			if delivery.Attempt == 1 && !sampler.keep() {
+				sampler.skipped.Add(1)
+				return nil
+			}
+			if delivery.Attempt == 1 {
+				sampler.kept.Add(1)
+			}
+			return next(delivery)
+		}
+	}
+}
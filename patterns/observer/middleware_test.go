@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// funcObserver adapts a function to the Observer interface
+type funcObserver struct {
+	id string
+	fn func(Event) error
+}
+
+func (f *funcObserver) Update(event Event) error {
+	return f.fn(event)
+}
+
+func (f *funcObserver) GetID() string {
+	return f.id
+}
+
+func TestMiddleware_RunsInOrder(t *testing.T) {
+	var mutex sync.Mutex
+	var calls []string
+	trace := func(name string) DeliveryMiddleware {
+		return func(next DeliverFunc) DeliverFunc {
+			return func(delivery *Delivery) error {
+				mutex.Lock()
+				calls = append(calls, name+">")
+				mutex.Unlock()
+				err := next(delivery)
+				mutex.Lock()
+				calls = append(calls, "<"+name)
+				mutex.Unlock()
+				return err
+			}
+		}
+	}
+	var published []string
+	tag := func(next PublishFunc) PublishFunc {
+		return func(event Event) {
+			published = append(published, event.Type)
+			next(withMetadata(event, "tag", "x"))
+		}
+	}
+
+	dispatcher := NewEventDispatcher(
+		WithPublishMiddleware(tag),
+		WithDeliveryMiddleware(trace("outer"), trace("inner")),
+	)
+	var seen Event
+	dispatcher.Subscribe("price_update", &funcObserver{id: "rec", fn: func(event Event) error {
+		seen = event
+		return nil
+	}})
+	dispatcher.Notify(priceEvent(1))
+
+	if want := "outer> inner> <inner <outer"; strings.Join(calls, " ") != want {
+		t.Fatalf("calls = %v, want %s", calls, want)
+	}
+	if len(published) != 1 || seen.Metadata["tag"] != "x" {
+		t.Fatalf("publish middleware not applied: published=%v metadata=%v", published, seen.Metadata)
+	}
+}
+
+func TestMiddleware_WrapsEveryAttempt(t *testing.T) {
+	var attempts []int
+	record := func(next DeliverFunc) DeliverFunc {
+		return func(delivery *Delivery) error {
+			attempts = append(attempts, delivery.Attempt)
+			return next(delivery)
+		}
+	}
+	dispatcher := NewEventDispatcher(WithDeliveryMiddleware(record))
+	dispatcher.Subscribe("price_update", NewFlakyObserver("flaky", 2), WithRetry(fastRetry))
+	dispatcher.Notify(priceEvent(1))
+
+	if len(attempts) != 3 || attempts[0] != 1 || attempts[2] != 3 {
+		t.Fatalf("attempts = %v, want [1 2 3]", attempts)
+	}
+	if dispatcher.DeadLetters().Len() != 0 {
+		t.Fatal("third attempt should have succeeded")
+	}
+}
+
+func TestMiddleware_ErrorsReachDeadLetters(t *testing.T) {
+	errBlocked := errors.New("blocked")
+	block := func(next DeliverFunc) DeliverFunc {
+		return func(*Delivery) error { return errBlocked }
+	}
+	dispatcher := NewEventDispatcher(WithDeliveryMiddleware(block))
+	dispatcher.Subscribe("price_update", &recordingObserver{id: "rec"})
+	dispatcher.Notify(priceEvent(1))
+
+	letters := dispatcher.DeadLetters().List()
+	if len(letters) != 1 || !errors.Is(letters[0].Err, errBlocked) {
+		t.Fatalf("expected one dead letter with errBlocked, got %+v", letters)
+	}
+}
+
+func TestTracingMiddleware_SharesTraceID(t *testing.T) {
+	var mutex sync.Mutex
+	var spans []Span
+	dispatcher := NewEventDispatcher(
+		WithPublishMiddleware(TracingPublishMiddleware()),
+		WithDeliveryMiddleware(TracingMiddleware(func(span Span) {
+			mutex.Lock()
+			spans = append(spans, span)
+			mutex.Unlock()
+		})),
+	)
+	dispatcher.Subscribe("price_update", &recordingObserver{id: "a"})
+	dispatcher.Subscribe("price_update", &recordingObserver{id: "b"})
+	dispatcher.Notify(priceEvent(1))
+
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	if spans[0].TraceID == "" || spans[0].TraceID != spans[1].TraceID {
+		t.Fatalf("deliveries of one event should share a trace ID: %q %q", spans[0].TraceID, spans[1].TraceID)
+	}
+	if spans[0].SpanID == spans[1].SpanID {
+		t.Fatal("span IDs should be unique")
+	}
+}
+
+func TestLoggingMiddleware_LogsFailures(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	dispatcher := NewEventDispatcher(WithDeliveryMiddleware(LoggingMiddleware(logger)))
+	dispatcher.Subscribe("price_update", NewFlakyObserver("flaky", 1))
+	dispatcher.Notify(priceEvent(1))
+
+	out := buf.String()
+	if !strings.Contains(out, `msg="delivery failed"`) || !strings.Contains(out, "observer=flaky") {
+		t.Fatalf("unexpected log output: %s", out)
+	}
+}
+
+func TestMetricsMiddleware_RecordsLatency(t *testing.T) {
+	histogram := NewLatencyHistogram(time.Millisecond, 50*time.Millisecond)
+	dispatcher := NewEventDispatcher(WithDeliveryMiddleware(MetricsMiddleware(histogram)))
+	dispatcher.Subscribe("price_update", &funcObserver{id: "slow", fn: func(Event) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}})
+	for i := 0; i < 4; i++ {
+		dispatcher.Notify(priceEvent(float64(i)))
+	}
+
+	snapshots := histogram.Snapshot()
+	if len(snapshots) != 1 || snapshots[0].Count != 4 {
+		t.Fatalf("unexpected snapshots: %+v", snapshots)
+	}
+	if q := snapshots[0].Quantile(0.5); q != 50*time.Millisecond {
+		t.Fatalf("p50 bucket = %v, want 50ms", q)
+	}
+}
+
+func TestLatencyHistogram_Quantile(t *testing.T) {
+	histogram := NewLatencyHistogram(10*time.Millisecond, 100*time.Millisecond)
+	for i := 0; i < 9; i++ {
+		histogram.Observe("obs", time.Millisecond, nil)
+	}
+	histogram.Observe("obs", time.Second, errors.New("slow"))
+
+	snapshot := histogram.Snapshot()[0]
+	if snapshot.Quantile(0.5) != 10*time.Millisecond {
+		t.Fatalf("p50 = %v", snapshot.Quantile(0.5))
+	}
+	if snapshot.Quantile(1) != 100*time.Millisecond {
+		t.Fatalf("p100 should clamp to the last bound, got %v", snapshot.Quantile(1))
+	}
+	if snapshot.Errors != 1 || snapshot.Counts[2] != 1 {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+}
+
+func TestRateLimitMiddleware_Throttles(t *testing.T) {
+	dispatcher := NewEventDispatcher(WithDeliveryMiddleware(RateLimitMiddleware(100, 2)))
+	dispatcher.Subscribe("price_update", &recordingObserver{id: "rec"})
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		dispatcher.Notify(priceEvent(float64(i)))
+	}
+	// Two events pass on the burst, the other four wait ~10ms each
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Fatalf("6 deliveries at 100/s with burst 2 took only %v", elapsed)
+	}
+}
+
+func TestRateLimitMiddleware_DropsIdleBuckets(t *testing.T) {
+	limiter := newRateLimiter(1000, 1)
+	dispatcher := NewEventDispatcher(WithDeliveryMiddleware(limiter.middleware))
+	for i := 0; i < 5; i++ {
+		obs := &recordingObserver{id: fmt.Sprint("short-lived-", i)}
+		dispatcher.Subscribe("price_update", obs)
+		dispatcher.Notify(priceEvent(1))
+		dispatcher.Unsubscribe("price_update", obs)
+	}
+	if limiter.size() == 0 {
+		t.Fatal("recently used buckets should be kept")
+	}
+
+	// A full bucket refills in 1ms; afterwards only the active observer's is left
+	time.Sleep(5 * time.Millisecond)
+	dispatcher.Subscribe("price_update", &recordingObserver{id: "active"})
+	dispatcher.Notify(priceEvent(2))
+	if limiter.size() != 1 {
+		t.Fatalf("idle buckets should be dropped, %d left", limiter.size())
+	}
+}
+
+func TestRateLimitMiddleware_RejectsInvalidLimits(t *testing.T) {
+	for _, limit := range []struct {
+		rate  float64
+		burst int
+	}{{0, 1}, {-1, 1}, {10, 0}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("rate %v burst %d should panic", limit.rate, limit.burst)
+				}
+			}()
+			RateLimitMiddleware(limit.rate, limit.burst)
+		}()
+	}
+}
+
+func TestSamplingMiddleware_SkipsDeliveries(t *testing.T) {
+	sampler := NewSampler(0.25, 42)
+	dispatcher := NewEventDispatcher(WithDeliveryMiddleware(SamplingMiddleware(sampler)))
+	obs := &recordingObserver{id: "rec"}
+	dispatcher.Subscribe("price_update", obs)
+	for i := 0; i < 200; i++ {
+		dispatcher.Notify(priceEvent(float64(i)))
+	}
+
+	if sampler.Kept()+sampler.Skipped() != 200 {
+		t.Fatalf("kept %d + skipped %d != 200", sampler.Kept(), sampler.Skipped())
+	}
+	if got := uint64(len(obs.prices())); got != sampler.Kept() {
+		t.Fatalf("observer saw %d events, sampler kept %d", got, sampler.Kept())
+	}
+	if sampler.Kept() < 25 || sampler.Kept() > 75 {
+		t.Fatalf("kept %d of 200 at rate 0.25", sampler.Kept())
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// OverflowPolicy decides what an asynchronous subscription does when its queue is full
+type OverflowPolicy int
+
+const (
+	// Block makes the publisher wait until the queue has room
+	Block OverflowPolicy = iota
+	// DropNewest discards the incoming event
+	DropNewest
+	// DropOldest discards the oldest queued event to make room for the new one
+	DropOldest
+	// SpillToDisk writes overflowing events to a file and delivers them later in order
+	SpillToDisk
+)
+
+// String returns the policy name
+func (p OverflowPolicy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropNewest:
+		return "drop-newest"
+	case DropOldest:
+		return "drop-oldest"
+	case SpillToDisk:
+		return "spill-to-disk"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+	}
+}
+
+// QueueStats is a snapshot of an asynchronous subscription's queue
+type QueueStats struct {
+	ObserverID string
+	EventType  string
+	Lane       int
+	Policy     OverflowPolicy
+	Pending    int    // events waiting in memory or on disk
+	Delivered  uint64 // events handed to the observer
+	Dropped    uint64 // events discarded by the overflow policy
+	Spilled    uint64 // events that went through the spill file
+}
+
+// deliveryQueue is a bounded FIFO drained by a single delivery goroutine
+type deliveryQueue struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	buf      []Event
+	capacity int
+	policy   OverflowPolicy
+	spillDir string
+	spill    *spillFile
+	deliver  func(Event)
+	busy     bool // an event is being delivered
+	closed   bool
+	done     chan struct{}
+
+	delivered uint64
+	dropped   uint64
+	spilled   uint64
+}
+
+// newDeliveryQueue creates a queue and starts its delivery goroutine
+func newDeliveryQueue(capacity int, policy OverflowPolicy, spillDir string, deliver func(Event)) *deliveryQueue {
+	if capacity <= 0 {
+		panic("queue capacity must be positive")
+	}
+
+	q := &deliveryQueue{
+		buf:      make([]Event, 0, capacity),
+		capacity: capacity,
+		policy:   policy,
+		spillDir: spillDir,
+		deliver:  deliver,
+		done:     make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	go q.run()
+	return q
+}
+
+// push enqueues an event according to the overflow policy and reports
+// whether it was queued
+func (q *deliveryQueue) push(event Event) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.policy == Block {
+		for len(q.buf) >= q.capacity && !q.closed {
+			q.cond.Wait()
+		}
+	}
+	if q.closed {
+		q.dropped++
+		return false
+	}
+
+	switch {
+	case q.policy == SpillToDisk && (len(q.buf) >= q.capacity || q.spillPending() > 0):
+		// Once anything is on disk, newer events must queue behind it
+		if err := q.spillEvent(event); err != nil {
+			fmt.Printf("Spill failed, dropping event %s: %v\n", event.Type, err)
+			q.dropped++
+			return false
+		}
+		q.spilled++
+	case len(q.buf) >= q.capacity && q.policy == DropNewest:
+		q.dropped++
+		return false
+	case len(q.buf) >= q.capacity && q.policy == DropOldest:
+		q.buf = append(q.buf[1:], event)
+		q.dropped++
+	default:
+		q.buf = append(q.buf, event)
+	}
+	q.cond.Broadcast()
+	return true
+}
+
+// run delivers queued events until the queue is closed and empty
+func (q *deliveryQueue) run() {
+	defer close(q.done)
+
+	for {
+		q.mu.Lock()
+		for len(q.buf) == 0 && q.spillPending() == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		event, ok := q.pop()
+		if !ok {
+			q.mu.Unlock()
+			return
+		}
+		q.busy = true
+		q.cond.Broadcast()
+		q.mu.Unlock()
+
+		q.deliver(event)
+
+		q.mu.Lock()
+		q.busy = false
+		q.delivered++
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}
+}
+
+// pop takes the next event, memory first since spilled events are always newer
+func (q *deliveryQueue) pop() (Event, bool) {
+	if len(q.buf) > 0 {
+		event := q.buf[0]
+		q.buf = q.buf[1:]
+		return event, true
+	}
+	for q.spillPending() > 0 {
+		event, err := q.spill.read()
+		if err == nil {
+			return event, true
+		}
+		fmt.Printf("Reading spilled event failed: %v\n", err)
+		q.dropped++
+	}
+	return Event{}, false
+}
+
+// spillEvent appends an event to the spill file, creating it on first use
+func (q *deliveryQueue) spillEvent(event Event) error {
+	if q.spill == nil {
+		spill, err := openSpillFile(q.spillDir)
+		if err != nil {
+			return err
+		}
+		q.spill = spill
+	}
+	return q.spill.write(event)
+}
+
+// spillPending returns the number of events waiting in the spill file
+func (q *deliveryQueue) spillPending() int {
+	if q.spill == nil {
+		return 0
+	}
+	return q.spill.pending
+}
+
+// flush waits until every queued event has been delivered
+func (q *deliveryQueue) flush() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.buf) > 0 || q.spillPending() > 0 || q.busy {
+		select {
+		case <-q.done:
+			return
+		default:
+		}
+		q.cond.Wait()
+	}
+}
+
+// close stops accepting events, drains what is queued and removes the spill file
+func (q *deliveryQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	<-q.done
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.spill != nil {
+		q.spill.remove()
+		q.spill = nil
+	}
+}
+
+// stats returns a snapshot of the queue counters
+func (q *deliveryQueue) stats(eventType, observerID string) QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return QueueStats{
+		ObserverID: observerID,
+		EventType:  eventType,
+		Policy:     q.policy,
+		Pending:    len(q.buf) + q.spillPending(),
+		Delivered:  q.delivered,
+		Dropped:    q.dropped,
+		Spilled:    q.spilled,
+	}
+}
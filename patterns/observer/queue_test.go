@@ -0,0 +1,148 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingObserver remembers the prices it has seen and can be paused
+type recordingObserver struct {
+	id   string
+	gate chan struct{} // when non-nil every Update waits for a token
+	mu   sync.Mutex
+	seen []float64
+}
+
+func (r *recordingObserver) Update(event Event) error {
+	if r.gate != nil {
+		<-r.gate
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seen = append(r.seen, event.Data.(float64))
+	return nil
+}
+
+func (r *recordingObserver) GetID() string {
+	return r.id
+}
+
+func (r *recordingObserver) prices() []float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]float64(nil), r.seen...)
+}
+
+func priceEvent(price float64) Event {
+	return Event{Type: "price_update", Data: price, Timestamp: time.Now(), Source: "test"}
+}
+
+// waitForBusy waits until the delivery goroutine has taken an event off the queue
+func waitForBusy(t *testing.T, q *deliveryQueue) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		q.mu.Lock()
+		busy := q.busy
+		q.mu.Unlock()
+		if busy {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("delivery goroutine never picked up an event")
+}
+
+func assertPrices(t *testing.T, got []float64, want ...float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestDeliveryQueue_OverflowPolicies(t *testing.T) {
+	tests := []struct {
+		policy      OverflowPolicy
+		wantPrices  []float64
+		wantDropped uint64
+	}{
+		{DropNewest, []float64{1, 2, 3}, 2},
+		{DropOldest, []float64{1, 4, 5}, 2},
+		{SpillToDisk, []float64{1, 2, 3, 4, 5}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			obs := &recordingObserver{id: "rec", gate: make(chan struct{})}
+			dispatcher := NewEventDispatcher()
+			dispatcher.Subscribe("price_update", obs, WithAsyncQueue(2, tt.policy), WithSpillDir(t.TempDir()))
+			sub := dispatcher.topics.match("price_update")[0]
+
+			// The first event is held by the observer, the rest hit the queue
+			dispatcher.Notify(priceEvent(1))
+			waitForBusy(t, sub.lanes[0])
+			for _, price := range []float64{2, 3, 4, 5} {
+				dispatcher.Notify(priceEvent(price))
+			}
+			close(obs.gate)
+			dispatcher.Flush()
+
+			assertPrices(t, obs.prices(), tt.wantPrices...)
+			stats := dispatcher.QueueStats()[0]
+			if stats.Dropped != tt.wantDropped || stats.Pending != 0 {
+				t.Fatalf("unexpected stats: %+v", stats)
+			}
+			dispatcher.Close()
+		})
+	}
+}
+
+func TestDeliveryQueue_BlockAppliesBackpressure(t *testing.T) {
+	obs := &recordingObserver{id: "rec", gate: make(chan struct{})}
+	dispatcher := NewEventDispatcher()
+	dispatcher.Subscribe("price_update", obs, WithAsyncQueue(1, Block))
+	sub := dispatcher.topics.match("price_update")[0]
+
+	dispatcher.Notify(priceEvent(1))
+	waitForBusy(t, sub.lanes[0])
+	dispatcher.Notify(priceEvent(2))
+
+	published := make(chan struct{})
+	go func() {
+		dispatcher.Notify(priceEvent(3))
+		close(published)
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("publisher should block while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(obs.gate)
+	<-published
+	dispatcher.Close()
+	assertPrices(t, obs.prices(), 1, 2, 3)
+}
+
+func TestEventDispatcher_CloseDrainsQueues(t *testing.T) {
+	obs := &recordingObserver{id: "rec"}
+	dispatcher := NewEventDispatcher()
+	dispatcher.Subscribe("price_update", obs, WithAsyncQueue(100, Block))
+
+	for i := 1; i <= 50; i++ {
+		dispatcher.Notify(priceEvent(float64(i)))
+	}
+	dispatcher.Close()
+	dispatcher.Notify(priceEvent(51))
+
+	if got := len(obs.prices()); got != 50 {
+		t.Fatalf("expected 50 delivered events, got %d", got)
+	}
+}
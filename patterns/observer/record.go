@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// ErrUnregisteredPayload is returned when a persisted event's payload type
+// was not registered with RegisterPayload
+var ErrUnregisteredPayload = errors.New("event payload type is not registered")
+
+// ErrCorruptRecord is returned when a record's length does not fit the file
+var ErrCorruptRecord = errors.New("corrupt event record")
+
+// maxRecordSize bounds a single encoded event, so that a corrupt length
+// cannot make a read allocate gigabytes
+const maxRecordSize = 16 << 20
+
+// payloadTypes holds the payload types registered with RegisterPayload
+var payloadTypes sync.Map // reflect.Type to struct{}
+
+func init() {
+	RegisterPayload(StockQuote{})
+	RegisterPayload(SystemAlert{})
+}
+
+// RegisterPayload makes events carrying payloads of the same type as
+// payload storable in a persistent EventStore. Event.Data is an interface,
+// so gob needs every concrete type; register them before OpenEventStore.
+// Predeclared types such as float64 or string, and slices of them, need no
+// registration.
+func RegisterPayload(payload any) {
+	gob.Register(payload)
+	payloadTypes.Store(reflect.TypeOf(payload), struct{}{})
+}
+
+// checkPayload rejects payloads that cannot be persisted
+func checkPayload(data any) error {
+	if data == nil {
+		return nil
+	}
+	t := reflect.TypeOf(data)
+	if _, ok := payloadTypes.Load(t); ok {
+		return nil
+	}
+	basic := t
+	if basic.Kind() == reflect.Slice {
+		basic = basic.Elem()
+	}
+	switch basic.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.UnsafePointer:
+	default:
+		if basic.PkgPath() == "" && basic.Name() != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %T", ErrUnregisteredPayload, data)
+}
+
+// writeRecord writes a gob-encoded event prefixed by its length at off
+func writeRecord(file *os.File, off int64, event Event) (int64, error) {
+	var body bytes.Buffer
+	if err := gob.NewEncoder(&body).Encode(&event); err != nil {
+		return 0, fmt.Errorf("encode event: %w", err)
+	}
+	if body.Len() > maxRecordSize {
+		return 0, fmt.Errorf("encode event: %d bytes exceeds the record limit of %d", body.Len(), maxRecordSize)
+	}
+
+	record := make([]byte, 4+body.Len())
+	binary.BigEndian.PutUint32(record, uint32(body.Len()))
+	copy(record[4:], body.Bytes())
+	if _, err := file.WriteAt(record, off); err != nil {
+		return 0, fmt.Errorf("write record: %w", err)
+	}
+	return int64(len(record)), nil
+}
+
+// readRecord reads the record at off and returns it with its size on disk.
+// A length past the end of the file or over maxRecordSize is reported as
+// ErrCorruptRecord before anything is allocated.
+func readRecord(file *os.File, off int64) (Event, int64, error) {
+	var header [4]byte
+	if _, err := file.ReadAt(header[:], off); err != nil {
+		return Event{}, 0, fmt.Errorf("read record header: %w", err)
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	info, err := file.Stat()
+	if err != nil {
+		return Event{}, 4, fmt.Errorf("read record body: %w", err)
+	}
+	if size > maxRecordSize || off+4+int64(size) > info.Size() {
+		return Event{}, 4, fmt.Errorf("%w: %d bytes at offset %d of a %d byte file", ErrCorruptRecord, size, off, info.Size())
+	}
+	body := make([]byte, size)
+	if _, err := file.ReadAt(body, off+4); err != nil {
+		return Event{}, 4, fmt.Errorf("read record body: %w", err)
+	}
+
+	var event Event
+	if err := gob.NewDecoder(bytes.NewReader(body)).Decode(&event); err != nil {
+		return Event{}, int64(4 + size), fmt.Errorf("decode event: %w", err)
+	}
+	return event, int64(4 + size), nil
+}
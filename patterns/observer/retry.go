@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// PanicError is returned by SafeUpdate when an observer panics
+type PanicError struct {
+	ObserverID string
+	Value      interface{}
+	Stack      []byte
+}
+
+// Error implements error
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("observer %s panicked: %v", e.ObserverID, e.Value)
+}
+
+// SafeUpdate calls observer.Update and turns a panic into a *PanicError,
+// so one misbehaving observer cannot crash the process
+func SafeUpdate(observer Observer, event Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{ObserverID: observer.GetID(), Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return observer.Update(event)
+}
+
+// RetryPolicy controls how failed deliveries are retried
+type RetryPolicy struct {
+	MaxAttempts    int           // total attempts including the first; values below 2 disable retries
+	InitialBackoff time.Duration // wait before the first retry
+	MaxBackoff     time.Duration // upper bound for the wait, 0 means unbounded
+	Multiplier     float64       // backoff growth per retry, values below 1 keep it constant
+}
+
+// DefaultRetryPolicy tries three times with exponential backoff starting at 10ms
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     time.Second,
+	Multiplier:     2,
+}
+
+// noRetry delivers once; used when a subscription sets no policy
+var noRetry = RetryPolicy{MaxAttempts: 1}
+
+// Backoff returns the wait before retry number n (starting at 1)
+func (p RetryPolicy) Backoff(n int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < n && p.Multiplier > 1; i++ {
+		backoff = time.Duration(float64(backoff) * p.Multiplier)
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
+// WithRetry retries failed or panicking deliveries according to policy
+// before the event is moved to the dead-letter store
+func WithRetry(policy RetryPolicy) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.retry = policy
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSafeUpdate_RecoversPanic(t *testing.T) {
+	err := SafeUpdate(NewFlakyObserver("flaky", 1), priceEvent(1))
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.ObserverID != "flaky" || len(panicErr.Stack) == 0 {
+		t.Fatalf("expected PanicError, got %v", err)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, Multiplier: 2}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := policy.Backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("retry %d: expected %v, got %v", i+1, w*time.Millisecond, got)
+		}
+	}
+}
+
+func TestEventDispatcher_RetryThenDeadLetter(t *testing.T) {
+	dispatcher := NewEventDispatcher()
+	retry := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	recovers := NewFlakyObserver("recovers", 2)
+	deadLettered := NewFlakyObserver("dead-lettered", 4)
+	dispatcher.Subscribe("price_update", recovers, WithRetry(retry))
+	dispatcher.Subscribe("price_update", deadLettered, WithRetry(retry))
+	dispatcher.Notify(priceEvent(1))
+
+	if recovers.calls != 3 {
+		t.Fatalf("expected 3 attempts for the recovering observer, got %d", recovers.calls)
+	}
+	letters := dispatcher.DeadLetters().List()
+	if len(letters) != 1 || letters[0].ObserverID != "dead-lettered" || letters[0].Attempts != 3 {
+		t.Fatalf("unexpected dead letters: %+v", letters)
+	}
+
+	if err := dispatcher.Redrive(letters[0].ID); err != nil {
+		t.Fatalf("redrive failed: %v", err)
+	}
+	if dispatcher.DeadLetters().Len() != 0 || deadLettered.calls != 5 {
+		t.Fatalf("expected a successful redrive, calls=%d letters=%d", deadLettered.calls, dispatcher.DeadLetters().Len())
+	}
+	if err := dispatcher.Redrive(letters[0].ID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Fatalf("expected ErrDeadLetterNotFound, got %v", err)
+	}
+}
+
+func TestEventDispatcher_RedriveAfterUnsubscribe(t *testing.T) {
+	dispatcher := NewEventDispatcher()
+	observer := NewFlakyObserver("flaky", 1)
+	dispatcher.Subscribe("price_update", observer)
+	dispatcher.Notify(priceEvent(1))
+	dispatcher.Unsubscribe("price_update", observer)
+
+	letter := dispatcher.DeadLetters().List()[0]
+	if err := dispatcher.Redrive(letter.ID); !errors.Is(err, ErrSubscriptionGone) {
+		t.Fatalf("expected ErrSubscriptionGone, got %v", err)
+	}
+	if kept := dispatcher.DeadLetters().List(); len(kept) != 1 || kept[0].ID != letter.ID {
+		t.Fatalf("dead letter should be kept under its ID when its subscription is gone: %+v", kept)
+	}
+}
+
+func TestEventDispatcher_RedriveGoesThroughTheQueue(t *testing.T) {
+	dispatcher := NewEventDispatcher()
+	gate := make(chan struct{})
+	var active, overlaps atomic.Int32
+	var mutex sync.Mutex
+	var seen []float64
+	failed := false
+	observer := &funcObserver{id: "queued", fn: func(event Event) error {
+		if active.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		defer active.Add(-1)
+		price := event.Data.(float64)
+		mutex.Lock()
+		if price == 1 && !failed {
+			failed = true
+			mutex.Unlock()
+			return errors.New("downstream unavailable")
+		}
+		mutex.Unlock()
+		if price == 2 {
+			<-gate
+		}
+		mutex.Lock()
+		seen = append(seen, price)
+		mutex.Unlock()
+		return nil
+	}}
+	dispatcher.Subscribe("price_update", observer, WithAsyncQueue(4, Block))
+	dispatcher.Notify(priceEvent(1))
+	dispatcher.Flush()
+	letters := dispatcher.DeadLetters().List()
+	if len(letters) != 1 {
+		t.Fatalf("expected one dead letter, got %+v", letters)
+	}
+
+	// While the observer is busy the redrive waits in its queue
+	dispatcher.Notify(priceEvent(2))
+	if err := dispatcher.Redrive(letters[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	close(gate)
+	dispatcher.Flush()
+	if len(seen) != 2 || seen[0] != 2 || seen[1] != 1 || overlaps.Load() != 0 {
+		t.Fatalf("redrive should be delivered after the queued event, one at a time: seen %v, overlaps %d", seen, overlaps.Load())
+	}
+	if dispatcher.DeadLetters().Len() != 0 {
+		t.Fatal("a queued redrive leaves the store")
+	}
+}
+
+func TestDeadLetterStore_DropsOldestWhenFull(t *testing.T) {
+	store := NewDeadLetterStore(WithMaxDeadLetters(2))
+	for i := 0; i < 3; i++ {
+		store.add(DeadLetter{ObserverID: "obs"})
+	}
+	letters := store.List()
+	if len(letters) != 2 || letters[0].ID != 2 || letters[1].ID != 3 || store.Dropped() != 1 {
+		t.Fatalf("expected letters 2 and 3 with one dropped, got %+v (dropped %d)", letters, store.Dropped())
+	}
+}
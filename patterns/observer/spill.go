@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// spillFile is an on-disk FIFO of length-prefixed gob records
+type spillFile struct {
+	file     *os.File
+	readOff  int64
+	writeOff int64
+	pending  int
+}
+
+// openSpillFile creates a new spill file in dir (or the temp dir when empty)
+func openSpillFile(dir string) (*spillFile, error) {
+	file, err := os.CreateTemp(dir, "observer-spill-*.gob")
+	if err != nil {
+		return nil, fmt.Errorf("create spill file: %w", err)
+	}
+	return &spillFile{file: file}, nil
+}
+
+// write appends an event to the end of the file
+func (s *spillFile) write(event Event) error {
+	n, err := writeRecord(s.file, s.writeOff, event)
+	if err != nil {
+		return err
+	}
+	s.writeOff += n
+	s.pending++
+	return nil
+}
+
+// read removes the oldest event from the file
+func (s *spillFile) read() (Event, error) {
+	event, n, err := readRecord(s.file, s.readOff)
+	s.readOff += n
+	s.pending--
+	if s.pending == 0 {
+		// Everything has been consumed, reuse the file from the start
+		s.readOff, s.writeOff = 0, 0
+		if truncErr := s.file.Truncate(0); truncErr != nil && err == nil {
+			err = truncErr
+		}
+	}
+	return event, err
+}
+
+// remove closes and deletes the file
+func (s *spillFile) remove() {
+	s.file.Close()
+	os.Remove(s.file.Name())
+}
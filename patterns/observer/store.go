@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ErrEventStoreClosed is returned when appending to a closed event store
+var ErrEventStoreClosed = errors.New("event store is closed")
+
+// EventStore is an append-only log of events numbered by sequence. Events are
+// kept in memory for replay and, when opened with a path, also appended to a
+// segment file so that the log survives restarts.
+type EventStore struct {
+	mutex  sync.RWMutex
+	events []Event
+	file   *os.File
+	size   int64
+	closed bool
+}
+
+// NewMemoryEventStore creates an event store that is not persisted
+func NewMemoryEventStore() *EventStore {
+	return &EventStore{}
+}
+
+// OpenEventStore opens or creates the segment file at path and loads the
+// events it already contains. A torn record at the end of the file, left by
+// a crash mid-write, is truncated away.
+func OpenEventStore(path string) (*EventStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open event store: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("stat event store: %w", err)
+	}
+
+	store := &EventStore{file: file}
+	for store.size < info.Size() {
+		event, n, err := readRecord(file, store.size)
+		if err != nil {
+			fmt.Printf("Event store %s: truncating torn record at offset %d: %v\n", path, store.size, err)
+			if err := file.Truncate(store.size); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("truncate event store: %w", err)
+			}
+			break
+		}
+		store.events = append(store.events, event)
+		store.size += n
+	}
+	return store, nil
+}
+
+// Append stores the event and returns it with its sequence number. On error
+// nothing is stored and the sequence number stays free for the next event.
+// A persistent store rejects payloads not registered with RegisterPayload.
+func (s *EventStore) Append(event Event) (Event, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return event, ErrEventStoreClosed
+	}
+	stored := event
+	stored.Sequence = uint64(len(s.events)) + 1
+	if s.file != nil {
+		if err := checkPayload(event.Data); err != nil {
+			return event, err
+		}
+		n, err := writeRecord(s.file, s.size, stored)
+		if err != nil {
+			// Drop any partial record so the next append starts clean
+			s.file.Truncate(s.size)
+			return event, err
+		}
+		s.size += n
+	}
+	s.events = append(s.events, stored)
+	return stored, nil
+}
+
+// LastSequence returns the sequence number of the newest event, or 0 when empty
+func (s *EventStore) LastSequence() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return uint64(len(s.events))
+}
+
+// Since returns all events with a sequence number of at least seq
+func (s *EventStore) Since(seq uint64) []Event {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if seq == 0 {
+		seq = 1
+	}
+	if seq > uint64(len(s.events)) {
+		return nil
+	}
+	return append([]Event(nil), s.events[seq-1:]...)
+}
+
+// Snapshot returns the latest event for each type and source, such as the
+// last price of every stock, in sequence order
+func (s *EventStore) Snapshot() []Event {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	type key struct{ eventType, source string }
+	seen := make(map[key]bool)
+	var latest []Event
+	for i := len(s.events) - 1; i >= 0; i-- {
+		k := key{s.events[i].Type, s.events[i].Source}
+		if !seen[k] {
+			seen[k] = true
+			latest = append(latest, s.events[i])
+		}
+	}
+	for i, j := 0, len(latest)-1; i < j; i, j = i+1, j-1 {
+		latest[i], latest[j] = latest[j], latest[i]
+	}
+	return latest
+}
+
+// Close closes the segment file; later appends fail with ErrEventStoreClosed
+func (s *EventStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
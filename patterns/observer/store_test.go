@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventStore_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.seg")
+	store, err := OpenEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, price := range []float64{1, 2, 3} {
+		if _, err := store.Append(priceEvent(price)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	// Simulate a crash in the middle of writing a fourth record
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte{0, 0, 1})
+	file.Close()
+
+	store, err = OpenEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if store.LastSequence() != 3 {
+		t.Fatalf("expected sequence 3 after reopen, got %d", store.LastSequence())
+	}
+	event, err := store.Append(priceEvent(4))
+	if err != nil || event.Sequence != 4 {
+		t.Fatalf("expected sequence 4, got %d (%v)", event.Sequence, err)
+	}
+	events := store.Since(2)
+	if len(events) != 3 || events[0].Data.(float64) != 2 || events[2].Sequence != 4 {
+		t.Fatalf("unexpected events since 2: %+v", events)
+	}
+}
+
+func TestEventDispatcher_ReplayFromSequence(t *testing.T) {
+	dispatcher := NewEventDispatcher(WithEventStore(NewMemoryEventStore()))
+	for _, price := range []float64{1, 2, 3} {
+		dispatcher.Notify(priceEvent(price))
+	}
+
+	obs := &recordingObserver{id: "rec"}
+	dispatcher.Subscribe("price_update", obs, FromSequence(2))
+	dispatcher.Notify(priceEvent(4))
+
+	assertPrices(t, obs.prices(), 2, 3, 4)
+}
+
+func TestEventDispatcher_ReplayLatestSnapshot(t *testing.T) {
+	dispatcher := NewEventDispatcher(WithEventStore(NewMemoryEventStore()))
+	publish := func(source string, price float64) {
+		dispatcher.Notify(Event{Type: "price_update", Data: price, Timestamp: time.Now(), Source: source})
+	}
+	publish("AAPL", 1)
+	publish("MSFT", 10)
+	publish("AAPL", 2)
+	dispatcher.Notify(Event{Type: "system_alert", Data: "ignored", Timestamp: time.Now()})
+
+	obs := &recordingObserver{id: "rec"}
+	dispatcher.Subscribe("price_update", obs, WithAsyncQueue(1, Block), FromLatestSnapshot())
+	publish("MSFT", 11)
+	dispatcher.Close()
+
+	assertPrices(t, obs.prices(), 10, 2, 11)
+}
+
+func TestEventStore_FailedAppendIsNotStored(t *testing.T) {
+	store, err := OpenEventStore(filepath.Join(t.TempDir(), "events.seg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher := NewEventDispatcher(WithEventStore(store))
+	obs := &recordingObserver{id: "rec"}
+	dispatcher.Subscribe("price_update", obs)
+
+	dispatcher.Notify(priceEvent(1))
+	// A channel cannot be encoded, so the store rejects the event
+	dispatcher.Notify(Event{Type: "price_update", Data: make(chan int), Timestamp: time.Now()})
+	dispatcher.Notify(priceEvent(2))
+
+	assertPrices(t, obs.prices(), 1, 2)
+	if events := store.Since(0); len(events) != 2 || events[1].Sequence != 2 {
+		t.Fatalf("a failed append must not use up a sequence number: %+v", events)
+	}
+
+	store.Close()
+	if _, err := store.Append(priceEvent(3)); !errors.Is(err, ErrEventStoreClosed) {
+		t.Fatalf("expected ErrEventStoreClosed, got %v", err)
+	}
+	dispatcher.Notify(priceEvent(3))
+	assertPrices(t, obs.prices(), 1, 2)
+}
+
+func TestEventDispatcher_ReplayDoesNotBlockPublishers(t *testing.T) {
+	dispatcher := NewEventDispatcher(WithEventStore(NewMemoryEventStore()))
+	dispatcher.Notify(priceEvent(1))
+	dispatcher.Notify(priceEvent(2))
+
+	obs := &recordingObserver{id: "rec", gate: make(chan struct{})}
+	subscribed := make(chan struct{})
+	go func() {
+		defer close(subscribed)
+		dispatcher.Subscribe("price_update", obs, FromSequence(1))
+	}()
+
+	// The replay is stuck on the gate, yet publishing goes on
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		dispatcher.Notify(priceEvent(3))
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Notify was blocked by a replaying subscriber")
+	}
+
+	close(obs.gate)
+	<-subscribed
+	dispatcher.Notify(priceEvent(4))
+	assertPrices(t, obs.prices(), 1, 2, 3, 4)
+}
+
+// tradePayload is a custom payload type for the registration tests
+type tradePayload struct {
+	Symbol string
+	Shares int
+}
+
+func TestEventStore_RequiresRegisteredPayloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.seg")
+	store, err := OpenEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trade := Event{Type: "trade", Data: tradePayload{Symbol: "AAPL", Shares: 10}}
+	if _, err := store.Append(trade); !errors.Is(err, ErrUnregisteredPayload) {
+		t.Fatalf("expected ErrUnregisteredPayload, got %v", err)
+	}
+	for _, data := range []any{nil, 1.5, "text", []string{"a"}, StockQuote{Symbol: "AAPL"}} {
+		if _, err := store.Append(Event{Type: "other", Data: data}); err != nil {
+			t.Fatalf("%T should not need registering: %v", data, err)
+		}
+	}
+
+	RegisterPayload(tradePayload{})
+	if _, err := store.Append(trade); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = OpenEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if events := store.Since(6); len(events) != 1 || events[0].Data != trade.Data {
+		t.Fatalf("the registered payload should survive a reopen, got %+v", events)
+	}
+}
+
+func TestReadRecord_RejectsCorruptLength(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "events.seg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	// A torn header claiming a 4 GiB record followed by a few bytes
+	file.Write([]byte{0xff, 0xff, 0xff, 0xff, 1, 2, 3})
+
+	if _, _, err := readRecord(file, 0); !errors.Is(err, ErrCorruptRecord) {
+		t.Fatalf("expected ErrCorruptRecord, got %v", err)
+	}
+	store, err := OpenEventStore(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if store.LastSequence() != 0 {
+		t.Fatalf("the corrupt record should be truncated away, got sequence %d", store.LastSequence())
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// SubscribeOption configures a single subscription
+type SubscribeOption func(*subscribeConfig)
+
+// subscribeConfig collects the options passed to Subscribe
+type subscribeConfig struct {
+	async        bool
+	capacity     int
+	policy       OverflowPolicy
+	spillDir     string
+	replay       replayMode
+	fromSequence uint64
+	retry        RetryPolicy
+	filters      []*subscriptionFilter
+	lanes        int
+	laneKey      KeyFunc
+	err          error // first invalid option
+}
+
+// newSubscribeConfig applies the options over the defaults
+func newSubscribeConfig(opts []SubscribeOption) (subscribeConfig, error) {
+	cfg := subscribeConfig{capacity: 1, retry: noRetry, lanes: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.err == nil && cfg.async && (cfg.capacity <= 0 || cfg.lanes <= 0) {
+		cfg.err = fmt.Errorf("queue capacity and lane count must be positive")
+	}
+	if cfg.err == nil && cfg.lanes > 1 && cfg.laneKey == nil {
+		cfg.err = fmt.Errorf("ordered lanes need a key function")
+	}
+	return cfg, cfg.err
+}
+
+// replayMode selects which stored events a new subscription receives first
+type replayMode int
+
+const (
+	replayNone replayMode = iota
+	replayFromSequence
+	replaySnapshot
+)
+
+// WithAsyncQueue delivers events to the observer through a bounded queue
+// drained by a dedicated goroutine, instead of updating it inside Notify.
+// The policy decides what happens once the queue holds capacity events.
+func WithAsyncQueue(capacity int, policy OverflowPolicy) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.async = true
+		cfg.capacity = capacity
+		cfg.policy = policy
+	}
+}
+
+// WithSpillDir sets the directory used for SpillToDisk overflow files.
+// The system temporary directory is used when it is not set.
+func WithSpillDir(dir string) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.spillDir = dir
+	}
+}
+
+// FromSequence replays every stored event with a sequence number of at least
+// seq before live delivery starts. Pass the last processed sequence + 1 to
+// resume a restarted observer. It requires a dispatcher with an event store.
+func FromSequence(seq uint64) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.replay = replayFromSequence
+		cfg.fromSequence = seq
+	}
+}
+
+// FromLatestSnapshot replays the latest stored event of each type and source
+// before live delivery starts, so late joiners learn the current state
+func FromLatestSnapshot() SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.replay = replaySnapshot
+	}
+}
+
+// subscription binds an observer to an event type
+type subscription struct {
+	eventType    string
+	observer     Observer
+	lanes        []*deliveryQueue // empty for synchronous delivery
+	laneKey      KeyFunc
+	laneSeed     maphash.Seed
+	replay       replayMode
+	fromSequence uint64
+	retry        RetryPolicy
+	deadLetters  *DeadLetterStore
+	deliverFn    DeliverFunc // the dispatcher's delivery middleware chain
+	filters      []*subscriptionFilter
+	handle       *Subscription // returned by Subscribe, ended on removal
+
+	replayMutex sync.Mutex
+	replaying   bool // live events are held in pending until the replay is done
+	pending     []Event
+	closed      bool
+}
+
+// newSubscription creates a subscription and starts its delivery goroutines if needed
+func newSubscription(eventType string, observer Observer, deadLetters *DeadLetterStore, deliver DeliverFunc, cfg subscribeConfig) *subscription {
+
+	sub := &subscription{
+		eventType:    eventType,
+		observer:     observer,
+		replay:       cfg.replay,
+		fromSequence: cfg.fromSequence,
+		retry:        cfg.retry,
+		deadLetters:  deadLetters,
+		deliverFn:    deliver,
+		filters:      cfg.filters,
+		laneKey:      cfg.laneKey,
+		laneSeed:     maphash.MakeSeed(),
+	}
+	if cfg.async {
+		for i := 0; i < cfg.lanes; i++ {
+			sub.lanes = append(sub.lanes, newDeliveryQueue(cfg.capacity, cfg.policy, cfg.spillDir, func(event Event) {
+				sub.process(event)
+			}))
+		}
+	}
+	return sub
+}
+
+// deliver hands an event that passes the filters to the observer,
+// through the queue when asynchronous
+func (s *subscription) deliver(event Event) {
+	if !s.accepts(event) {
+		return
+	}
+	if s.async() {
+		s.laneFor(event).push(event)
+		return
+	}
+	s.process(event)
+}
+
+// hold keeps a live event back while the subscription is still replaying
+// history, and reports whether it did
+func (s *subscription) hold(event Event) bool {
+	s.replayMutex.Lock()
+	defer s.replayMutex.Unlock()
+	if s.replaying {
+		s.pending = append(s.pending, event)
+	}
+	return s.replaying
+}
+
+// catchUp delivers the replayed history, then the live events held back
+// meanwhile, until none are left and live delivery can take over
+func (s *subscription) catchUp(history []Event) {
+	for {
+		for _, event := range history {
+			s.deliver(event)
+		}
+		s.replayMutex.Lock()
+		history, s.pending = s.pending, nil
+		if len(history) == 0 || s.closed {
+			s.replaying = false
+			s.replayMutex.Unlock()
+			return
+		}
+		s.replayMutex.Unlock()
+	}
+}
+
+// async reports whether the subscription delivers through queues
+func (s *subscription) async() bool {
+	return len(s.lanes) > 0
+}
+
+// flush waits until every lane has delivered its queued events
+func (s *subscription) flush() {
+	for _, lane := range s.lanes {
+		lane.flush()
+	}
+}
+
+// process updates the observer, retrying failures and panics according to
+// the retry policy, and dead-letters the event if every attempt fails
+func (s *subscription) process(event Event) error {
+	var err error
+	attempts := 0
+	for {
+		attempts++
+		delivery := &Delivery{Observer: s.observer, EventType: s.eventType, Event: event, Attempt: attempts}
+		if err = s.deliverFn(delivery); err == nil {
+			return nil
+		}
+		if attempts >= s.retry.MaxAttempts {
+			break
+		}
+		time.Sleep(s.retry.Backoff(attempts))
+	}
+
+	fmt.Printf("Observer %s failed on %s after %d attempt(s): %v\n", s.observer.GetID(), event.Type, attempts, err)
+	if s.deadLetters != nil {
+		s.deadLetters.add(DeadLetter{
+			Event:      event,
+			EventType:  s.eventType,
+			ObserverID: s.observer.GetID(),
+			Err:        err,
+			Attempts:   attempts,
+			FailedAt:   time.Now(),
+		})
+	}
+	return err
+}
+
+// close drains and stops the subscription's lanes, if any, and drops live
+// events still held back by a replay
+func (s *subscription) close() {
+	s.replayMutex.Lock()
+	s.closed = true
+	s.pending = nil
+	s.replayMutex.Unlock()
+	for _, lane := range s.lanes {
+		lane.close()
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// TopicSeparator splits a topic into levels, e.g. "stock.AAPL.price_update"
+	TopicSeparator = "."
+	// SingleLevelWildcard matches exactly one topic level
+	SingleLevelWildcard = "*"
+	// MultiLevelWildcard matches any number of trailing levels, including none
+	MultiLevelWildcard = "#"
+)
+
+// StockTopic builds the hierarchical topic for a stock event, e.g. stock.AAPL.price_update
+func StockTopic(symbol, kind string) string {
+	return strings.Join([]string{"stock", symbol, kind}, TopicSeparator)
+}
+
+// eventKind returns the last level of a topic, so "stock.AAPL.price_update"
+// and plain "price_update" are handled the same way by observers
+func eventKind(topic string) string {
+	return topic[strings.LastIndex(topic, TopicSeparator)+1:]
+}
+
+// ValidateTopic checks that a topic events are published on is well formed:
+// no empty levels and no wildcards, which only make sense in subscriptions.
+func ValidateTopic(topic string) error {
+	if strings.ContainsAny(topic, SingleLevelWildcard+MultiLevelWildcard) {
+		return fmt.Errorf("topic %q: wildcards are only allowed in subscription patterns", topic)
+	}
+	return ValidateTopicPattern(topic)
+}
+
+// ValidateTopicPattern checks that a subscription pattern is well formed:
+// no empty levels, wildcards occupy a whole level and '#' only appears last.
+func ValidateTopicPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("topic pattern is empty")
+	}
+
+	levels := strings.Split(pattern, TopicSeparator)
+	for i, level := range levels {
+		switch {
+		case level == "":
+			return fmt.Errorf("topic pattern %q has an empty level", pattern)
+		case level == MultiLevelWildcard && i != len(levels)-1:
+			return fmt.Errorf("topic pattern %q: %s must be the last level", pattern, MultiLevelWildcard)
+		case level != MultiLevelWildcard && level != SingleLevelWildcard &&
+			strings.ContainsAny(level, SingleLevelWildcard+MultiLevelWildcard):
+			return fmt.Errorf("topic pattern %q: wildcards must occupy a whole level", pattern)
+		}
+	}
+	return nil
+}
+
+// topicNode is one level of the subscription trie
+type topicNode struct {
+	children map[string]*topicNode
+	subs     []*subscription
+}
+
+// topicTrie indexes subscriptions by pattern level so that matching a topic
+// only walks the branches that can match it
+type topicTrie struct {
+	root *topicNode
+}
+
+// newTopicTrie creates an empty trie
+func newTopicTrie() *topicTrie {
+	return &topicTrie{root: &topicNode{}}
+}
+
+// insert adds a subscription under its pattern
+func (t *topicTrie) insert(pattern string, sub *subscription) {
+	node := t.root
+	for _, level := range strings.Split(pattern, TopicSeparator) {
+		if node.children == nil {
+			node.children = make(map[string]*topicNode)
+		}
+		child, ok := node.children[level]
+		if !ok {
+			child = &topicNode{}
+			node.children[level] = child
+		}
+		node = child
+	}
+	node.subs = append(node.subs, sub)
+}
+
+// remove deletes the first subscription under pattern accepted by match,
+// pruning branches that become empty
+func (t *topicTrie) remove(pattern string, match func(*subscription) bool) *subscription {
+	return t.root.remove(strings.Split(pattern, TopicSeparator), match)
+}
+
+func (n *topicNode) remove(levels []string, match func(*subscription) bool) *subscription {
+	if len(levels) == 0 {
+		for i, sub := range n.subs {
+			if match(sub) {
+				n.subs = append(n.subs[:i:i], n.subs[i+1:]...)
+				return sub
+			}
+		}
+		return nil
+	}
+
+	child, ok := n.children[levels[0]]
+	if !ok {
+		return nil
+	}
+	removed := child.remove(levels[1:], match)
+	if len(child.subs) == 0 && len(child.children) == 0 {
+		delete(n.children, levels[0])
+	}
+	return removed
+}
+
+// match returns every subscription whose pattern matches the topic
+func (t *topicTrie) match(topic string) []*subscription {
+	var subs []*subscription
+	t.root.match(strings.Split(topic, TopicSeparator), &subs)
+	return subs
+}
+
+func (n *topicNode) match(levels []string, subs *[]*subscription) {
+	if multi, ok := n.children[MultiLevelWildcard]; ok {
+		*subs = append(*subs, multi.subs...)
+	}
+	if len(levels) == 0 {
+		*subs = append(*subs, n.subs...)
+		return
+	}
+	if child, ok := n.children[levels[0]]; ok {
+		child.match(levels[1:], subs)
+	}
+	if single, ok := n.children[SingleLevelWildcard]; ok {
+		single.match(levels[1:], subs)
+	}
+}
+
+// all returns every subscription in the trie
+func (t *topicTrie) all() []*subscription {
+	var subs []*subscription
+	var walk func(*topicNode)
+	walk = func(n *topicNode) {
+		subs = append(subs, n.subs...)
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(t.root)
+	return subs
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestTopicTrie_Match(t *testing.T) {
+	trie := newTopicTrie()
+	patterns := []string{
+		"price_update",
+		"stock.AAPL.price_update",
+		"stock.*.price_update",
+		"stock.AAPL.#",
+		"stock.#",
+		"#",
+		"stock.*",
+	}
+	for _, pattern := range patterns {
+		trie.insert(pattern, &subscription{eventType: pattern})
+	}
+
+	tests := []struct {
+		topic string
+		want  []string
+	}{
+		{"price_update", []string{"#", "price_update"}},
+		{"stock.AAPL.price_update", []string{"#", "stock.#", "stock.*.price_update", "stock.AAPL.#", "stock.AAPL.price_update"}},
+		{"stock.MSFT.price_update", []string{"#", "stock.#", "stock.*.price_update"}},
+		{"stock.AAPL", []string{"#", "stock.#", "stock.*", "stock.AAPL.#"}},
+		{"stock", []string{"#", "stock.#"}},
+		{"system_alert", []string{"#"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, sub := range trie.match(tt.topic) {
+			got = append(got, sub.eventType)
+		}
+		sort.Strings(got)
+		sort.Strings(tt.want)
+		if len(got) != len(tt.want) {
+			t.Fatalf("%s: expected %v, got %v", tt.topic, tt.want, got)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Fatalf("%s: expected %v, got %v", tt.topic, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestTopicTrie_RemovePrunes(t *testing.T) {
+	trie := newTopicTrie()
+	sub := &subscription{eventType: "stock.AAPL.#"}
+	trie.insert("stock.AAPL.#", sub)
+
+	removed := trie.remove("stock.AAPL.#", func(s *subscription) bool { return s == sub })
+	if removed != sub {
+		t.Fatal("expected the subscription to be removed")
+	}
+	if len(trie.root.children) != 0 {
+		t.Fatalf("expected empty branches to be pruned, got %v", trie.root.children)
+	}
+	if len(trie.match("stock.AAPL.price_update")) != 0 {
+		t.Fatal("removed subscription still matches")
+	}
+}
+
+func TestValidateTopicPattern(t *testing.T) {
+	valid := []string{"price_update", "stock.*.price_update", "stock.#", "#", "*"}
+	for _, pattern := range valid {
+		if err := ValidateTopicPattern(pattern); err != nil {
+			t.Errorf("%q should be valid: %v", pattern, err)
+		}
+	}
+
+	invalid := []string{"", "stock..price", "stock.#.price", "stock.AA*", "stock.#x"}
+	for _, pattern := range invalid {
+		if err := ValidateTopicPattern(pattern); err == nil {
+			t.Errorf("%q should be rejected", pattern)
+		}
+	}
+}
+
+func TestValidateTopic(t *testing.T) {
+	if err := ValidateTopic("stock.AAPL.price_update"); err != nil {
+		t.Fatal(err)
+	}
+	for _, topic := range []string{"stock.*.price_update", "stock.#", "#", "stock..price"} {
+		if err := ValidateTopic(topic); err == nil {
+			t.Errorf("%q should be rejected as a publish topic", topic)
+		}
+	}
+}
+
+func TestEventDispatcher_NotifyDropsInvalidTopics(t *testing.T) {
+	dispatcher := NewEventDispatcher()
+	obs := &recordingObserver{id: "rec"}
+	dispatcher.Subscribe("#", obs)
+
+	for _, topic := range []string{"stock.#", "stock.*.price_update", "stock..price", ""} {
+		event := priceEvent(1)
+		event.Type = topic
+		dispatcher.Notify(event)
+	}
+	dispatcher.Notify(priceEvent(2))
+	assertPrices(t, obs.prices(), 2)
+}
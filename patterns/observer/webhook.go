@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook request headers
+const (
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// WebhookEndpoint is a URL that receives events, signed with its own secret
+type WebhookEndpoint struct {
+	URL    string
+	Secret string
+}
+
+// WebhookPayload is the JSON body posted to every endpoint. Its ID is
+// derived from the event, so a redelivered event keeps its ID and receivers
+// can use it to drop duplicates.
+type WebhookPayload struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Source    string      `json:"source"`
+	Sequence  uint64      `json:"sequence,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// DeliveryAttempt is one entry of an endpoint's delivery log
+type DeliveryAttempt struct {
+	DeliveryID string
+	EventType  string
+	Attempt    int
+	StatusCode int // 0 when no response was received
+	Err        error
+	Duration   time.Duration
+	At         time.Time
+}
+
+// Succeeded reports whether the endpoint accepted the delivery
+func (a DeliveryAttempt) Succeeded() bool {
+	return a.Err == nil && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// SignWebhook returns the signature header value for a body sent at timestamp:
+// "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>"
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks a received request in constant time;
+// receivers should also reject timestamps that are too old
+func VerifyWebhookSignature(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhook(secret, timestamp, body)), []byte(signature))
+}
+
+// WebhookOption configures a WebhookObserver
+type WebhookOption func(*WebhookObserver)
+
+// WithWebhookClient sets the HTTP client used for deliveries
+func WithWebhookClient(client *http.Client) WebhookOption {
+	return func(wo *WebhookObserver) {
+		wo.client = client
+	}
+}
+
+// WithWebhookTimeout limits how long a single attempt may take
+func WithWebhookTimeout(timeout time.Duration) WebhookOption {
+	return func(wo *WebhookObserver) {
+		wo.timeout = timeout
+	}
+}
+
+// WithWebhookRetry retries 5xx responses and timeouts of each endpoint on
+// its own, so endpoints that accepted the event are not posted to again.
+// By default every endpoint gets a single attempt. The retries run inside
+// Update, so with a subscription WithRetry as well the attempts multiply:
+// each of the subscription's attempts makes up to policy.MaxAttempts posts.
+// Use one of the two.
+func WithWebhookRetry(policy RetryPolicy) WebhookOption {
+	return func(wo *WebhookObserver) {
+		wo.retry = policy
+	}
+}
+
+// WithDeliveryLogSize sets how many attempts are kept per endpoint; it
+// panics on a negative size
+func WithDeliveryLogSize(size int) WebhookOption {
+	if size < 0 {
+		panic("webhook delivery log size must not be negative")
+	}
+	return func(wo *WebhookObserver) {
+		wo.logSize = size
+	}
+}
+
+// WebhookObserver implements Observer by POSTing events as signed JSON to
+// a set of endpoints
+type WebhookObserver struct {
+	ID        string
+	endpoints []WebhookEndpoint
+	client    *http.Client
+	timeout   time.Duration
+	retry     RetryPolicy
+	logSize   int
+
+	mutex sync.Mutex
+	logs  map[string][]DeliveryAttempt
+}
+
+// NewWebhookObserver creates a webhook observer for the given endpoints
+func NewWebhookObserver(id string, endpoints []WebhookEndpoint, opts ...WebhookOption) *WebhookObserver {
+	wo := &WebhookObserver{
+		ID:        id,
+		endpoints: endpoints,
+		client:    http.DefaultClient,
+		timeout:   5 * time.Second,
+		retry:     noRetry,
+		logSize:   100,
+		logs:      make(map[string][]DeliveryAttempt),
+	}
+	for _, opt := range opts {
+		opt(wo)
+	}
+	return wo
+}
+
+// Update delivers the event to every endpoint concurrently. It fails if any
+// endpoint still rejects the event once its retries are used up.
+func (wo *WebhookObserver) Update(event Event) error {
+	id := webhookEventID(event)
+	body, err := json.Marshal(WebhookPayload{
+		ID:        id.String(),
+		Type:      event.Type,
+		Source:    event.Source,
+		Sequence:  event.Sequence,
+		Timestamp: event.Timestamp,
+		Data:      event.Data,
+	})
+	if err != nil {
+		return fmt.Errorf("webhook %s: encode event: %w", wo.ID, err)
+	}
+
+	errs := make([]error, len(wo.endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range wo.endpoints {
+		wg.Add(1)
+		go func(i int, endpoint WebhookEndpoint) {
+			defer wg.Done()
+			errs[i] = wo.deliver(endpoint, uuid.NewSHA1(id, []byte(endpoint.URL)).String(), event.Type, body)
+		}(i, endpoint)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// webhookIDSpace is the UUID namespace of webhook payload IDs
+var webhookIDSpace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/kenneth-wang/go-demo/webhook"))
+
+// webhookEventID names an event by its source, type, sequence and time, so
+// that the same event always gets the same ID
+func webhookEventID(event Event) uuid.UUID {
+	name := fmt.Sprintf("%s\x00%s\x00%d\x00%d", event.Source, event.Type, event.Sequence, event.Timestamp.UnixNano())
+	return uuid.NewSHA1(webhookIDSpace, []byte(name))
+}
+
+// deliver posts body to one endpoint, retrying retryable failures. The
+// delivery ID is the same for every attempt and every redelivery of an event.
+func (wo *WebhookObserver) deliver(endpoint WebhookEndpoint, deliveryID, eventType string, body []byte) error {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		status, err := wo.post(endpoint, deliveryID, body)
+		wo.record(endpoint.URL, DeliveryAttempt{
+			DeliveryID: deliveryID,
+			EventType:  eventType,
+			Attempt:    attempt,
+			StatusCode: status,
+			Err:        err,
+			Duration:   time.Since(start),
+			At:         start,
+		})
+
+		if err == nil && status < 300 {
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("unexpected status %d", status)
+		}
+		if !retryableDelivery(status) || attempt >= wo.retry.MaxAttempts {
+			return fmt.Errorf("webhook %s to %s failed after %d attempt(s): %w", wo.ID, endpoint.URL, attempt, err)
+		}
+		time.Sleep(wo.retry.Backoff(attempt))
+	}
+}
+
+// retryableDelivery reports whether a failed attempt is worth retrying:
+// network errors and timeouts (no status) and 5xx responses are, 4xx are not
+func retryableDelivery(status int) bool {
+	return status == 0 || status >= 500
+}
+
+// post performs a single signed request
+func (wo *WebhookObserver) post(endpoint WebhookEndpoint, deliveryID string, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wo.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIDHeader, deliveryID)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(endpoint.Secret, timestamp, body))
+
+	resp, err := wo.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// record appends an attempt to the endpoint's log, dropping the oldest entries
+func (wo *WebhookObserver) record(url string, attempt DeliveryAttempt) {
+	wo.mutex.Lock()
+	defer wo.mutex.Unlock()
+
+	log := append(wo.logs[url], attempt)
+	if len(log) > wo.logSize {
+		log = log[len(log)-wo.logSize:]
+	}
+	wo.logs[url] = log
+}
+
+// DeliveryLog returns the recorded attempts for an endpoint, oldest first
+func (wo *WebhookObserver) DeliveryLog(url string) []DeliveryAttempt {
+	wo.mutex.Lock()
+	defer wo.mutex.Unlock()
+
+	return append([]DeliveryAttempt(nil), wo.logs[url]...)
+}
+
+// GetID returns the observer ID
+func (wo *WebhookObserver) GetID() string {
+	return wo.ID
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastRetry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+// webhookServer answers with the given statuses in order, then 200
+func webhookServer(t *testing.T, secret string, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		body, _ := io.ReadAll(r.Body)
+		if !VerifyWebhookSignature(secret, r.Header.Get(WebhookTimestampHeader), body, r.Header.Get(WebhookSignatureHeader)) {
+			t.Errorf("invalid signature on request %d", n)
+		}
+		var payload WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil || payload.Type != "price_update" {
+			t.Errorf("unexpected payload %s: %v", body, err)
+		}
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestWebhookObserver_RetriesServerErrors(t *testing.T) {
+	server, calls := webhookServer(t, "s3cret", http.StatusInternalServerError, http.StatusBadGateway)
+	webhook := NewWebhookObserver("hook", []WebhookEndpoint{{URL: server.URL, Secret: "s3cret"}}, WithWebhookRetry(fastRetry))
+
+	if err := webhook.Update(stockEvent("AAPL", 150, 1)); err != nil {
+		t.Fatalf("expected delivery to succeed, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 requests, got %d", calls.Load())
+	}
+
+	log := webhook.DeliveryLog(server.URL)
+	if len(log) != 3 || log[0].StatusCode != 500 || log[1].StatusCode != 502 || !log[2].Succeeded() {
+		t.Fatalf("unexpected delivery log: %+v", log)
+	}
+	if log[0].DeliveryID != log[2].DeliveryID {
+		t.Fatal("retries of one delivery should share its ID")
+	}
+}
+
+func TestWebhookObserver_DoesNotRetryClientErrors(t *testing.T) {
+	server, calls := webhookServer(t, "s3cret", http.StatusBadRequest)
+	webhook := NewWebhookObserver("hook", []WebhookEndpoint{{URL: server.URL, Secret: "s3cret"}}, WithWebhookRetry(fastRetry))
+
+	if err := webhook.Update(stockEvent("AAPL", 150, 1)); err == nil {
+		t.Fatal("expected a 400 to fail the delivery")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected a single request, got %d", calls.Load())
+	}
+}
+
+func TestWebhookObserver_RetriesTimeouts(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	webhook := NewWebhookObserver("hook", []WebhookEndpoint{{URL: server.URL}},
+		WithWebhookRetry(fastRetry), WithWebhookTimeout(20*time.Millisecond))
+	if err := webhook.Update(stockEvent("AAPL", 150, 1)); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+
+	log := webhook.DeliveryLog(server.URL)
+	if len(log) != 2 || log[0].Err == nil || log[0].StatusCode != 0 || !log[1].Succeeded() {
+		t.Fatalf("unexpected delivery log: %+v", log)
+	}
+}
+
+func TestWebhookObserver_FailureIsDeadLettered(t *testing.T) {
+	server, _ := webhookServer(t, "s3cret", 503, 503, 503)
+	webhook := NewWebhookObserver("hook", []WebhookEndpoint{{URL: server.URL, Secret: "s3cret"}},
+		WithWebhookRetry(fastRetry), WithDeliveryLogSize(2))
+
+	dispatcher := NewEventDispatcher()
+	dispatcher.Subscribe("price_update", webhook)
+	dispatcher.Notify(stockEvent("AAPL", 150, 1))
+
+	if dispatcher.DeadLetters().Len() != 1 {
+		t.Fatal("expected the failed delivery to be dead-lettered")
+	}
+	if got := len(webhook.DeliveryLog(server.URL)); got != 2 {
+		t.Fatalf("delivery log should be capped at 2 entries, got %d", got)
+	}
+}
+
+func TestWebhookObserver_RedeliveryKeepsIDs(t *testing.T) {
+	var ids []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		ids = append(ids, payload.ID, r.Header.Get(WebhookIDHeader))
+	}))
+	t.Cleanup(server.Close)
+	webhook := NewWebhookObserver("hook", []WebhookEndpoint{{URL: server.URL}})
+
+	event := stockEvent("AAPL", 150, 1)
+	event.Sequence = 7
+	webhook.Update(event)
+	webhook.Update(event)
+	if len(ids) != 4 || ids[0] != ids[2] || ids[1] != ids[3] {
+		t.Fatalf("a redelivered event should keep its IDs, got %v", ids)
+	}
+
+	event.Sequence = 8
+	webhook.Update(event)
+	if ids[4] == ids[0] || ids[5] == ids[1] {
+		t.Fatal("another event needs other IDs")
+	}
+}
+
+func TestWebhookObserver_RetryLayers(t *testing.T) {
+	// By default the webhook posts once and leaves retries to the subscription
+	server, calls := webhookServer(t, "s3cret", 503, 503, 503, 503, 503, 503, 503, 503, 503)
+	dispatcher := NewEventDispatcher()
+	dispatcher.Subscribe("price_update",
+		NewWebhookObserver("hook", []WebhookEndpoint{{URL: server.URL, Secret: "s3cret"}}),
+		WithRetry(fastRetry))
+	dispatcher.Notify(stockEvent("AAPL", 150, 1))
+	if calls.Load() != 3 {
+		t.Fatalf("expected one post per subscription attempt, got %d", calls.Load())
+	}
+
+	// With both layers each subscription attempt retries the endpoint
+	calls.Store(0)
+	dispatcher.Subscribe("price_update",
+		NewWebhookObserver("layered", []WebhookEndpoint{{URL: server.URL, Secret: "s3cret"}}, WithWebhookRetry(fastRetry)),
+		WithRetry(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+	dispatcher.Unsubscribe("price_update", &WebhookObserver{ID: "hook"})
+	dispatcher.Notify(stockEvent("AAPL", 151, 1))
+	if calls.Load() != 6 {
+		t.Fatalf("expected 2 x 3 posts, got %d", calls.Load())
+	}
+}
+
+func TestWithDeliveryLogSize_RejectsNegative(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a negative log size")
+		}
+	}()
+	WithDeliveryLogSize(-1)
+}
@@ -0,0 +1,404 @@
+// Package httpcache provides gin middleware that caches GET responses in an
+// LRU cache, with ETag revalidation and Cache-Control support.
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kenneth-wang/go-demo/datastructures/lru/lrucache"
+)
+
+// response is a cached response
+type response struct {
+	status   int
+	header   http.Header
+	body     []byte
+	storedAt time.Time
+	vary     map[string]string // request header values named by the response's Vary
+}
+
+// ResponseCache caches the responses of the routes its middleware is used on
+type ResponseCache struct {
+	cache      *lrucache.SyncLRUCache[string, *response]
+	vary       []string
+	defaultTTL time.Duration
+	clock      lrucache.Clock
+}
+
+// config collects the options before the cache is built
+type config struct {
+	capacity   int
+	maxBytes   int64
+	vary       []string
+	defaultTTL time.Duration
+	clock      lrucache.Clock
+}
+
+// Option configures a ResponseCache
+type Option func(*config)
+
+// WithCapacity sets how many responses are kept; the default is 1000
+func WithCapacity(capacity int) Option {
+	return func(c *config) {
+		c.capacity = capacity
+	}
+}
+
+// WithMaxBytes bounds the total size of the cached bodies
+func WithMaxBytes(maxBytes int64) Option {
+	return func(c *config) {
+		c.maxBytes = maxBytes
+	}
+}
+
+// WithVary adds request headers whose values select separate cache entries,
+// such as Accept-Language or Accept-Encoding
+func WithVary(headers ...string) Option {
+	return func(c *config) {
+		for _, header := range headers {
+			c.vary = append(c.vary, textproto.CanonicalMIMEHeaderKey(header))
+		}
+	}
+}
+
+// WithDefaultTTL sets how long responses without max-age are cached; zero
+// caches only responses that carry max-age. The default is one minute.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.defaultTTL = ttl
+	}
+}
+
+// WithClock replaces the wall clock, for tests
+func WithClock(clock lrucache.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// systemClock reads the wall clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// New creates a ResponseCache
+func New(opts ...Option) *ResponseCache {
+	cfg := config{capacity: 1000, defaultTTL: time.Minute, clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	cacheOpts := []lrucache.Option[string, *response]{lrucache.WithClock[string, *response](cfg.clock)}
+	if cfg.maxBytes > 0 {
+		cacheOpts = append(cacheOpts, lrucache.WithMaxWeight(cfg.maxBytes, func(key string, r *response) int64 {
+			return int64(len(key) + len(r.body))
+		}))
+	}
+	return &ResponseCache{
+		cache:      lrucache.NewSyncLRUCache[string, *response](cfg.capacity, cacheOpts...),
+		vary:       cfg.vary,
+		defaultTTL: cfg.defaultTTL,
+		clock:      cfg.clock,
+	}
+}
+
+// Stats returns the counters of the underlying cache
+func (rc *ResponseCache) Stats() lrucache.Stats {
+	return rc.cache.Stats()
+}
+
+// Purge drops every cached response
+func (rc *ResponseCache) Purge() {
+	rc.cache.Clear()
+}
+
+// Middleware returns the gin handler that serves and stores GET responses.
+// Responses of the routes behind it are buffered so that an ETag can be
+// added, which makes it unsuitable for streaming handlers. Only the headers
+// the handlers write are stored. A response with a Vary header is served
+// only to requests with the same values of those headers; a request with
+// other values replaces it.
+func (rc *ResponseCache) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		directives := parseCacheControl(c.Request.Header)
+		if _, ok := directives["no-store"]; ok {
+			c.Next()
+			return
+		}
+
+		key := rc.key(c.Request)
+		if cached, ok := rc.lookup(key, c.Request, directives); ok {
+			header := c.Writer.Header()
+			for name, values := range cached.header {
+				header[name] = values
+			}
+			age := rc.clock.Now().Sub(cached.storedAt)
+			header.Set("Age", strconv.Itoa(int(age.Seconds())))
+			header.Set("X-Cache", "HIT")
+			write(c, cached)
+			c.Abort()
+			return
+		}
+
+		// Headers set before the handlers ran, such as request IDs or CORS,
+		// belong to this request and are not stored
+		upstream := c.Writer.Header().Clone()
+		recorder := &responseRecorder{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = recorder
+		completed := false
+		defer func() {
+			c.Writer = recorder.ResponseWriter
+			// A handler panicked: send what it wrote so far, as it would have
+			// been without the cache, and leave the rest to a recovery
+			// middleware further up
+			if !completed && recorder.written {
+				c.Writer.WriteHeader(recorder.status)
+				c.Writer.Write(recorder.body.Bytes())
+			}
+		}()
+		c.Next()
+		completed = true
+		c.Writer = recorder.ResponseWriter
+
+		fresh := &response{
+			status:   recorder.status,
+			body:     recorder.body.Bytes(),
+			storedAt: rc.clock.Now(),
+		}
+		header := c.Writer.Header()
+		if fresh.status == http.StatusOK && header.Get("ETag") == "" {
+			header.Set("ETag", etag(fresh.body))
+		}
+		fresh.header = written(upstream, header)
+		fresh.vary = varyValues(c.Request, fresh.header)
+		// An aborted chain is sent as it is but not cached
+		if ttl, ok := rc.ttl(fresh, c.Request); ok && !c.IsAborted() {
+			rc.cache.PutWithTTL(key, fresh, ttl)
+		}
+		header.Set("X-Cache", "MISS")
+		write(c, fresh)
+	}
+}
+
+// key identifies a request by method, path, sorted query and the values of
+// the configured Vary headers
+func (rc *ResponseCache) key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.URL.Path)
+	if query := r.URL.Query(); len(query) > 0 {
+		b.WriteByte('?')
+		b.WriteString(query.Encode())
+	}
+	for _, name := range rc.vary {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(strings.Join(r.Header.Values(name), ", "))
+	}
+	return b.String()
+}
+
+// lookup returns a cached response the request's Cache-Control accepts and
+// whose Vary headers the request matches
+func (rc *ResponseCache) lookup(key string, r *http.Request, directives map[string]string) (*response, bool) {
+	if _, ok := directives["no-cache"]; ok {
+		return nil, false
+	}
+	cached, ok := rc.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	for name, value := range cached.vary {
+		if strings.Join(r.Header.Values(name), ", ") != value {
+			return nil, false
+		}
+	}
+	if maxAge, ok := seconds(directives, "max-age"); ok && rc.clock.Now().Sub(cached.storedAt) > maxAge {
+		return nil, false
+	}
+	return cached, true
+}
+
+// ttl decides from the response whether and how long it may be cached.
+// Responses to requests with credentials are only shared when they are
+// marked public or carry s-maxage.
+func (rc *ResponseCache) ttl(r *response, req *http.Request) (time.Duration, bool) {
+	if r.status != http.StatusOK || r.header.Get("Set-Cookie") != "" || r.vary == nil {
+		return 0, false
+	}
+	directives := parseCacheControl(r.header)
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[directive]; ok {
+			return 0, false
+		}
+	}
+	if req.Header.Get("Authorization") != "" {
+		_, public := directives["public"]
+		_, shared := directives["s-maxage"]
+		if !public && !shared {
+			return 0, false
+		}
+	}
+	if ttl, ok := seconds(directives, "s-maxage"); ok {
+		return ttl, ttl > 0
+	}
+	if ttl, ok := seconds(directives, "max-age"); ok {
+		return ttl, ttl > 0
+	}
+	return rc.defaultTTL, rc.defaultTTL > 0
+}
+
+// written returns the headers that differ from those set before the handlers ran
+func written(before, after http.Header) http.Header {
+	header := make(http.Header)
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			header[name] = slices.Clone(values)
+		}
+	}
+	return header
+}
+
+// varyValues records the request's values of the headers the response's
+// Vary names. It returns nil for Vary: *, which matches no other request.
+func varyValues(r *http.Request, header http.Header) map[string]string {
+	values := make(map[string]string)
+	for _, line := range header.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+			switch name {
+			case "":
+				continue
+			case "*":
+				return nil
+			}
+			values[name] = strings.Join(r.Header.Values(name), ", ")
+		}
+	}
+	return values
+}
+
+// write sends r, or 304 Not Modified if the request already has its ETag
+func write(c *gin.Context, r *response) {
+	if tag := r.header.Get("ETag"); tag != "" && etagMatches(c.GetHeader("If-None-Match"), tag) {
+		c.Writer.Header().Del("Content-Length")
+		c.Writer.WriteHeader(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Writer.WriteHeader(r.status)
+	c.Writer.Write(r.body)
+}
+
+// etag derives a strong ETag from a response body
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists tag, comparing
+// weakly as RFC 9110 asks for If-None-Match
+func etagMatches(ifNoneMatch, tag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCacheControl splits the Cache-Control headers into lower-cased
+// directives and their unquoted values
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return directives
+}
+
+// seconds reads a delta-seconds directive such as max-age
+func seconds(directives map[string]string, name string) (time.Duration, bool) {
+	value, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// responseRecorder buffers a handler's response so it can be inspected,
+// tagged and cached before it is sent
+type responseRecorder struct {
+	gin.ResponseWriter
+	status  int
+	body    bytes.Buffer
+	written bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.written {
+		r.status = status
+	}
+}
+
+func (r *responseRecorder) WriteHeaderNow() {
+	r.written = true
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.written = true
+	return r.body.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.written = true
+	return r.body.WriteString(s)
+}
+
+func (r *responseRecorder) Status() int {
+	return r.status
+}
+
+func (r *responseRecorder) Size() int {
+	if !r.written {
+		return -1
+	}
+	return r.body.Len()
+}
+
+func (r *responseRecorder) Written() bool {
+	return r.written
+}
+
+// Flush is a no-op: the response is sent once the handlers are done
+func (r *responseRecorder) Flush() {}
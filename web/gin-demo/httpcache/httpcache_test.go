@@ -0,0 +1,393 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeClock is a Clock that only moves when told to
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newRouter serves GET /page behind the middleware and counts handler calls
+func newRouter(rc *ResponseCache, handler gin.HandlerFunc) (*gin.Engine, *int) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	r := gin.New()
+	r.Use(rc.Middleware())
+	counted := func(c *gin.Context) {
+		calls++
+		handler(c)
+	}
+	r.GET("/page", counted)
+	r.POST("/page", counted)
+	return r, &calls
+}
+
+// do sends a request with the given headers, given as name, value pairs
+func do(r http.Handler, method, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Add(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func hello(c *gin.Context) {
+	c.String(http.StatusOK, "hello %s", c.Query("name"))
+}
+
+func TestMiddleware_CachesGetResponses(t *testing.T) {
+	clock := newFakeClock()
+	r, calls := newRouter(New(WithClock(clock)), hello)
+
+	first := do(r, "GET", "/page?name=gopher")
+	if first.Code != http.StatusOK || first.Body.String() != "hello gopher" || first.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("first response %d %q %v", first.Code, first.Body, first.Header())
+	}
+	tag := first.Header().Get("ETag")
+	if tag == "" {
+		t.Fatal("an ETag should be generated")
+	}
+
+	clock.Advance(3 * time.Second)
+	second := do(r, "GET", "/page?name=gopher")
+	if second.Body.String() != "hello gopher" || second.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("second response %q %v", second.Body, second.Header())
+	}
+	if second.Header().Get("ETag") != tag || second.Header().Get("Age") != "3" {
+		t.Fatalf("a hit keeps the ETag and reports its age: %v", second.Header())
+	}
+	if second.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Fatal("a hit should replay the stored headers")
+	}
+	if *calls != 1 {
+		t.Fatalf("handler called %d times, want 1", *calls)
+	}
+}
+
+func TestMiddleware_KeyIncludesSortedQuery(t *testing.T) {
+	r, calls := newRouter(New(), hello)
+	do(r, "GET", "/page?name=a&x=1")
+	if w := do(r, "GET", "/page?x=1&name=a"); w.Header().Get("X-Cache") != "HIT" {
+		t.Fatal("the order of query parameters should not matter")
+	}
+	if w := do(r, "GET", "/page?name=b"); w.Body.String() != "hello b" {
+		t.Fatalf("a different query must not share an entry, got %q", w.Body)
+	}
+	if *calls != 2 {
+		t.Fatalf("handler called %d times, want 2", *calls)
+	}
+}
+
+func TestMiddleware_IfNoneMatch(t *testing.T) {
+	r, _ := newRouter(New(), hello)
+	tag := do(r, "GET", "/page").Header().Get("ETag")
+
+	w := do(r, "GET", "/page", "If-None-Match", `"other", `+tag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("a matching If-None-Match should get an empty 304, got %d %q", w.Code, w.Body)
+	}
+	if w.Header().Get("ETag") != tag {
+		t.Fatal("a 304 should repeat the ETag")
+	}
+	if w := do(r, "GET", "/page", "If-None-Match", `"stale"`); w.Code != http.StatusOK {
+		t.Fatalf("a stale ETag should get the full response, got %d", w.Code)
+	}
+
+	// A miss is revalidated against the fresh response as well
+	r, _ = newRouter(New(), hello)
+	if w := do(r, "GET", "/page", "If-None-Match", "W/"+tag); w.Code != http.StatusNotModified {
+		t.Fatalf("a weak match on a miss should get 304, got %d", w.Code)
+	}
+}
+
+func TestMiddleware_HandlerETagIsKept(t *testing.T) {
+	r, _ := newRouter(New(), func(c *gin.Context) {
+		c.Header("ETag", `"v42"`)
+		c.String(http.StatusOK, "versioned")
+	})
+	if tag := do(r, "GET", "/page").Header().Get("ETag"); tag != `"v42"` {
+		t.Fatalf("ETag = %s, the handler's own should win", tag)
+	}
+	if w := do(r, "GET", "/page", "If-None-Match", `"v42"`); w.Code != http.StatusNotModified {
+		t.Fatalf("got %d, want 304", w.Code)
+	}
+}
+
+func TestMiddleware_Vary(t *testing.T) {
+	r, calls := newRouter(New(WithVary("accept-language")), func(c *gin.Context) {
+		c.String(http.StatusOK, "lang=%s", c.GetHeader("Accept-Language"))
+	})
+	do(r, "GET", "/page", "Accept-Language", "en")
+	if w := do(r, "GET", "/page", "Accept-Language", "fr"); w.Body.String() != "lang=fr" {
+		t.Fatalf("another language must not share an entry, got %q", w.Body)
+	}
+	if w := do(r, "GET", "/page", "Accept-Language", "en"); w.Header().Get("X-Cache") != "HIT" {
+		t.Fatal("the same language should hit")
+	}
+	if *calls != 2 {
+		t.Fatalf("handler called %d times, want 2", *calls)
+	}
+}
+
+func TestMiddleware_ResponseCacheControl(t *testing.T) {
+	clock := newFakeClock()
+	tests := []struct {
+		cacheControl string
+		cached       bool
+	}{
+		{"no-store", false},
+		{"no-cache", false},
+		{"private, max-age=60", false},
+		{"public, max-age=0", false},
+		{"public, max-age=10", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			r, calls := newRouter(New(WithClock(clock)), func(c *gin.Context) {
+				if tt.cacheControl != "" {
+					c.Header("Cache-Control", tt.cacheControl)
+				}
+				c.String(http.StatusOK, "body")
+			})
+			do(r, "GET", "/page")
+			do(r, "GET", "/page")
+			if cached := *calls == 1; cached != tt.cached {
+				t.Fatalf("cached = %v, want %v", cached, tt.cached)
+			}
+		})
+	}
+}
+
+func TestMiddleware_MaxAgeSetsTTL(t *testing.T) {
+	clock := newFakeClock()
+	r, calls := newRouter(New(WithClock(clock), WithDefaultTTL(time.Hour)), func(c *gin.Context) {
+		c.Header("Cache-Control", "max-age=10")
+		c.String(http.StatusOK, "body")
+	})
+	do(r, "GET", "/page")
+	clock.Advance(9 * time.Second)
+	do(r, "GET", "/page")
+	clock.Advance(time.Second)
+	do(r, "GET", "/page")
+	if *calls != 2 {
+		t.Fatalf("handler called %d times, want 2 with max-age=10", *calls)
+	}
+}
+
+func TestMiddleware_RequestCacheControl(t *testing.T) {
+	clock := newFakeClock()
+	r, calls := newRouter(New(WithClock(clock)), hello)
+	do(r, "GET", "/page")
+
+	if w := do(r, "GET", "/page", "Cache-Control", "no-cache"); w.Header().Get("X-Cache") != "MISS" {
+		t.Fatal("no-cache should skip the cached response")
+	}
+	if *calls != 2 {
+		t.Fatalf("handler called %d times, want 2", *calls)
+	}
+
+	clock.Advance(20 * time.Second)
+	if w := do(r, "GET", "/page", "Cache-Control", "max-age=30"); w.Header().Get("X-Cache") != "HIT" {
+		t.Fatal("a response younger than max-age should be served")
+	}
+	if w := do(r, "GET", "/page", "Cache-Control", "max-age=10"); w.Header().Get("X-Cache") != "MISS" {
+		t.Fatal("a response older than max-age should be refreshed")
+	}
+
+	if w := do(r, "GET", "/page", "Cache-Control", "no-store"); w.Header().Get("X-Cache") != "" {
+		t.Fatal("no-store should bypass the cache entirely")
+	}
+}
+
+func TestMiddleware_OnlyCachesSuccessfulGets(t *testing.T) {
+	status := http.StatusNotFound
+	r, calls := newRouter(New(), func(c *gin.Context) {
+		c.String(status, "status %d", status)
+	})
+	do(r, "GET", "/page")
+	do(r, "GET", "/page")
+	if *calls != 2 {
+		t.Fatal("error responses must not be cached")
+	}
+
+	status = http.StatusOK
+	do(r, "POST", "/page")
+	if w := do(r, "POST", "/page"); w.Header().Get("X-Cache") != "" || *calls != 4 {
+		t.Fatal("POST requests must pass through")
+	}
+}
+
+func TestMiddleware_HandlerPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, _ any) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}), New().Middleware())
+	calls := 0
+	r.GET("/page", func(c *gin.Context) {
+		calls++
+		panic("boom")
+	})
+	for i := 0; i < 2; i++ {
+		if w := do(r, "GET", "/page"); w.Code != http.StatusInternalServerError {
+			t.Fatalf("recovery status should reach the client, got %d %q", w.Code, w.Body)
+		}
+	}
+	if calls != 2 {
+		t.Fatal("a panicking handler must not be cached")
+	}
+
+	// What a handler wrote before panicking is sent as it would be uncached
+	r.GET("/partial", func(c *gin.Context) {
+		c.String(http.StatusAccepted, "partial")
+		panic("boom")
+	})
+	if w := do(r, "GET", "/partial"); w.Code != http.StatusAccepted || w.Body.String() != "partial" {
+		t.Fatalf("got %d %q", w.Code, w.Body)
+	}
+}
+
+func TestMiddleware_AbortedIsNotCached(t *testing.T) {
+	r, calls := newRouter(New(), func(c *gin.Context) {
+		c.String(http.StatusOK, "aborted")
+		c.Abort()
+	})
+	do(r, "GET", "/page")
+	if w := do(r, "GET", "/page"); w.Code != http.StatusOK || w.Body.String() != "aborted" || *calls != 2 {
+		t.Fatalf("aborted responses must be sent but not cached, got %d %q after %d calls", w.Code, w.Body, *calls)
+	}
+}
+
+func TestMiddleware_SetCookieIsNotCached(t *testing.T) {
+	r, calls := newRouter(New(), func(c *gin.Context) {
+		c.SetCookie("session", "secret", 60, "/", "", false, true)
+		c.String(http.StatusOK, "personal")
+	})
+	do(r, "GET", "/page")
+	do(r, "GET", "/page")
+	if *calls != 2 {
+		t.Fatal("responses that set cookies must not be shared")
+	}
+}
+
+func TestMiddleware_AuthorizedRequests(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		cached       bool
+	}{
+		{"", false},
+		{"max-age=60", false},
+		{"public, max-age=60", true},
+		{"s-maxage=60", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			r, calls := newRouter(New(), func(c *gin.Context) {
+				if tt.cacheControl != "" {
+					c.Header("Cache-Control", tt.cacheControl)
+				}
+				c.String(http.StatusOK, "account")
+			})
+			do(r, "GET", "/page", "Authorization", "Bearer alice")
+			do(r, "GET", "/page", "Authorization", "Bearer bob")
+			if cached := *calls == 1; cached != tt.cached {
+				t.Fatalf("cached = %v, want %v", cached, tt.cached)
+			}
+		})
+	}
+}
+
+func TestMiddleware_UpstreamHeadersAreNotStored(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	requestID := 0
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		requestID++
+		c.Header("X-Request-Id", strconv.Itoa(requestID))
+	})
+	r.Use(New().Middleware())
+	r.GET("/page", hello)
+
+	do(r, "GET", "/page")
+	w := do(r, "GET", "/page")
+	if w.Header().Get("X-Cache") != "HIT" {
+		t.Fatal("second request should hit")
+	}
+	if id := w.Header().Get("X-Request-Id"); id != "2" {
+		t.Fatalf("X-Request-Id = %q, a hit must keep the header of its own request", id)
+	}
+	if w.Header().Get("Content-Type") == "" {
+		t.Fatal("headers written by the handler should be replayed")
+	}
+}
+
+func TestMiddleware_HandlerVary(t *testing.T) {
+	r, calls := newRouter(New(), func(c *gin.Context) {
+		c.Header("Vary", "Accept-Encoding")
+		c.String(http.StatusOK, "encoding=%s", c.GetHeader("Accept-Encoding"))
+	})
+	do(r, "GET", "/page", "Accept-Encoding", "gzip")
+	if w := do(r, "GET", "/page", "Accept-Encoding", "gzip"); w.Header().Get("X-Cache") != "HIT" {
+		t.Fatal("a request with the same Vary values should hit")
+	}
+	if w := do(r, "GET", "/page"); w.Body.String() != "encoding=" {
+		t.Fatalf("a request with other Vary values must not be served the entry, got %q", w.Body)
+	}
+	if *calls != 2 {
+		t.Fatalf("handler called %d times, want 2", *calls)
+	}
+
+	r, calls = newRouter(New(), func(c *gin.Context) {
+		c.Header("Vary", "*")
+		c.String(http.StatusOK, "body")
+	})
+	do(r, "GET", "/page")
+	do(r, "GET", "/page")
+	if *calls != 2 {
+		t.Fatal("Vary: * must not be cached")
+	}
+}
+
+func TestPurge(t *testing.T) {
+	rc := New()
+	r, calls := newRouter(rc, hello)
+	do(r, "GET", "/page")
+	rc.Purge()
+	do(r, "GET", "/page")
+	if *calls != 2 {
+		t.Fatal("Purge should drop cached responses")
+	}
+	if stats := rc.Stats(); stats.Hits != 0 || stats.Misses != 2 {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/kenneth-wang/go-demo/web/gin-demo/httpcache"
 )
 
 func main() {
@@ -9,5 +12,17 @@ func main() {
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "Hello from Gin!"})
 	})
+
+	// Responses under /cached are kept in an LRU cache for 30 seconds
+	responses := httpcache.New(httpcache.WithCapacity(100), httpcache.WithDefaultTTL(30*time.Second),
+		httpcache.WithVary("Accept-Language"))
+	cached := r.Group("/cached", responses.Middleware())
+	cached.GET("/time", func(c *gin.Context) {
+		c.JSON(200, gin.H{"generated_at": time.Now().Format(time.RFC3339Nano)})
+	})
+	cached.GET("/stats", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.JSON(200, gin.H{"cache": responses.Stats().String()})
+	})
 	r.Run(":8080")
 }